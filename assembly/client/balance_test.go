@@ -0,0 +1,114 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//testEndpoints returns the endpoints of the calls in progress
+func testEndpoints(outstanding ...int64) []*Endpoint {
+	endpoints := make([]*Endpoint, len(outstanding))
+	for i, n := range outstanding {
+		endpoints[i] = &Endpoint{_addr: fmt.Sprintf("127.0.0.1:%d", 8001+i), _outstanding: n}
+	}
+	return endpoints
+}
+
+func TestBalanceRoundRobin(t *testing.T) {
+	endpoints := testEndpoints(0, 0, 0)
+	rr := &RoundRobinBalancer{}
+	for i := 0; i < 6; i++ {
+		if ep := rr.Pick(endpoints, ""); ep != endpoints[i%3] {
+			t.Fatalf("round robin %d picked %s", i, ep.Addr())
+		}
+	}
+}
+
+func TestBalanceLeastOutstanding(t *testing.T) {
+	lo := &LeastOutstandingBalancer{}
+	endpoints := testEndpoints(3, 1, 2)
+	if ep := lo.Pick(endpoints, ""); ep != endpoints[1] {
+		t.Fatalf("least outstanding picked %s", ep.Addr())
+	}
+
+	endpoints = testEndpoints(2, 1, 1)
+	if ep := lo.Pick(endpoints, ""); ep != endpoints[1] {
+		t.Fatalf("least outstanding tie picked %s", ep.Addr())
+	}
+
+	//the calls in progress move the picks
+	endpoints = testEndpoints(0, 0, 0)
+	for i := 0; i < 9; i++ {
+		ep := lo.Pick(endpoints, "")
+		ep._outstanding++
+	}
+
+	for _, ep := range endpoints {
+		if ep.Outstanding() != 3 {
+			t.Fatalf("least outstanding %s took %d of 9 calls", ep.Addr(), ep.Outstanding())
+		}
+	}
+}
+
+func TestBalanceP2C(t *testing.T) {
+	p2c := &P2CBalancer{_rand: rand.New(rand.NewSource(1))}
+	endpoints := testEndpoints(0)
+	if ep := p2c.Pick(endpoints, ""); ep != endpoints[0] {
+		t.Fatalf("p2c picked %s", ep.Addr())
+	}
+
+	endpoints = testEndpoints(5, 1)
+	for i := 0; i < 16; i++ {
+		if ep := p2c.Pick(endpoints, ""); ep != endpoints[1] {
+			t.Fatalf("p2c of two picked %s", ep.Addr())
+		}
+	}
+
+	//the idlest endpoint wins when it is a choice, 1 - 3/4 * 2/3 of the picks,
+	//the busiest never wins
+	endpoints = testEndpoints(0, 10, 20, 30)
+	picks := make(map[*Endpoint]int)
+	const n = 10000
+	for i := 0; i < n; i++ {
+		picks[p2c.Pick(endpoints, "")]++
+	}
+
+	if picks[endpoints[3]] != 0 {
+		t.Fatalf("p2c picked the busiest endpoint %d times", picks[endpoints[3]])
+	}
+
+	if picks[endpoints[0]] < n*45/100 || picks[endpoints[0]] > n*55/100 {
+		t.Fatalf("p2c picked the idlest endpoint %d of %d times", picks[endpoints[0]], n)
+	}
+
+	if picks[endpoints[0]] <= picks[endpoints[1]] || picks[endpoints[1]] <= picks[endpoints[2]] {
+		t.Fatalf("p2c picks %d %d %d not by the calls in progress",
+			picks[endpoints[0]], picks[endpoints[1]], picks[endpoints[2]])
+	}
+}
+
+func TestBalanceConsistentHash(t *testing.T) {
+	endpoints := testEndpoints(0, 0, 0)
+	ch := &ConsistentHashBalancer{}
+	moved := 0
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		ep := ch.Pick(endpoints, key)
+		if ch.Pick(endpoints, key) != ep {
+			t.Fatalf("consistent hash unstable on %s", key)
+		}
+
+		if ep == endpoints[2] {
+			continue
+		}
+
+		if ch.Pick(endpoints[:2], key) != ep {
+			moved++
+		}
+	}
+
+	if moved > 0 {
+		t.Fatalf("consistent hash moved %d keys of the live endpoints", moved)
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+func TestBreakerStates(t *testing.T) {
+	b := newCircuitBreaker(BreakerPolicy{FailureRatio: 0.5, MinRequests: 4, Window: 1000, Cooldown: 100})
+
+	//closed, the failures of an old window are dropped
+	for i := 0; i < 3; i++ {
+		if !b.allow(0) {
+			t.Fatal("closed breaker rejected")
+		}
+		b.record(0, true)
+	}
+
+	if !b.allow(1000) {
+		t.Fatal("closed breaker rejected")
+	}
+	b.record(1000, true)
+	if b.state(1000) != BreakerClosed {
+		t.Fatal("breaker opened by the failures of an old window")
+	}
+
+	//closed to open at the failure ratio of the min requests
+	b.allow(1000)
+	b.record(1000, false)
+	b.allow(1000)
+	b.record(1000, false)
+	if b.state(1000) != BreakerClosed {
+		t.Fatal("breaker opened below the failure ratio")
+	}
+
+	b.allow(1010)
+	b.record(1010, true)
+	if b.state(1010) != BreakerOpen || b.allow(1050) || b.passes(1050) {
+		t.Fatalf("breaker %s at the failure ratio", b.state(1010))
+	}
+
+	//open to half open after the cool-down, one probe passes
+	if b.state(1110) != BreakerHalfOpen || !b.passes(1110) || !b.allow(1110) {
+		t.Fatal("breaker not probing after the cool-down")
+	}
+
+	if b.allow(1110) || b.passes(1110) {
+		t.Fatal("breaker passed a call with the probe in progress")
+	}
+
+	//the probe not made is released
+	b.release()
+	if !b.passes(1110) || !b.allow(1110) {
+		t.Fatal("released probe not passed again")
+	}
+
+	//a failed probe opens again
+	b.record(1120, true)
+	if b.state(1120) != BreakerOpen || b.allow(1200) {
+		t.Fatalf("breaker %s after the failed probe", b.state(1120))
+	}
+
+	//a succeeded probe closes with a new window
+	if !b.allow(1220) {
+		t.Fatal("breaker not probing after the cool-down")
+	}
+
+	b.record(1230, false)
+	if b.state(1230) != BreakerClosed || !b.allow(1230) || b._requests != 0 || b._failures != 0 {
+		t.Fatalf("breaker %s after the succeeded probe", b.state(1230))
+	}
+}
+
+func TestBreakerFailure(t *testing.T) {
+	cases := map[error]bool{
+		nil:                      false,
+		context.Canceled:         false,
+		context.DeadlineExceeded: true,
+		code.ErrTimeOut:          true,
+		code.ErrConnectClosed:    true,
+		code.NewError(code.CodeInternal, "internal", nil):       true,
+		code.NewError(code.CodeUnavailable, "unavailable", nil): true,
+		code.NewError(code.CodeNoReturn, "no return", nil):      false,
+	}
+
+	for err, want := range cases {
+		if breakerFailure(err) != want {
+			t.Fatalf("%v failure %v", err, !want)
+		}
+	}
+}
+
+func TestBreakerPick(t *testing.T) {
+	p, called := testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		return nil, nil
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+
+	policy := BreakerPolicy{FailureRatio: 0.5, MinRequests: 4, Window: 1000 * 10, Cooldown: 1000 * 60}
+	probing, closed := p._endpoints[0], p._endpoints[1]
+	probing._breaker = newCircuitBreaker(policy)
+	closed._breaker = newCircuitBreaker(policy)
+
+	//the half open endpoint with the probe in progress is skipped
+	probing._breaker._state = BreakerHalfOpen
+	probing._breaker._probing = true
+	for i := 0; i < 4; i++ {
+		if _, _, err := p.invoke(context.Background(), "test.Get", nil, true, nil); err != nil {
+			t.Fatalf("call with a healthy endpoint:%v", err)
+		}
+	}
+
+	for _, ep := range called() {
+		if ep != closed {
+			t.Fatalf("call made on the probing endpoint %s", ep.Addr())
+		}
+	}
+
+	//all endpoints reject
+	closed._breaker._state = BreakerOpen
+	closed._breaker._opened = time.Now().UnixNano() / int64(time.Millisecond)
+	if _, err := p.waitPool(context.Background(), nil); err != code.ErrCircuitOpen {
+		t.Fatalf("call with all breakers rejecting:%v", err)
+	}
+
+	//the released probe passes and its success closes the breaker
+	probing.release()
+	if _, _, err := p.invoke(context.Background(), "test.Get", nil, true, nil); err != nil {
+		t.Fatalf("call of the probe:%v", err)
+	}
+
+	if endpoints := called(); endpoints[len(endpoints)-1] != probing || probing.Breaker() != BreakerClosed {
+		t.Fatalf("probing endpoint breaker %s", probing.Breaker())
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//testPool returns the pool of the endpoints connecting by dial, the backoff
+//is 10 seconds, cancel the pool to stop the reconnections
+func testPool(dial func(addr string) (int64, *RPCClient, error), addrs ...string) *RPCClientPool {
+	p := &RPCClientPool{_opts: defaultOptions, _balancer: &RoundRobinBalancer{}, _dial: dial}
+	p._opts.BackoffBase = 1000 * 10
+	p._ctx, p._cancel = context.WithCancel(context.Background())
+	for _, addr := range addrs {
+		p._endpoints = append(p._endpoints, newEndpoint(addr))
+	}
+	return p
+}
+
+//testClosed returns the pool of a ready endpoint whose only connection closed,
+//the reconnection dials are sent to dials and wait for the result
+func testClosed(t *testing.T, dials chan chan error) *RPCClientPool {
+	p := testPool(func(addr string) (int64, *RPCClient, error) {
+		result := make(chan error)
+		dials <- result
+		if err := <-result; err != nil {
+			return 0, nil, err
+		}
+		return 2, &RPCClient{}, nil
+	}, "127.0.0.1:8001")
+
+	ep := p._endpoints[0]
+	ep.succeed()
+	p._cs = []*rpcHandle{{_id: 1, _client: &RPCClient{_responseStop: make(chan bool)},
+		_endpoint: ep, _ref: 1, _status: constClientIdle}}
+	p._sz = 1
+	p.closePool(1)
+
+	if s := ep.State(); s != StateIdle && s != StateConnecting {
+		t.Fatalf("closed endpoint %s", s)
+	}
+	return p
+}
+
+//testState waits for the endpoint state
+func testState(t *testing.T, ep *Endpoint, state ConnectivityState) {
+	deadline := time.Now().Add(time.Second)
+	for ep.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("endpoint %s not %s", ep.State(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnectivityBackoff(t *testing.T) {
+	for fails, want := range map[int]int64{0: 1000, 1: 2000, 3: 8000, 6: 64000, 7: 120000, 40: 120000} {
+		for i := 0; i < 100; i++ {
+			if d := backoff(fails, 1000, 120000); d < want*8/10 || d > want*12/10 {
+				t.Fatalf("backoff after %d failures %d, want %d jittered", fails, d, want)
+			}
+		}
+	}
+
+	ep := newEndpoint("127.0.0.1:8001")
+	for i := 0; i < 3; i++ {
+		ep.fail(1000, 1000, 120000)
+	}
+
+	if ep.State() != StateTransientFailure || ep.available() || ep._fails != 3 ||
+		ep._retry < 1000+3200 || ep._retry > 1000+4800 {
+		t.Fatalf("failed endpoint %s fails %d retry %d", ep.State(), ep._fails, ep._retry)
+	}
+
+	ep.succeed()
+	if ep.State() != StateReady || !ep.available() || ep._fails != 0 || ep._retry != 0 {
+		t.Fatalf("connected endpoint %s fails %d retry %d", ep.State(), ep._fails, ep._retry)
+	}
+}
+
+func TestConnectivityClose(t *testing.T) {
+	dials := make(chan chan error)
+	p := testClosed(t, dials)
+	defer p._wait.Wait()
+	defer p._cancel()
+
+	//the close of an established connection reconnects at once, not after the backoff
+	var result chan error
+	select {
+	case result = <-dials:
+	case <-time.After(time.Second):
+		t.Fatal("closed endpoint not reconnected at once")
+	}
+
+	ep := p._endpoints[0]
+	if ep.State() != StateConnecting || p.endpointsDown() {
+		t.Fatalf("reconnecting endpoint %s", ep.State())
+	}
+
+	result <- nil
+	testState(t, ep, StateReady)
+
+	p._sync.Lock()
+	defer p._sync.Unlock()
+	if len(p._cs) != 2 || p._cs[1]._id != 2 || p._cs[1]._status != constClientIdle || p._cs[1]._endpoint != ep {
+		t.Fatal("reconnection not joined the pool idle")
+	}
+}
+
+func TestConnectivityRedialFailure(t *testing.T) {
+	dials := make(chan chan error)
+	p := testClosed(t, dials)
+	defer p._wait.Wait()
+	defer p._cancel()
+
+	var result chan error
+	select {
+	case result = <-dials:
+	case <-time.After(time.Second):
+		t.Fatal("closed endpoint not reconnected at once")
+	}
+
+	//only the failed dial is a transient failure, the next dial after the backoff
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	result <- errors.New("connection refused")
+	ep := p._endpoints[0]
+	testState(t, ep, StateTransientFailure)
+
+	p._sync.Lock()
+	fails, retry := ep._fails, ep._retry
+	p._sync.Unlock()
+	if fails != 1 || retry < now+8000 {
+		t.Fatalf("failed endpoint fails %d retry in %d", fails, retry-now)
+	}
+
+	if _, err := p.waitPool(context.Background(), nil); err != code.ErrEndpointDown {
+		t.Fatalf("call to the failed endpoint:%v", err)
+	}
+
+	select {
+	case <-dials:
+		t.Fatal("failed endpoint dialed before the backoff")
+	default:
+	}
+}
+
+func TestConnectivityDialFailure(t *testing.T) {
+	var dialed int32
+	p := testPool(func(addr string) (int64, *RPCClient, error) {
+		atomic.AddInt32(&dialed, 1)
+		return 0, nil, errors.New("connection refused")
+	}, "127.0.0.1:8001")
+	defer p._wait.Wait()
+	defer p._cancel()
+
+	ep := p._endpoints[0]
+	if _, err := p.waitPool(context.Background(), nil); err == nil || err == code.ErrEndpointDown {
+		t.Fatalf("call to the idle endpoint:%v", err)
+	}
+
+	if ep.State() != StateTransientFailure {
+		t.Fatalf("endpoint of the failed dial %s", ep.State())
+	}
+
+	//the calls fail fast without dialing until the backoff
+	for i := 0; i < 3; i++ {
+		if _, err := p.waitPool(context.Background(), nil); err != code.ErrEndpointDown {
+			t.Fatalf("call to the failed endpoint:%v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&dialed); n != 1 {
+		t.Fatalf("failed endpoint dialed %d times", n)
+	}
+}
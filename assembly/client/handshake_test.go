@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testHandshakeMessage param of the handshake tests
+type testHandshakeMessage struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (m *testHandshakeMessage) Reset()         { *m = testHandshakeMessage{} }
+func (m *testHandshakeMessage) String() string { return proto.CompactTextString(m) }
+func (*testHandshakeMessage) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*testHandshakeMessage)(nil), "client.testHandshakeMessage")
+}
+
+//testBaselineRequest decodes the request frame as a server without negotiation,
+//version 1 frames of the registered param data names, anything else closes the connection
+func testBaselineRequest(t *testing.T, data []byte) *testHandshakeMessage {
+	blk, err := common.Decode(rpctest.NewBuffer(data), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blk.Ver != common.ConstVersion || blk.Compress != 0 || blk.Oper != common.RPCRequest {
+		t.Fatalf("frame version %d compress %d", blk.Ver, blk.Compress)
+	}
+
+	if blk.DataName != "client.testHandshakeMessage" {
+		t.Fatalf("data name %s undefined closes the connection", blk.DataName)
+	}
+
+	msg := &testHandshakeMessage{}
+	if err := proto.Unmarshal(blk.Data, msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestHandshakeBaselineServer(t *testing.T) {
+	//the server without negotiation sends the handshake code only
+	c := &RPCClient{}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeCode})); err != nil {
+		t.Fatal(err)
+	}
+
+	if negotiate, authenticate := c.handshaking(); !negotiate || authenticate ||
+		atomic.LoadInt64(&c._helloWait) == 0 {
+		t.Fatal("offer not waited after the handshake code")
+	}
+
+	c.settleLegacy()
+	if negotiate, _ := c.handshaking(); negotiate || atomic.LoadInt64(&c._helloWait) != 0 ||
+		c.Version() != common.ConstVersion || c.Features() != common.ConstLegacyFeatures {
+		t.Fatalf("settled version %d features %x", c.Version(), c.Features())
+	}
+
+	//an offer after the settlement is dropped
+	c.negotiate(&common.HelloEvent{MinVersion: common.ConstVersion, MaxVersion: common.ConstVersionMax,
+		Features: common.ConstFeatures})
+	if c.Version() != common.ConstVersion || c.Features() != common.ConstLegacyFeatures {
+		t.Fatalf("late offer settled version %d features %x", c.Version(), c.Features())
+	}
+
+	//a server call before any offer settles the server without negotiation
+	c = &RPCClient{}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeCode})); err != nil {
+		t.Fatal(err)
+	}
+
+	c.awaitHello(&common.HelloEvent{})
+	if negotiate, _ := c.handshaking(); !negotiate {
+		t.Fatal("offer settled the server without negotiation")
+	}
+
+	c.awaitHello(&common.RequestEvent{MethodName: "test.Call", Ver: common.ConstVersion})
+	if negotiate, _ := c.handshaking(); negotiate || c.Features() != common.ConstLegacyFeatures {
+		t.Fatal("server call did not settle the server without negotiation")
+	}
+}
+
+func TestHandshakeAuthServer(t *testing.T) {
+	c := &RPCClient{_authenticator: &common.TokenAuth{Identity: "test", Token: "token"}}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeAuthCode})); err != nil {
+		t.Fatal(err)
+	}
+
+	if negotiate, authenticate := c.handshaking(); !negotiate || !authenticate ||
+		atomic.LoadInt64(&c._helloWait) != 0 {
+		t.Fatal("authentication server waited as a server without negotiation")
+	}
+
+	c.awaitHello(&common.AuthEvent{Name: "token", Oper: common.RPCRequest})
+	if negotiate, _ := c.handshaking(); !negotiate {
+		t.Fatal("challenge settled the server without negotiation")
+	}
+}
+
+func TestHandshakeBaselineRequest(t *testing.T) {
+	c := &RPCClient{_timeOut: 1000}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeCode})); err != nil {
+		t.Fatal(err)
+	}
+	c.settleLegacy()
+
+	//the deadline and the metadata do not travel to the server without negotiation
+	ctx, cancel := context.WithTimeout(common.WithMetadata(context.Background(),
+		common.NewMetadata("trace", "1")), time.Second)
+	defer cancel()
+	data, err := c.request(ctx, "test.Call", 1, &testHandshakeMessage{Name: "baseline"}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := testBaselineRequest(t, data); msg.Name != "baseline" {
+		t.Fatalf("request param %+v", msg)
+	}
+
+	if c.canceller() != nil {
+		t.Fatal("cancel request sent to the server without negotiation")
+	}
+
+	//the negotiated server gets the extended request
+	c = &RPCClient{_timeOut: 1000}
+	c.withNegotiated(common.ConstVersion, common.ConstFeatures)
+	if data, err = c.request(ctx, "test.Call", 1, &testHandshakeMessage{Name: "negotiated"}, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if blk, err := common.Decode(rpctest.NewBuffer(data), 0); err != nil || blk.DataName != common.ConstExtendName {
+		t.Fatalf("negotiated request data name %s:%v", blk.DataName, err)
+	}
+
+	if c.canceller() == nil {
+		t.Fatal("cancel request not sent to the negotiated server")
+	}
+
+	//the client codec fails the handshake of the server without negotiation
+	c = &RPCClient{_codec: common.GetCodec(common.ConstCodecJSON), _responseStop: make(chan bool)}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeCode})); err != nil {
+		t.Fatal(err)
+	}
+
+	c.settleLegacy()
+	if c.handshakeError() == nil || atomic.LoadUint64(&c._auth) != 0 {
+		t.Fatal("codec client settled the server without negotiation")
+	}
+}
+
+func TestHandshakeSettledRace(t *testing.T) {
+	//the callers read the settled state while the decoding goroutine settles it
+	c := &RPCClient{_timeOut: 1000}
+	if err := c.greeting(rpctest.NewBuffer([]byte{common.ConstHandShakeCode})); err != nil {
+		t.Fatal(err)
+	}
+
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := c.request(context.Background(), "test.Call", 1, &testHandshakeMessage{Name: "race"}, 1000); err != nil {
+					t.Error(err)
+					return
+				}
+				c.canceller()
+				c.compression()
+				atomic.LoadUint64(&c._auth)
+			}
+		}()
+	}
+
+	c.settleLegacy()
+	wait.Wait()
+	if c.Version() != common.ConstVersion || c.Features() != common.ConstLegacyFeatures ||
+		c.handshakeError() != nil {
+		t.Fatalf("settled version %d features %x:%v", c.Version(), c.Features(), c.handshakeError())
+	}
+}
+
+func TestHandshakeMetadata(t *testing.T) {
+	//the outgoing metadata travels only to the server settling the metadata feature
+	ctx := common.WithMetadata(context.Background(), common.NewMetadata("trace", "1"))
+	c := &RPCClient{_timeOut: 1000}
+	c.withNegotiated(common.ConstVersion, common.ConstFeatures)
+	if md := c.outgoing(ctx); md.Get("trace") != "1" {
+		t.Fatalf("negotiated outgoing metadata %+v", md)
+	}
+
+	c.withNegotiated(common.ConstVersion, common.ConstFeatures&^common.FeatureMetadata)
+	if md := c.outgoing(ctx); md != nil {
+		t.Fatalf("outgoing metadata without the feature %+v", md)
+	}
+
+	c.withNegotiated(common.ConstVersion, common.ConstLegacyFeatures)
+	if md := c.outgoing(ctx); md != nil {
+		t.Fatalf("outgoing metadata of the server without negotiation %+v", md)
+	}
+}
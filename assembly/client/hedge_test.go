@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+func TestHedgeFirstSuccess(t *testing.T) {
+	var n int32
+	cancelled := make(chan error, 1)
+	p, called := testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+
+	//the hedged copy wins and the first copy is cancelled
+	if _, err := p.invokeHedged(context.Background(), "test.Get", nil, &HedgePolicy{MaxAttempts: 3, Delay: 20}); err != nil {
+		t.Fatalf("hedged call:%v", err)
+	}
+
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Fatalf("losing copy done by %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("losing copy not cancelled")
+	}
+
+	if endpoints := called(); len(endpoints) != 2 || endpoints[0] == endpoints[1] {
+		t.Fatalf("hedged call sent %d copies", len(endpoints))
+	}
+
+	if stats := p.Stats(); stats.Hedged != 1 || stats.HedgeWins != 1 || stats.Resent != 0 {
+		t.Fatalf("hedged %d won %d resent %d", stats.Hedged, stats.HedgeWins, stats.Resent)
+	}
+
+	//the first copy winning before the delay sends no hedged copy
+	p, called = testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		return nil, nil
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+	if _, err := p.invokeHedged(context.Background(), "test.Get", nil, &HedgePolicy{MaxAttempts: 3, Delay: 1000}); err != nil {
+		t.Fatalf("hedged call:%v", err)
+	}
+
+	if stats := p.Stats(); len(called()) != 1 || stats.Hedged != 0 || stats.HedgeWins != 0 {
+		t.Fatalf("first copy won with %d copies hedged %d", len(called()), stats.Hedged)
+	}
+}
+
+func TestHedgeResend(t *testing.T) {
+	var n int32
+	p, called := testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			return nil, code.ErrConnectClosed
+		}
+		return nil, nil
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+
+	//the connection error re-sends at once, not counted hedged
+	start := time.Now()
+	if _, err := p.invokeHedged(context.Background(), "test.Get", nil, &HedgePolicy{MaxAttempts: 3, Delay: 1000}); err != nil {
+		t.Fatalf("re-sent call:%v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("re-sent after %v", elapsed)
+	}
+
+	if endpoints := called(); len(endpoints) != 2 || endpoints[0] == endpoints[1] {
+		t.Fatal("re-sent to the failed endpoint")
+	}
+
+	if stats := p.Stats(); stats.Hedged != 0 || stats.HedgeWins != 0 || stats.Resent != 1 {
+		t.Fatalf("hedged %d won %d resent %d", stats.Hedged, stats.HedgeWins, stats.Resent)
+	}
+
+	//the method error returns at once
+	merr := code.NewError(code.CodeNoReturn, "no return", nil)
+	p, called = testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		return nil, merr
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+	if _, err := p.invokeHedged(context.Background(), "test.Get", nil, &HedgePolicy{MaxAttempts: 3, Delay: 1000}); err != merr {
+		t.Fatalf("method error call:%v", err)
+	}
+
+	if stats := p.Stats(); len(called()) != 1 || stats.Resent != 0 {
+		t.Fatalf("method error sent %d copies", len(called()))
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//testCall call of the connection endpoint, the reply and the error
+type testCall func(ctx context.Context, ep *Endpoint) (proto.Message, error)
+
+//testCalls returns the pool of the ready endpoints of one idle connection each,
+//the calls are made by call and the endpoints called are returned in order,
+//the further connections are dialed without a network
+func testCalls(call testCall, addrs ...string) (*RPCClientPool, func() []*Endpoint) {
+	var p *RPCClientPool
+	p = testPool(func(addr string) (int64, *RPCClient, error) {
+		return atomic.AddInt64(&p._ids, 1), &RPCClient{}, nil
+	}, addrs...)
+	for i, ep := range p._endpoints {
+		ep.succeed()
+		p._ids = int64(i + 1)
+		p._cs = append(p._cs, &rpcHandle{_id: p._ids, _endpoint: ep, _ref: 1, _status: constClientIdle})
+		p._sz++
+	}
+
+	var called []*Endpoint
+	var calledSync sync.Mutex
+	p._call = func(ctx context.Context, h *rpcHandle, method string, param proto.Message, wait bool) (proto.Message, error) {
+		calledSync.Lock()
+		called = append(called, h._endpoint)
+		calledSync.Unlock()
+
+		r, err := call(ctx, h._endpoint)
+		h._endpoint.record(err)
+
+		atomic.AddInt64(&h._endpoint._outstanding, -1)
+		p._sync.Lock()
+		h._ref--
+		h._status = constClientIdle
+		p._sync.Unlock()
+		return r, err
+	}
+
+	return p, func() []*Endpoint {
+		calledSync.Lock()
+		defer calledSync.Unlock()
+		return append([]*Endpoint(nil), called...)
+	}
+}
+
+//testFail call failing by err
+func testFail(err error) testCall {
+	return func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		return nil, err
+	}
+}
+
+func TestRetryRetriable(t *testing.T) {
+	policy := &RetryPolicy{Codes: []int32{code.CodeUnavailable, code.CodeNoReturn}}
+	cases := map[error]bool{
+		code.ErrConnectClosed:                                   true,
+		code.ErrEndpointDown:                                    true,
+		code.NewError(code.CodeNoReturn, "no return", nil):      true,
+		code.NewError(code.CodeUnavailable, "unavailable", nil): true,
+		code.ErrCircuitOpen:                                     false,
+		code.ErrTimeOut:                                         false,
+		context.Canceled:                                        false,
+		context.DeadlineExceeded:                                false,
+	}
+
+	for err, want := range cases {
+		if policy.retriable(err) != want {
+			t.Fatalf("%v retriable %v", err, !want)
+		}
+	}
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	p, called := testCalls(testFail(code.ErrConnectClosed), "127.0.0.1:8001", "127.0.0.1:8002")
+	p._opts.Idempotent = map[string]bool{"test.Get": true}
+
+	if _, err := p.invokeRetry(context.Background(), "test.Set", nil, true); err != code.ErrConnectClosed {
+		t.Fatalf("not idempotent call:%v", err)
+	}
+
+	if n := len(called()); n != 1 {
+		t.Fatalf("not idempotent call made %d times", n)
+	}
+
+	if _, err := p.invokeRetry(context.Background(), "test.Get", nil, true); err != code.ErrConnectClosed {
+		t.Fatalf("idempotent call:%v", err)
+	}
+
+	if n := len(called()); n != 1+DefaultRetryPolicy.MaxAttempts {
+		t.Fatalf("idempotent call made %d times", n-1)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded, code.ErrTimeOut} {
+		p, called := testCalls(testFail(err), "127.0.0.1:8001", "127.0.0.1:8002")
+		p._opts.Idempotent = map[string]bool{"test.Get": true}
+		if _, e := p.invokeRetry(context.Background(), "test.Get", nil, true); e != err {
+			t.Fatalf("call:%v", e)
+		}
+
+		if n := len(called()); n != 1 {
+			t.Fatalf("call of %v made %d times", err, n)
+		}
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	p, called := testCalls(testFail(code.ErrConnectClosed), "127.0.0.1:8001", "127.0.0.1:8002")
+	p._opts.Idempotent = map[string]bool{"test.Get": true}
+	p._opts.Retry = map[string]RetryPolicy{"test.Get": {MaxAttempts: 5, Backoff: 1, BackoffMax: 1,
+		Codes: []int32{code.CodeUnavailable}}}
+
+	if _, err := p.invokeRetry(context.Background(), "test.Get", nil, true); err != code.ErrConnectClosed {
+		t.Fatalf("call:%v", err)
+	}
+
+	if n := len(called()); n != 5 {
+		t.Fatalf("call made %d of 5 attempts", n)
+	}
+
+	//the deadline stops the attempts before the max, the last attempt may start at the deadline
+	p, called = testCalls(testFail(code.ErrConnectClosed), "127.0.0.1:8001", "127.0.0.1:8002")
+	p._opts.Idempotent = map[string]bool{"test.Get": true}
+	p._opts.Retry = map[string]RetryPolicy{"": {MaxAttempts: 100, Backoff: 20, BackoffMax: 20,
+		Codes: []int32{code.CodeUnavailable}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.invokeRetry(ctx, "test.Get", nil, true); err != code.ErrConnectClosed && err != context.DeadlineExceeded {
+		t.Fatalf("call:%v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("attempts took %v after the deadline", elapsed)
+	}
+
+	if n := len(called()); n < 2 || n > 10 {
+		t.Fatalf("call made %d attempts in the deadline", n)
+	}
+}
+
+func TestRetryEndpoint(t *testing.T) {
+	p, called := testCalls(testFail(code.ErrConnectClosed), "127.0.0.1:8001", "127.0.0.1:8002", "127.0.0.1:8003")
+	p._balancer = &ConsistentHashBalancer{}
+	p._opts.Idempotent = map[string]bool{"test.Get": true}
+	p._opts.Retry = map[string]RetryPolicy{"": {MaxAttempts: 4, Backoff: 1, BackoffMax: 1,
+		Codes: []int32{code.CodeUnavailable}}}
+
+	ctx := WithBalanceKey(context.Background(), "user-1")
+	if _, err := p.invokeRetry(ctx, "test.Get", nil, true); err != code.ErrConnectClosed {
+		t.Fatalf("call:%v", err)
+	}
+
+	//each attempt is made on another endpoint until all are tried
+	endpoints := called()
+	if len(endpoints) != 4 || endpoints[0] != p._balancer.Pick(p._endpoints, "user-1") {
+		t.Fatalf("call made %d attempts", len(endpoints))
+	}
+
+	for i := 1; i < 3; i++ {
+		for _, ep := range endpoints[:i] {
+			if endpoints[i] == ep {
+				t.Fatalf("attempt %d made on the tried endpoint %s", i+1, ep.Addr())
+			}
+		}
+	}
+
+	//the retry of a method error is made on another endpoint too
+	var n int32
+	p, called = testCalls(func(ctx context.Context, ep *Endpoint) (proto.Message, error) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			return nil, code.NewError(code.CodeUnavailable, "unavailable", nil)
+		}
+		return nil, nil
+	}, "127.0.0.1:8001", "127.0.0.1:8002")
+	p._balancer = &ConsistentHashBalancer{}
+	p._opts.Idempotent = map[string]bool{"test.Get": true}
+
+	if _, err := p.invokeRetry(ctx, "test.Get", nil, true); err != nil {
+		t.Fatalf("call:%v", err)
+	}
+
+	if endpoints = called(); len(endpoints) != 2 || endpoints[0] == endpoints[1] {
+		t.Fatal("retry made on the failed endpoint")
+	}
+}
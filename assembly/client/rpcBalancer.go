@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type balanceKey struct{}
+
+//WithBalanceKey doc
+//@Summary Returns a context of the calls balanced by the key, the consistent
+//@Summary hash balancer sends the calls of the same key to the same endpoint
+//@Param  context.Context parent
+//@Param  string          request key
+//@Return context.Context
+func WithBalanceKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, balanceKey{}, key)
+}
+
+func balanceKeyOf(ctx context.Context) string {
+	key, _ := ctx.Value(balanceKey{}).(string)
+	return key
+}
+
+//Endpoint doc
+//@Summary Server address of the pool connections
+//@Member string address
+//@Member int64  calls and streams in progress
+//@Member int    dial failures in a row
+//@Member int64  next reconnection/millsecond
+//@Member int    connections in progress
+//@Member int32  connectivity state
+//@Member bool   reconnecting in background
+//@Member *circuitBreaker circuit breaker, nil none
+type Endpoint struct {
+	_addr         string
+	_outstanding  int64
+	_fails        int
+	_retry        int64
+	_dialing      int
+	_state        int32
+	_reconnecting bool
+	_breaker      *circuitBreaker
+}
+
+func newEndpoint(addr string) *Endpoint {
+	return &Endpoint{_addr: addr}
+}
+
+//Addr doc
+//@Summary Returns the endpoint address
+//@Return string
+func (slf *Endpoint) Addr() string {
+	return slf._addr
+}
+
+//Outstanding doc
+//@Summary Returns the calls and the streams in progress of the endpoint
+//@Return int64
+func (slf *Endpoint) Outstanding() int64 {
+	return atomic.LoadInt64(&slf._outstanding)
+}
+
+//State doc
+//@Summary Returns the connectivity state of the endpoint
+//@Return ConnectivityState
+func (slf *Endpoint) State() ConnectivityState {
+	return ConnectivityState(atomic.LoadInt32(&slf._state))
+}
+
+//Breaker doc
+//@Summary Returns the circuit breaker state of the endpoint, closed when no breaker
+//@Return BreakerState
+func (slf *Endpoint) Breaker() BreakerState {
+	if slf._breaker == nil {
+		return BreakerClosed
+	}
+	return slf._breaker.state(time.Now().UnixNano() / int64(time.Millisecond))
+}
+
+//allow doc
+//@Summary Returns whether the breaker passes a call
+func (slf *Endpoint) allow() bool {
+	return slf._breaker == nil || slf._breaker.allow(time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+//passes doc
+//@Summary Returns whether the breaker would pass a call, the probe in progress rejects
+func (slf *Endpoint) passes() bool {
+	return slf._breaker == nil || slf._breaker.passes(time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+//release doc
+//@Summary Release the breaker of a passed call not made
+func (slf *Endpoint) release() {
+	if slf._breaker != nil {
+		slf._breaker.release()
+	}
+}
+
+//record doc
+//@Summary Count the call result by the breaker
+func (slf *Endpoint) record(err error) {
+	if slf._breaker != nil {
+		slf._breaker.record(time.Now().UnixNano()/int64(time.Millisecond), breakerFailure(err))
+	}
+}
+
+func (slf *Endpoint) setState(state ConnectivityState) {
+	atomic.StoreInt32(&slf._state, int32(state))
+}
+
+//fail doc
+//@Summary Mark the endpoint in transient failure, the next reconnection is after the backoff
+func (slf *Endpoint) fail(now int64, base, max int64) {
+	slf._retry = now + backoff(slf._fails, base, max)
+	slf._fails++
+	slf.setState(StateTransientFailure)
+}
+
+func (slf *Endpoint) succeed() {
+	slf._fails = 0
+	slf._retry = 0
+	slf.setState(StateReady)
+}
+
+func (slf *Endpoint) available() bool {
+	return slf.State() != StateTransientFailure
+}
+
+//Balancer doc
+//@Summary Endpoint policy of the pool calls, the endpoints of the failing
+//@Summary connections are skipped before picking
+//@Method Pick returns one of the endpoints, never empty, for the request key,
+//@Method      empty no key
+type Balancer interface {
+	Pick(endpoints []*Endpoint, key string) *Endpoint
+}
+
+//RoundRobinBalancer doc
+//@Summary Picks the endpoints in turn, the default
+type RoundRobinBalancer struct {
+	_next uint32
+}
+
+//Pick doc
+//@Summary Returns the next endpoint
+//@Param  []*Endpoint
+//@Param  string request key
+//@Return *Endpoint
+func (slf *RoundRobinBalancer) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	n := atomic.AddUint32(&slf._next, 1) - 1
+	return endpoints[n%uint32(len(endpoints))]
+}
+
+//LeastOutstandingBalancer doc
+//@Summary Picks the endpoint of the fewest calls in progress
+type LeastOutstandingBalancer struct {
+}
+
+//Pick doc
+//@Summary Returns the endpoint of the fewest calls in progress, the first of the ties
+//@Param  []*Endpoint
+//@Param  string request key
+//@Return *Endpoint
+func (slf *LeastOutstandingBalancer) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	result := endpoints[0]
+	for _, v := range endpoints[1:] {
+		if v.Outstanding() < result.Outstanding() {
+			result = v
+		}
+	}
+	return result
+}
+
+//P2CBalancer doc
+//@Summary Power of two choices, picks the less busy of two random endpoints
+type P2CBalancer struct {
+	_rand *rand.Rand
+	_sync sync.Mutex
+}
+
+//Pick doc
+//@Summary Returns the endpoint of fewer calls in progress of two random endpoints
+//@Param  []*Endpoint
+//@Param  string request key
+//@Return *Endpoint
+func (slf *P2CBalancer) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	if len(endpoints) == 1 {
+		return endpoints[0]
+	}
+
+	slf._sync.Lock()
+	if slf._rand == nil {
+		slf._rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	a := slf._rand.Intn(len(endpoints))
+	b := slf._rand.Intn(len(endpoints) - 1)
+	slf._sync.Unlock()
+
+	if b >= a {
+		b++
+	}
+
+	if endpoints[b].Outstanding() < endpoints[a].Outstanding() {
+		return endpoints[b]
+	}
+	return endpoints[a]
+}
+
+//ConsistentHashBalancer doc
+//@Summary Rendezvous hashing of the request key, the calls of a key stay on
+//@Summary one endpoint and only the keys of a failing endpoint move,
+//@Summary the calls without a key are picked in turn
+type ConsistentHashBalancer struct {
+	_next RoundRobinBalancer
+}
+
+//Pick doc
+//@Summary Returns the endpoint of the highest hash weight of the key
+//@Param  []*Endpoint
+//@Param  string request key
+//@Return *Endpoint
+func (slf *ConsistentHashBalancer) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	if key == "" {
+		return slf._next.Pick(endpoints, key)
+	}
+
+	var result *Endpoint
+	var weight uint64
+	for _, v := range endpoints {
+		h := fnv.New64a()
+		h.Write([]byte(v._addr))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if w := mix64(h.Sum64()); result == nil || w > weight {
+			result = v
+			weight = w
+		}
+	}
+	return result
+}
+
+//mix64 doc
+//@Summary 64 bit finalizer, spreads the fnv hash of similar keys
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//BreakerState doc
+//@Summary Circuit breaker state of an endpoint
+type BreakerState int32
+
+const (
+	//BreakerClosed the calls pass, the failures are counted
+	BreakerClosed BreakerState = iota
+	//BreakerOpen the calls fail fast with code.ErrCircuitOpen until the cool-down
+	BreakerOpen
+	//BreakerHalfOpen one probe call passes, its result closes or opens the breaker
+	BreakerHalfOpen
+)
+
+//String doc
+//@Summary Returns the state name
+//@Return string
+func (slf BreakerState) String() string {
+	switch slf {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	}
+	return "UNKNOWN"
+}
+
+//BreakerPolicy doc
+//@Summary Circuit breaker policy of the endpoints
+//@Member float64 failure ratio of the window opening the breaker, (0, 1]
+//@Member int     calls of the window before the ratio applies
+//@Member int64   counting window/millsecond
+//@Member int64   open time before the probe/millsecond
+type BreakerPolicy struct {
+	FailureRatio float64
+	MinRequests  int
+	Window       int64
+	Cooldown     int64
+}
+
+//DefaultBreakerPolicy opens at half of 20 calls failing in 10 seconds for 5 seconds
+var DefaultBreakerPolicy = BreakerPolicy{FailureRatio: 0.5, MinRequests: 20, Window: 1000 * 10, Cooldown: 1000 * 5}
+
+//circuitBreaker doc
+//@Summary Circuit breaker of an endpoint
+//@Member BreakerPolicy
+//@Member BreakerState
+//@Member int   calls of the window
+//@Member int   failures of the window
+//@Member int64 window start/millsecond
+//@Member int64 opened at/millsecond
+//@Member bool  half open probe in progress
+type circuitBreaker struct {
+	_policy   BreakerPolicy
+	_state    BreakerState
+	_requests int
+	_failures int
+	_window   int64
+	_opened   int64
+	_probing  bool
+	_sync     sync.Mutex
+}
+
+func newCircuitBreaker(policy BreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{_policy: policy}
+}
+
+//state doc
+//@Summary Returns the breaker state, open turns half open after the cool-down
+func (slf *circuitBreaker) state(now int64) BreakerState {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if slf._state == BreakerOpen && now-slf._opened >= slf._policy.Cooldown {
+		return BreakerHalfOpen
+	}
+	return slf._state
+}
+
+//allow doc
+//@Summary Returns whether a call passes, the first call after the cool-down is the probe
+func (slf *circuitBreaker) allow(now int64) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	switch slf._state {
+	case BreakerOpen:
+		if now-slf._opened < slf._policy.Cooldown {
+			return false
+		}
+		slf._state = BreakerHalfOpen
+		slf._probing = true
+		return true
+	case BreakerHalfOpen:
+		if slf._probing {
+			return false
+		}
+		slf._probing = true
+		return true
+	}
+
+	if now-slf._window >= slf._policy.Window {
+		slf._window = now
+		slf._requests = 0
+		slf._failures = 0
+	}
+	return true
+}
+
+//passes doc
+//@Summary Returns whether a call would pass, without taking the probe
+func (slf *circuitBreaker) passes(now int64) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	switch slf._state {
+	case BreakerOpen:
+		return now-slf._opened >= slf._policy.Cooldown
+	case BreakerHalfOpen:
+		return !slf._probing
+	}
+	return true
+}
+
+//release doc
+//@Summary Release the probe of a call not made
+func (slf *circuitBreaker) release() {
+	slf._sync.Lock()
+	slf._probing = false
+	slf._sync.Unlock()
+}
+
+//record doc
+//@Summary Count the result of a passed call
+func (slf *circuitBreaker) record(now int64, failed bool) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	switch slf._state {
+	case BreakerHalfOpen:
+		slf._probing = false
+		if failed {
+			slf._state = BreakerOpen
+			slf._opened = now
+			return
+		}
+		slf._state = BreakerClosed
+		slf._window = now
+		slf._requests = 0
+		slf._failures = 0
+		return
+	case BreakerOpen:
+		return
+	}
+
+	slf._requests++
+	if failed {
+		slf._failures++
+	}
+
+	if slf._requests >= slf._policy.MinRequests &&
+		float64(slf._failures) >= slf._policy.FailureRatio*float64(slf._requests) {
+		slf._state = BreakerOpen
+		slf._opened = now
+	}
+}
+
+//breakerFailure doc
+//@Summary Returns whether the error is a failure of the endpoint, the connection errors,
+//@Summary the time outs and the internal errors, the method errors are not
+func breakerFailure(err error) bool {
+	if err == nil || err == context.Canceled {
+		return false
+	}
+
+	if err == context.DeadlineExceeded || err == code.ErrTimeOut || unavailable(err) {
+		return true
+	}
+
+	if e, ok := err.(*code.RPCError); ok {
+		return e.Code == code.CodeInternal || e.Code == code.CodeUnavailable || e.Code == code.CodeTimeOut
+	}
+	return false
+}
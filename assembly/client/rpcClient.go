@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,21 +24,47 @@ const (
 	constClientDel  = 2
 )
 
+//constHelloWait time the offer is waited after the handshake code/millsecond,
+//a server sending none is a server without negotiation
+const constHelloWait = 200
+
 //RPCClient doc
+//@Summary RPC client connection, the handshake settles on the decoding goroutine while
+//@Summary the callers read the settled version, features and compressor
 type RPCClient struct {
 	connector.NetConnector
+	common.RPCContexts
+	common.RPCStreams
 	_parent             *RPCClientPool
 	_auth               uint64
+	_ver                int32
+	_minVer             int
+	_maxVer             int
+	_features           uint32
+	_negotiate          bool
+	_authenticate       bool
+	_settled            int32
+	_helloWait          int64
 	_connTimeout        int64
+	_maxFrame           int
 	_timeOut            int64
 	_idletime           int64
 	_serial             uint32
-	_response           chan *common.ResponseEvent
+	_pending            map[uint32]chan *common.ResponseEvent
+	_pendingSync        sync.Mutex
 	_responseStop       chan bool
 	_responseStopClosed int32
-	_responseWait       uint32
 	_isClosed           int32
 	_closeWait          sync.WaitGroup
+	_tls                *common.TLSConn
+	_authenticator      common.Authenticator
+	_handshakeErr       error
+	_compressors        []string
+	_compressor         common.Compressor
+	_threshold          int
+	_codec              common.Codec
+	_tags               map[string]string
+	_sync               sync.Mutex
 }
 
 //Initial doc
@@ -44,9 +73,11 @@ type RPCClient struct {
 func (slf *RPCClient) Initial() {
 	slf._isClosed = 0
 	slf._responseStopClosed = 0
-	slf._response = make(chan *common.ResponseEvent)
+	slf._pending = make(map[uint32]chan *common.ResponseEvent)
 	slf._responseStop = make(chan bool)
 	slf.NetConnector.Initial()
+	slf.InitialContexts()
+	slf.InitialStreams()
 	slf.RegisterMethod(&common.RequestEvent{}, slf.onRequest)
 	slf.RegisterMethod(&common.ResponseEvent{}, slf.onResponse)
 }
@@ -62,13 +93,25 @@ func (slf *RPCClient) closeStop() {
 func (slf *RPCClient) Shutdown() {
 	if atomic.CompareAndSwapInt32(&slf._isClosed, 0, 1) {
 		slf.closeStop()
+		slf.ShutdownContexts()
+		slf.ShutdownStreams()
+		if slf._tls != nil {
+			slf._tls.Close()
+		}
 		slf._closeWait.Wait()
-		close(slf._response)
+		slf._pendingSync.Lock()
+		slf._pending = make(map[uint32]chan *common.ResponseEvent)
+		slf._pendingSync.Unlock()
 		slf.NetConnector.Shutdown()
 		slf._parent = nil
 		slf._serial = 0
-		slf._responseWait = 0
-		slf._auth = 0
+		atomic.StoreUint64(&slf._auth, 0)
+		slf.withNegotiated(0, 0)
+		atomic.StoreInt32(&slf._settled, 0)
+		atomic.StoreInt64(&slf._helloWait, 0)
+		slf._sync.Lock()
+		slf._compressor = nil
+		slf._sync.Unlock()
 	}
 }
 
@@ -83,12 +126,32 @@ func (slf *RPCClient) Connection(addr string) error {
 		return err
 	}
 
+	if slf._tls != nil {
+		slf._tls.Start()
+	}
+
 	ick := 0
 	for {
-		if slf._auth > 0 {
+		if atomic.LoadUint64(&slf._auth) > 0 {
 			return nil
 		}
 
+		if slf._tls != nil {
+			if err := slf._tls.Err(); err != nil {
+				slf.Shutdown()
+				return err
+			}
+		}
+
+		if err := slf.handshakeError(); err != nil {
+			slf.Shutdown()
+			return err
+		}
+
+		if wait := atomic.LoadInt64(&slf._helloWait); wait > 0 && time.Now().UnixNano() > wait {
+			slf.settleLegacy()
+		}
+
 		if slf._connTimeout > 0 {
 			currTime := time.Now().UnixNano() - startTime
 			if (currTime / int64(time.Millisecond)) > slf._connTimeout {
@@ -106,13 +169,64 @@ func (slf *RPCClient) Connection(addr string) error {
 	}
 }
 
+//SendTo doc
+//@Summary Send data to the server, compressed when settled, over the tls session when TLS
+//@Param   []byte data
+//@Return  error
+func (slf *RPCClient) SendTo(data []byte) error {
+	if compressor, threshold := slf.compression(); compressor != nil {
+		var err error
+		if data, err = common.CompressFrame(data, compressor, threshold); err != nil {
+			return err
+		}
+	}
+
+	if slf._tls != nil {
+		return slf._tls.SendTo(data)
+	}
+	return slf.NetConnector.SendTo(data)
+}
+
+//TLSState doc
+//@Summary Returns the TLS connection state, false non-TLS or handshake not complete
+//@Return tls.ConnectionState
+//@Return bool
+func (slf *RPCClient) TLSState() (tls.ConnectionState, bool) {
+	if slf._tls == nil {
+		return tls.ConnectionState{}, false
+	}
+	return slf._tls.ConnectionState()
+}
+
 //Call doc
 //@Summary Call remote function
 //@Param   string  			method
 //@Param   interface{}  	param
 //@Return  error
 func (slf *RPCClient) Call(method string, param proto.Message) error {
-	data, err := common.Call(method, param)
+	return slf.CallContext(context.Background(), method, param)
+}
+
+//CallContext doc
+//@Summary Call remote function, the context deadline and metadata travel with the request
+//@Param   context.Context  context
+//@Param   string  			method
+//@Param   interface{}  	param
+//@Return  error
+func (slf *RPCClient) CallContext(ctx context.Context, method string, param proto.Message) error {
+	var timeout int64
+	if dl, ok := ctx.Deadline(); ok {
+		timeout = int64(time.Until(dl) / time.Millisecond)
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := slf.request(ctx, method, 0, param, timeout)
 	if err != nil {
 		return err
 	}
@@ -120,48 +234,212 @@ func (slf *RPCClient) Call(method string, param proto.Message) error {
 }
 
 //CallReturn doc
-//@Summary Call remote function wait return
+//@Summary Call remote function wait return, safe for concurrent use
 //@Param   string  			method
 //@Param   interface{}  	param
 //@Return  proto.Message    return
 //@Return  error
 func (slf *RPCClient) CallReturn(method string, param proto.Message) (proto.Message, error) {
-	var data []byte
-	var err error
-	if slf._responseWait != 0 {
-		return nil, errors.New("call waitting")
-	}
-	if param != nil {
-		data, err = proto.Marshal(param)
-		if err != nil {
-			return nil, err
+	return slf.CallReturnContext(context.Background(), method, param)
+}
+
+//CallReturnContext doc
+//@Summary Call remote function wait return until the context is done or time out,
+//@Summary then a cancel request is sent, the cancel request, the remaining time out and
+//@Summary the context metadata travel when the server settled the features, the response
+//@Summary trailers fill the common.WithTrailer metadata of the context, serialized by
+//@Summary the context codec or the client codec
+//@Param   context.Context  context
+//@Param   string  			method
+//@Param   interface{}  	param
+//@Return  proto.Message    return
+//@Return  error
+func (slf *RPCClient) CallReturnContext(ctx context.Context, method string, param proto.Message) (proto.Message, error) {
+	timeout := slf._timeOut
+	if dl, ok := ctx.Deadline(); ok {
+		remain := int64(time.Until(dl) / time.Millisecond)
+		if remain <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+
+		if remain < timeout {
+			timeout = remain
 		}
 	}
-	slf._responseWait = slf.incSerial()
-	data = common.Encode(common.ConstVersion, method, slf._responseWait, common.RPCRequest, proto.MessageName(param), data)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ser, wait := slf.addPending()
+	defer slf.removePending(ser)
+
+	data, err := slf.request(ctx, method, ser, param, timeout)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = slf.SendTo(data); err != nil {
-		slf._responseWait = 0
 		return nil, err
 	}
 
 	slf._closeWait.Add(1)
 	defer slf._closeWait.Done()
-	for {
-		select {
-		case <-slf._responseStop:
-			atomic.StoreUint32(&slf._responseWait, 0)
-			return nil, code.ErrConnectClosed
-		case <-time.After(time.Duration(slf._timeOut) * time.Millisecond):
-			atomic.StoreUint32(&slf._responseWait, 0)
-			return nil, code.ErrTimeOut
-		case result := <-slf._response:
-			if atomic.CompareAndSwapUint32(&slf._responseWait, result.Ser, 0) {
-				return result.Return, nil
-			}
+	return common.WaitReturn(ctx, slf.Version(), method, ser, wait, timeout, slf._responseStop, slf.canceller())
+}
+
+//canceller doc
+//@Summary Returns the send of the cancel requests, nil the server does not take cancel requests
+//@Return func([]byte) error
+func (slf *RPCClient) canceller() func([]byte) error {
+	if slf.Features()&common.FeatureExtend == 0 {
+		return nil
+	}
+	return slf.SendTo
+}
+
+//NewStream doc
+//@Summary Open a stream to the server method, server-stream, client-stream or bidi
+//@Param  context.Context stream context, the deadline travels with the open frame
+//@Param  string          remote method
+//@Return *common.Stream
+//@Return error
+func (slf *RPCClient) NewStream(ctx context.Context, method string) (*common.Stream, error) {
+	if slf.Features()&common.FeatureStream == 0 {
+		return nil, code.ErrFeatureUnsupported
+	}
+
+	slf._pendingSync.Lock()
+	ser := slf.incSerial()
+	slf._pendingSync.Unlock()
+	return slf.OpenStream(ctx, slf.Version(), method, ser, slf.outgoing(ctx), slf.codec(ctx), slf.SendTo)
+}
+
+//request doc
+//@Summary Encode the request of the settled version and features, the time out, the context
+//@Summary metadata and codec travel with the request when the server settled the features
+//@Param  context.Context context
+//@Param  string          method
+//@Param  uint32          serial, 0 non-return
+//@Param  proto.Message   param
+//@Param  int64           remaining time out/millsecond, 0 no time out
+//@Return []byte
+//@Return error
+func (slf *RPCClient) request(ctx context.Context, method string, ser uint32, param proto.Message, timeout int64) ([]byte, error) {
+	return common.CallRequest(slf.Version(), slf.Features(), method, ser, param, timeout, slf.outgoing(ctx), slf.codec(ctx))
+}
+
+//codec doc
+//@Summary Returns the codec of the context, otherwise the client codec
+//@Param  context.Context
+//@Return common.Codec
+func (slf *RPCClient) codec(ctx context.Context) common.Codec {
+	if codec := common.CodecFromContext(ctx); codec != nil {
+		return codec
+	}
+	return slf._codec
+}
+
+//outgoing doc
+//@Summary Returns the outgoing metadata of the context, nil the server does not support
+//@Param  context.Context
+//@Return common.Metadata
+func (slf *RPCClient) outgoing(ctx context.Context) common.Metadata {
+	if slf.Features()&common.FeatureMetadata == 0 {
+		return nil
+	}
+	return common.OutgoingMetadata(ctx)
+}
+
+//Version doc
+//@Summary Returns the protocol version negotiated with the server
+//@Return int
+func (slf *RPCClient) Version() int {
+	ver := atomic.LoadInt32(&slf._ver)
+	if ver == 0 {
+		return common.ConstVersion
+	}
+	return int(ver)
+}
 
+//Features doc
+//@Summary Returns the protocol features settled with the server
+//@Return uint32
+func (slf *RPCClient) Features() uint32 {
+	return atomic.LoadUint32(&slf._features)
+}
+
+//withNegotiated doc
+//@Summary Settle the protocol version and features negotiated with the server
+//@Param int    version, 0 before the handshake code
+//@Param uint32 features
+func (slf *RPCClient) withNegotiated(ver int, features uint32) {
+	atomic.StoreInt32(&slf._ver, int32(ver))
+	atomic.StoreUint32(&slf._features, features)
+}
+
+//withCompressor doc
+//@Summary Settle the compressor of the frames sent to the server
+//@Param common.Compressor compressor, nil raw
+//@Param int               data length below which frames are sent raw
+func (slf *RPCClient) withCompressor(compressor common.Compressor, threshold int) {
+	slf._sync.Lock()
+	slf._compressor = compressor
+	slf._threshold = threshold
+	slf._sync.Unlock()
+}
+
+//compression doc
+//@Summary Returns the settled compressor, nil raw, and the data length below which
+//@Summary frames are sent raw
+func (slf *RPCClient) compression() (common.Compressor, int) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._compressor, slf._threshold
+}
+
+//handshaking doc
+//@Summary Returns the negotiation and the authentication waited
+//@Return bool negotiation waited
+//@Return bool authentication waited
+func (slf *RPCClient) handshaking() (bool, bool) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._negotiate, slf._authenticate
+}
+
+//Pending doc
+//@Summary Returns Number of calls waiting for return
+//@Return int
+func (slf *RPCClient) Pending() int {
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	return len(slf._pending)
+}
+
+func (slf *RPCClient) addPending() (uint32, chan *common.ResponseEvent) {
+	wait := make(chan *common.ResponseEvent, 1)
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	for {
+		ser := slf.incSerial()
+		if _, ok := slf._pending[ser]; ok {
 			continue
 		}
+		slf._pending[ser] = wait
+		return ser, wait
+	}
+}
+
+func (slf *RPCClient) removePending(ser uint32) chan *common.ResponseEvent {
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	wait, ok := slf._pending[ser]
+	if !ok {
+		return nil
 	}
+	delete(slf._pending, ser)
+	return wait
 }
 
 func (slf *RPCClient) onRequest(context actor.Context, sender *actor.PID, message interface{}) {
@@ -173,44 +451,318 @@ func (slf *RPCClient) onRequest(context actor.Context, sender *actor.PID, messag
 
 func (slf *RPCClient) onResponse(context actor.Context, sender *actor.PID, message interface{}) {
 	response := message.(*common.ResponseEvent)
-	if slf._responseWait != response.Ser {
+	wait := slf.removePending(response.Ser)
+	if wait == nil {
 		slf.LogError("RPC Response error not request wait")
 		return
 	}
 
-	slf._closeWait.Add(1)
-	defer slf._closeWait.Done()
+	wait <- response
+}
 
-	select {
-	case <-slf._responseStop:
-		atomic.StoreUint32(&slf._responseWait, 0)
-		slf.LogError("client closed")
-		return
-	case slf._response <- response:
-	}
+//OnPanic doc
+//@Summary Log a recovered method panic with the stack
+//@Param string      method name
+//@Param interface{} panic value
+//@Param []byte      stack
+func (slf *RPCClient) OnPanic(methodName string, r interface{}, stack []byte) {
+	slf.LogError("RPC method %s panic:%+v\n%s", methodName, r, stack)
+}
+
+func (slf *RPCClient) withTLS(config *tls.Config, limit int) {
+	slf._tls = common.NewTLSClient(config, limit, slf.NetConnector.SendTo,
+		slf.rpcDispatch,
+		func(err error) {
+			slf.LogError("RPC TLS error:%+v", err)
+			slf.closeStop()
+		})
 }
 
 func (slf *RPCClient) rpcDecode(context actor.Context, params ...interface{}) error {
 	c := params[0].(*connector.NetConnector)
-	if slf._auth == 0 {
-		tmpAuth := c.ReadBuffer(1)
-		if tmpAuth[0] != common.ConstHandShakeCode {
-			return errors.New("rpc connection unauthorized")
+	if slf._tls != nil {
+		return slf._tls.Feed(c)
+	}
+	return slf.rpcDispatch(c)
+}
+
+func (slf *RPCClient) rpcDispatch(c net.INetReceiveBuffer) error {
+	if atomic.LoadInt32(&slf._ver) == 0 {
+		if err := slf.greeting(c); err != nil {
+			return err
 		}
-		slf._auth = timer.Now()
 	}
 
-	data, err := common.RPCDecodeClient(slf._parent.getRPC, c)
+	data, err := common.RPCDecodeClient(slf._parent.getRPC, c, slf._maxFrame)
 	if err != nil {
 		return err
 	}
 
-	actor.DefaultSchedulerContext.Send(context.Self(), data)
+	slf.awaitHello(data)
+	if slf.route(data) {
+		return net.ErrAnalysisSuccess
+	}
+
+	actor.DefaultSchedulerContext.Send(slf.GetPID(), data)
 
 	return net.ErrAnalysisSuccess
 }
 
+//greeting doc
+//@Summary Read the 1 byte handshake code, the offer follows, or the challenge
+//@Summary when the connection must authenticate
+//@Param  net.INetReceiveBuffer
+//@Return error
+func (slf *RPCClient) greeting(c net.INetReceiveBuffer) error {
+	if c.GetBufferLen() < 1 {
+		return net.ErrAnalysisProceed
+	}
+
+	tmpAuth := c.ReadBuffer(1)
+	if tmpAuth[0] != common.ConstHandShakeCode && tmpAuth[0] != common.ConstHandShakeAuthCode {
+		return errors.New("rpc connection unauthorized")
+	}
+
+	if tmpAuth[0] == common.ConstHandShakeAuthCode && slf._authenticator == nil {
+		slf.handshakeFailed(code.ErrUnauthenticated)
+		return code.ErrUnauthenticated
+	}
+
+	authenticate := tmpAuth[0] == common.ConstHandShakeAuthCode
+	slf._sync.Lock()
+	slf._negotiate = true
+	slf._authenticate = authenticate
+	slf._sync.Unlock()
+	atomic.StoreInt32(&slf._ver, common.ConstVersion)
+	if !authenticate {
+		atomic.StoreInt64(&slf._helloWait, time.Now().Add(constHelloWait*time.Millisecond).UnixNano())
+	}
+	return nil
+}
+
+//awaitHello doc
+//@Summary A frame other than the offer while the offer is waited settles
+//@Summary the server without negotiation
+//@Param interface{} decoded event
+func (slf *RPCClient) awaitHello(data interface{}) {
+	if _, ok := data.(*common.HelloEvent); ok {
+		return
+	}
+
+	if negotiate, authenticate := slf.handshaking(); !negotiate || authenticate {
+		return
+	}
+	slf.settleLegacy()
+}
+
+//settleLegacy doc
+//@Summary Settle the server without negotiation, version 1 and the legacy features,
+//@Summary the client codec fails the handshake, the server only reads protobuf
+func (slf *RPCClient) settleLegacy() {
+	if !atomic.CompareAndSwapInt32(&slf._settled, 0, 1) {
+		return
+	}
+
+	atomic.StoreInt64(&slf._helloWait, 0)
+	if name := slf.codecName(); name != "" {
+		slf.handshakeFailed(fmt.Errorf("%s: codec %s", code.ErrFeatureUnsupported.Error(), name))
+		return
+	}
+
+	atomic.StoreUint32(&slf._features, common.ConstLegacyFeatures)
+	slf._sync.Lock()
+	slf._negotiate = false
+	slf._sync.Unlock()
+	slf.handshaked()
+}
+
+//route doc
+//@Summary Handle control events on the decoding goroutine
+//@Param  interface{} decoded event
+//@Return bool        event consumed, otherwise send to the client service
+func (slf *RPCClient) route(data interface{}) bool {
+	switch event := data.(type) {
+	case *common.HelloEvent:
+		slf.negotiate(event)
+		return true
+	case *common.AuthEvent:
+		slf.authenticate(event)
+		return true
+	case *common.CancelEvent:
+		slf.CancelRequest(event.Ser)
+		return true
+	case *common.StreamEvent:
+		slf.StreamProcess(slf, slf.SendTo, event)
+		return true
+	case *common.ResponseEvent:
+		return event.Err != nil && slf.StreamError(event.Ser, event.Err)
+	}
+	return false
+}
+
+//negotiate doc
+//@Summary Settle the version and features of the server offer once, and answer,
+//@Summary an offer after the settlement is dropped
+//@Param *common.HelloEvent offer or reject
+func (slf *RPCClient) negotiate(event *common.HelloEvent) {
+	if event.Code != 0 {
+		slf.handshakeFailed(code.NewError(event.Code, event.Message, nil))
+		return
+	}
+
+	if negotiate, authenticate := slf.handshaking(); !negotiate || authenticate ||
+		!atomic.CompareAndSwapInt32(&slf._settled, 0, 1) {
+		return
+	}
+	atomic.StoreInt64(&slf._helloWait, 0)
+
+	ver, err := common.NegotiateVersion(slf.minVersion(), slf.maxVersion(), event.MinVersion, event.MaxVersion)
+	if err != nil {
+		slf.handshakeFailed(fmt.Errorf("%s: server [%d, %d], client [%d, %d]", err.Error(),
+			event.MinVersion, event.MaxVersion, slf.minVersion(), slf.maxVersion()))
+		return
+	}
+
+	features := common.NegotiateFeatures(ver, common.ConstFeatures, event.Features)
+	answer := &common.HelloEvent{Version: ver}
+	compressor := common.NegotiateCompressor(slf._compressors, event.Compressors)
+	if compressor != "" {
+		answer.Compressors = []string{compressor}
+	} else {
+		features &^= common.FeatureCompression
+	}
+
+	if name := slf.codecName(); name != "" {
+		if features&common.FeatureExtend == 0 {
+			slf.handshakeFailed(fmt.Errorf("%s: codec %s", code.ErrFeatureUnsupported.Error(), name))
+			return
+		}
+
+		if !codecOffered(event.Codecs, name) {
+			slf.handshakeFailed(fmt.Errorf("%s: %s", code.ErrCodecUndefined.Error(), name))
+			return
+		}
+		answer.Codecs = []string{name}
+	}
+
+	answer.Tags = slf._tags
+	answer.Features = features
+	data, err := common.EncodeHello(answer)
+	if err == nil {
+		err = slf.SendTo(data)
+	}
+
+	if err != nil {
+		slf.handshakeFailed(err)
+		return
+	}
+
+	slf.withNegotiated(ver, features)
+	slf._sync.Lock()
+	slf._compressor = common.GetCompressor(compressor)
+	slf._negotiate = false
+	slf._sync.Unlock()
+	slf.handshaked()
+}
+
+func (slf *RPCClient) codecName() string {
+	if slf._codec == nil || slf._codec.Name() == common.ConstCodecProto {
+		return ""
+	}
+	return slf._codec.Name()
+}
+
+func codecOffered(offer []string, name string) bool {
+	for _, v := range offer {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+//handshaked doc
+//@Summary The connection is ready when negotiated and authenticated
+func (slf *RPCClient) handshaked() {
+	if negotiate, authenticate := slf.handshaking(); !negotiate && !authenticate {
+		atomic.StoreUint64(&slf._auth, timer.Now())
+	}
+}
+
+func (slf *RPCClient) minVersion() int {
+	if slf._minVer == 0 {
+		return common.ConstVersion
+	}
+	return slf._minVer
+}
+
+func (slf *RPCClient) maxVersion() int {
+	if slf._maxVer == 0 {
+		return common.ConstVersionMax
+	}
+	return slf._maxVer
+}
+
+//authenticate doc
+//@Summary Answer the server challenge, the connection is authenticated by the result
+//@Param *common.AuthEvent challenge or result
+func (slf *RPCClient) authenticate(event *common.AuthEvent) {
+	if _, authenticate := slf.handshaking(); slf._authenticator == nil || !authenticate {
+		return
+	}
+
+	if event.Name != slf._authenticator.Name() {
+		slf.handshakeFailed(code.ErrUnauthenticated)
+		return
+	}
+
+	if event.Oper == common.RPCResponse {
+		if event.Code != 0 {
+			slf.handshakeFailed(code.NewError(event.Code, event.Message, nil))
+			return
+		}
+		slf._sync.Lock()
+		slf._authenticate = false
+		slf._sync.Unlock()
+		slf.handshaked()
+		return
+	}
+
+	identity, proof, err := slf._authenticator.Response(event.Challenge)
+	if err == nil {
+		var data []byte
+		data, err = common.EncodeAuth(slf.Version(), &common.AuthEvent{Name: slf._authenticator.Name(),
+			Oper:     common.RPCRequest,
+			Identity: identity,
+			Proof:    proof})
+		if err == nil {
+			err = slf.SendTo(data)
+		}
+	}
+
+	if err != nil {
+		slf.handshakeFailed(err)
+	}
+}
+
+func (slf *RPCClient) handshakeFailed(err error) {
+	slf.LogError("RPC handshake error:%+v", err)
+	slf._pendingSync.Lock()
+	slf._handshakeErr = err
+	slf._pendingSync.Unlock()
+	slf.closeStop()
+}
+
+func (slf *RPCClient) handshakeError() error {
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	return slf._handshakeErr
+}
+
 func (slf *RPCClient) incSerial() uint32 {
 	slf._serial = ((slf._serial + 1) & 0xFFFFFFF)
+	if slf._serial == 0 {
+		slf._serial++
+	}
 	return slf._serial
 }
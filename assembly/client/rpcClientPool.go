@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"reflect"
@@ -13,37 +15,78 @@ import (
 	"github.com/yamakiller/magicNet/handler/implement/buffer"
 	"github.com/yamakiller/magicNet/handler/implement/connector"
 	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/assembly/common"
 	"github.com/yamakiller/magicRpc/code"
 )
 
 //Options doc
 //@Summary
 //@Method string address
+//@Method []string addresses, the calls are balanced across, instead of the address
 //@Method int    receive buffer size
+//@Method int    frame size limit of the received frames
 //@Method int    receive event chan size
 //@Method int    socket connection time out/millsecond
 //@Method int    operation time out/millsecond
 //@Method int    connection idle max of number
 //@Method int    connection max of number
 //@Method int    connection idle time out
+//@Method int    connection concurrent call max of number
+//@Method *tls.Config TLS config, nil plain TCP
+//@Method common.Authenticator handshake authenticator
+//@Method int    lowest supported protocol version
+//@Method int    highest supported protocol version
+//@Method []common.UnaryClientInterceptor call interceptors, in order
+//@Method []string compressors in order of preference
+//@Method int    data length below which frames are sent raw
+//@Method string codec of the calls, empty protobuf
+//@Method Balancer endpoint policy of the calls, nil round robin
+//@Method Resolver service discovery of the addresses, instead of the addresses
+//@Method int64  reconnection backoff after the first failure/millsecond
+//@Method int64  reconnection backoff max/millsecond
+//@Method map[string]bool idempotent methods, retried by the retry policies
+//@Method map[string]RetryPolicy retry policies of the methods, "" the default
+//@Method *BreakerPolicy circuit breaker policy of the endpoints, nil none
+//@Method map[string]HedgePolicy hedging policies of the methods, "" the default
+//@Method map[string]string tags of the connections, sent by the handshake
 type Options struct {
-	Name           string
-	Addr           string
-	BufferCap      int
-	OutChanSize    int
-	SocketTimeout  int64
-	Timeout        int64
-	Idle           int
-	Active         int
-	IdleTimeout    int64
-	AsyncConnected func(c *RPCClient)
+	Name              string
+	Addr              string
+	Addrs             []string
+	BufferCap         int
+	MaxFrameSize      int
+	OutChanSize       int
+	SocketTimeout     int64
+	Timeout           int64
+	Idle              int
+	Active            int
+	IdleTimeout       int64
+	MaxCalls          int
+	TLSConfig         *tls.Config
+	Auth              common.Authenticator
+	MinVersion        int
+	MaxVersion        int
+	Interceptors      []common.UnaryClientInterceptor
+	Compressors       []string
+	CompressThreshold int
+	Codec             string
+	Balancer          Balancer
+	Resolver          Resolver
+	BackoffBase       int64
+	BackoffMax        int64
+	Idempotent        map[string]bool
+	Retry             map[string]RetryPolicy
+	Breaker           *BreakerPolicy
+	Hedge             map[string]HedgePolicy
+	Tags              map[string]string
+	AsyncConnected    func(c *RPCClient)
 }
 
 //Option param
 type Option func(*Options) error
 
 var (
-	defaultOptions = Options{Name: "RPC/Client", BufferCap: 8196, OutChanSize: 32, Timeout: 1000 * 1000, SocketTimeout: 1000 * 60, Idle: 2, Active: 2, IdleTimeout: 1000 * 120}
+	defaultOptions = Options{Name: "RPC/Client", BufferCap: 8196, MaxFrameSize: common.ConstMaxFrameSize, OutChanSize: 32, Timeout: 1000 * 1000, SocketTimeout: 1000 * 60, Idle: 2, Active: 2, IdleTimeout: 1000 * 120, MaxCalls: 1, MinVersion: common.ConstVersion, MaxVersion: common.ConstVersionMax, CompressThreshold: common.ConstCompressThreshold, BackoffBase: ConstBackoffBase, BackoffMax: ConstBackoffMax}
 )
 
 // WithName Set RPC client pool name
@@ -62,6 +105,124 @@ func WithAddr(addr string) Option {
 	}
 }
 
+//WithAddrs Set RPC client connection addresses, the connections are
+//made to each address and the calls are balanced across
+func WithAddrs(addrs ...string) Option {
+	return func(o *Options) error {
+		o.Addrs = addrs
+		return nil
+	}
+}
+
+//WithBalancer Set endpoint policy of the calls, RoundRobinBalancer,
+//LeastOutstandingBalancer, P2CBalancer or ConsistentHashBalancer
+func WithBalancer(b Balancer) Option {
+	return func(o *Options) error {
+		o.Balancer = b
+		return nil
+	}
+}
+
+//WithResolver Set service discovery of the addresses, the connections are
+//opened and drained as the endpoints come and go
+func WithResolver(r Resolver) Option {
+	return func(o *Options) error {
+		o.Resolver = r
+		return nil
+	}
+}
+
+//WithBackoff Set reconnection backoff/millsecond, doubled by each failure
+//in a row from the base up to the max, jittered
+func WithBackoff(base, max int64) Option {
+	return func(o *Options) error {
+		if base <= 0 || max < base {
+			return errors.New("rpc backoff must be in (0, max]")
+		}
+		o.BackoffBase = base
+		o.BackoffMax = max
+		return nil
+	}
+}
+
+//WithIdempotent Mark methods idempotent, only the idempotent methods are retried
+func WithIdempotent(methods ...string) Option {
+	return func(o *Options) error {
+		idempotent := make(map[string]bool, len(o.Idempotent)+len(methods))
+		for k, v := range o.Idempotent {
+			idempotent[k] = v
+		}
+
+		for _, method := range methods {
+			idempotent[method] = true
+		}
+		o.Idempotent = idempotent
+		return nil
+	}
+}
+
+//WithRetryPolicy Set retry policy of the method, empty method the default
+//of the idempotent methods, DefaultRetryPolicy when none
+func WithRetryPolicy(method string, policy RetryPolicy) Option {
+	return func(o *Options) error {
+		if policy.MaxAttempts < 1 || policy.Backoff < 0 || policy.BackoffMax < policy.Backoff {
+			return errors.New("rpc retry policy invalid")
+		}
+
+		retry := make(map[string]RetryPolicy, len(o.Retry)+1)
+		for k, v := range o.Retry {
+			retry[k] = v
+		}
+		retry[method] = policy
+		o.Retry = retry
+		return nil
+	}
+}
+
+//WithCircuitBreaker Set circuit breaker policy of the endpoints, the calls go to
+//the other endpoints while the breaker of an endpoint rejects, fail fast with
+//code.ErrCircuitOpen when all reject
+func WithCircuitBreaker(policy BreakerPolicy) Option {
+	return func(o *Options) error {
+		if policy.FailureRatio <= 0 || policy.FailureRatio > 1 || policy.MinRequests < 1 ||
+			policy.Window <= 0 || policy.Cooldown <= 0 {
+			return errors.New("rpc circuit breaker policy invalid")
+		}
+		o.Breaker = &policy
+		return nil
+	}
+}
+
+//WithHedging Set hedging policy of the method, empty method the default of the
+//idempotent methods, the hedged methods are not retried
+func WithHedging(method string, policy HedgePolicy) Option {
+	return func(o *Options) error {
+		if policy.MaxAttempts < 2 || policy.Delay < 0 {
+			return errors.New("rpc hedging policy invalid")
+		}
+
+		hedge := make(map[string]HedgePolicy, len(o.Hedge)+1)
+		for k, v := range o.Hedge {
+			hedge[k] = v
+		}
+		hedge[method] = policy
+		o.Hedge = hedge
+		return nil
+	}
+}
+
+//WithTags Set tags of the connections, service name, region, shard or labels,
+//the server selects the connections by tag
+func WithTags(tags map[string]string) Option {
+	return func(o *Options) error {
+		o.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			o.Tags[k] = v
+		}
+		return nil
+	}
+}
+
 //WithBufferCap Set Connection Receive Buffer size
 func WithBufferCap(cap int) Option {
 	return func(o *Options) error {
@@ -70,6 +231,19 @@ func WithBufferCap(cap int) Option {
 	}
 }
 
+//WithMaxFrameSize Set frame size limit of the frames from the server,
+//the data decompressed included, a larger frame closes the connection,
+//the tls bytes waiting decode are capped at a frame
+func WithMaxFrameSize(n int) Option {
+	return func(o *Options) error {
+		if n <= 0 {
+			return errors.New("rpc max frame size must be positive")
+		}
+		o.MaxFrameSize = n
+		return nil
+	}
+}
+
 //WithTimeout Set Connection operation time out/millsecond
 func WithTimeout(tm int64) Option {
 	return func(o *Options) error {
@@ -118,6 +292,88 @@ func WithIdleTimeout(tm int64) Option {
 	}
 }
 
+//WithMaxCalls Set Connection concurrent call max of number
+func WithMaxCalls(n int) Option {
+	return func(o *Options) error {
+		o.MaxCalls = n
+		return nil
+	}
+}
+
+//WithTLSConfig Set TLS config, the connections are made over TLS,
+//set Certificates to present the client certificate for mutual TLS
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *Options) error {
+		o.TLSConfig = config
+		return nil
+	}
+}
+
+//WithVersions Set supported protocol versions, the handshake settles
+//on the highest version both sides support
+func WithVersions(min, max int) Option {
+	return func(o *Options) error {
+		if min < common.ConstVersion || max > common.ConstVersionMax || min > max {
+			return code.ErrVersionUnsupported
+		}
+		o.MinVersion = min
+		o.MaxVersion = max
+		return nil
+	}
+}
+
+//WithAuthenticator Set handshake authenticator, answers the server challenge
+func WithAuthenticator(auth common.Authenticator) Option {
+	return func(o *Options) error {
+		o.Auth = auth
+		return nil
+	}
+}
+
+//WithCompressor Set compressors in order of preference, settles the first the server supports
+func WithCompressor(names ...string) Option {
+	return func(o *Options) error {
+		for _, name := range names {
+			if common.GetCompressor(name) == nil {
+				return fmt.Errorf("rpc compressor %s undefined", name)
+			}
+		}
+		o.Compressors = names
+		return nil
+	}
+}
+
+//WithCompressThreshold Set data length below which frames are sent raw
+func WithCompressThreshold(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("rpc compress threshold must not be negative")
+		}
+		o.CompressThreshold = n
+		return nil
+	}
+}
+
+//WithCodec Set codec of the calls, the server must support, registered names only,
+//a server without negotiation fails the handshake
+func WithCodec(name string) Option {
+	return func(o *Options) error {
+		if common.GetCodec(name) == nil {
+			return fmt.Errorf("rpc codec %s undefined", name)
+		}
+		o.Codec = name
+		return nil
+	}
+}
+
+//WithUnaryInterceptor Append call interceptors, the chain runs in order around the calls
+func WithUnaryInterceptor(interceptors ...common.UnaryClientInterceptor) Option {
+	return func(o *Options) error {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+		return nil
+	}
+}
+
 //WithAsyncConnected Set Connected Callback function
 func WithAsyncConnected(f func(*RPCClient)) Option {
 	return func(o *Options) error {
@@ -131,55 +387,83 @@ func WithAsyncConnected(f func(*RPCClient)) Option {
 //@Param  ...Option
 func New(options ...Option) (*RPCClientPool, error) {
 
-	c := &RPCClientPool{_opts: defaultOptions}
+	c := &RPCClientPool{_opts: defaultOptions, _rpcs: make(map[string]*common.RPCService)}
 	for _, opt := range options {
 		if err := opt(&c._opts); err != nil {
 			return nil, err
 		}
 	}
+	c._interceptor = common.ChainUnaryClient(c._opts.Interceptors...)
+	c._ctx, c._cancel = context.WithCancel(context.Background())
+	c._dial = c.netClient
+	c._call = c.call
+	c._balancer = c._opts.Balancer
+	if c._balancer == nil {
+		c._balancer = &RoundRobinBalancer{}
+	}
 
-	var err error
-	var cc *RPCClient
-	var newid int64
-	for i := 0; i < c._opts.Idle; i++ {
-		newid, cc, err = c.netClient()
-		if err != nil {
-			goto fail
+	if c._opts.Resolver != nil {
+		if err := c.resolve(); err != nil {
+			c.Shutdown()
+			return nil, err
+		}
+	} else {
+		addrs := c._opts.Addrs
+		if len(addrs) == 0 && c._opts.Addr != "" {
+			addrs = []string{c._opts.Addr}
+		}
+
+		if len(addrs) == 0 {
+			return nil, errors.New("rpc client address undefined")
+		}
+
+		var err error
+		for _, ep := range c.setEndpoints(addrs) {
+			if e := c.fill(ep); e != nil {
+				err = e
+			}
 		}
 
-		c._cs = append(c._cs, &rpcHandle{_id: newid, _client: cc, _ref: 1, _status: constClientIdle})
-		c._sz++
+		if err != nil && len(c._cs) == 0 {
+			c.Shutdown()
+			return nil, err
+		}
 	}
 
 	c._wait.Add(1)
 	go c.guard()
 
 	return c, nil
-fail:
-	for _, v := range c._cs {
-		v._client.Shutdown()
-	}
-	c._cs = c._cs[len(c._cs):]
-	return nil, err
 }
 
 type rpcHandle struct {
-	_id     int64
-	_client *RPCClient
-	_ref    int
-	_status int //idle/use/del
+	_id       int64
+	_client   *RPCClient
+	_endpoint *Endpoint
+	_ref      int
+	_status   int //idle/use/del
 }
 
 //RPCClientPool doc
 type RPCClientPool struct {
-	_cs         []*rpcHandle
-	_ids        int64
-	_opts       Options
-	_sz         int
-	_isShutdown bool
-	_wait       sync.WaitGroup
-	_rpcs       map[string]interface{}
-	_sync       sync.Mutex
+	_cs          []*rpcHandle
+	_ids         int64
+	_opts        Options
+	_sz          int
+	_isShutdown  bool
+	_wait        sync.WaitGroup
+	_rpcs        map[string]*common.RPCService
+	_interceptor common.UnaryClientInterceptor
+	_endpoints   []*Endpoint
+	_balancer    Balancer
+	_ctx         context.Context
+	_cancel      context.CancelFunc
+	_dial        func(addr string) (int64, *RPCClient, error)
+	_call        func(ctx context.Context, h *rpcHandle, method string, param proto.Message, wait bool) (proto.Message, error)
+	_hedged      uint64
+	_hedgeWins   uint64
+	_resent      uint64
+	_sync        sync.Mutex
 }
 
 //GetName doc
@@ -194,56 +478,122 @@ func (slf *RPCClientPool) GetName() string {
 //@Param   string  method name
 //@Param   interface param
 func (slf *RPCClientPool) Call(method string, param, ret interface{}) error {
-	var r proto.Message
-	var h *rpcHandle
-	var err error
-	ick := 0
-	startTime := time.Now().UnixNano()
-	for {
-		h, err = slf.getPool()
-		if err != nil {
-			if err != code.ErrConnectNoAvailable {
-				return err
-			}
-			ick++
-			if ick > 8 {
-				ick = 0
-				time.Sleep(time.Duration(100) * time.Millisecond)
-			}
-			currentTime := time.Now().UnixNano()
-			if (currentTime-startTime)/int64(time.Millisecond) >
-				slf._opts.SocketTimeout {
-				return code.ErrTimeOut
-			}
-			continue
+	return slf.CallContext(context.Background(), method, param, ret)
+}
+
+//CallContext doc
+//@Summary Call Remote function, waiting for a connection and the return
+//@Summary until the context is done, the context deadline travels with the request,
+//@Summary the interceptor chain runs around the call, the idempotent methods are retried
+//@Summary by the retry policy or hedged by the hedging policy
+//@Param   context.Context  context
+//@Param   string           method name
+//@Param   interface        param
+//@Param   interface        return, nil non-return
+//@Return  error
+func (slf *RPCClientPool) CallContext(ctx context.Context, method string, param, ret interface{}) error {
+	invoker := func(ctx context.Context, method string, request proto.Message) (proto.Message, error) {
+		if policy := slf.hedgePolicy(method); policy != nil && ret != nil {
+			return slf.invokeHedged(ctx, method, request, policy)
 		}
-		break
+		return slf.invokeRetry(ctx, method, request, ret != nil)
 	}
 
-	if ret == nil {
-		err = h._client.Call(method, param.(proto.Message))
+	var r proto.Message
+	var err error
+	if slf._interceptor != nil {
+		r, err = slf._interceptor(ctx, method, param.(proto.Message), invoker)
 	} else {
-		r, err = h._client.CallReturn(method, param.(proto.Message))
+		r, err = invoker(ctx, method, param.(proto.Message))
 	}
 
 	if err != nil {
-		if err == code.ErrConnectClosed {
-			return err
-		}
-		slf.putPool(h)
 		return err
 	}
-	slf.putPool(h)
+
 	if ret != nil {
+		if r == nil {
+			return code.NewError(code.CodeNoReturn, "RPC call returned nil", nil)
+		}
 		reflect.ValueOf(ret).Elem().Set(reflect.ValueOf(r).Elem())
 	}
 
 	return nil
 }
 
+//invoke doc
+//@Summary Call on a pooled connection, wait the return when wait, the tried
+//@Summary connections and endpoints are avoided
+//@Return proto.Message
+//@Return *rpcHandle the connection called, nil none
+//@Return error
+func (slf *RPCClientPool) invoke(ctx context.Context, method string, param proto.Message, wait bool, tried []*rpcHandle) (proto.Message, *rpcHandle, error) {
+	h, err := slf.waitPool(ctx, tried)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := slf._call(ctx, h, method, param, wait)
+	return r, h, err
+}
+
+//call doc
+//@Summary Call on the connection, wait the return when wait, the result is counted
+//@Summary by the endpoint breaker and the connection returns to the pool
+//@Return proto.Message
+//@Return error
+func (slf *RPCClientPool) call(ctx context.Context, h *rpcHandle, method string, param proto.Message, wait bool) (proto.Message, error) {
+	defer slf.putPool(h)
+
+	var r proto.Message
+	var err error
+	if wait {
+		r, err = h._client.CallReturnContext(ctx, method, param)
+	} else {
+		err = h._client.CallContext(ctx, method, param)
+	}
+
+	h._endpoint.record(err)
+	return r, err
+}
+
+//NewStream doc
+//@Summary Open a stream to the remote method, the connection returns
+//@Summary to the pool when the stream finished receiving or the stream
+//@Summary context is done, cancel the context to release a stream not
+//@Summary received until io.EOF
+//@Param   context.Context  stream context
+//@Param   string           method name
+//@Return  *common.Stream
+//@Return  error
+func (slf *RPCClientPool) NewStream(ctx context.Context, method string) (*common.Stream, error) {
+	h, err := slf.waitPool(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := h._client.NewStream(ctx, method)
+	h._endpoint.record(err)
+	if err != nil {
+		slf.putPool(h)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-s.Done():
+		case <-s.Context().Done():
+		}
+		slf.putPool(h)
+	}()
+
+	return s, nil
+}
+
 //Shutdown shutdown Client pools
 func (slf *RPCClientPool) Shutdown() {
 	slf._isShutdown = true
+	slf._cancel()
 	slf._wait.Wait()
 	slf._sync.Lock()
 	for {
@@ -262,10 +612,119 @@ func (slf *RPCClientPool) Shutdown() {
 	slf._sync.Unlock()
 }
 
-func (slf *RPCClientPool) netClient() (int64, *RPCClient, error) {
+//resolve doc
+//@Summary Run the resolver, waiting the first endpoint set until socket time out
+func (slf *RPCClientPool) resolve() error {
+	var once sync.Once
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	slf._wait.Add(1)
+	go func() {
+		defer slf._wait.Done()
+		done <- slf._opts.Resolver.Resolve(slf._ctx, func(addrs []string) {
+			slf.update(addrs)
+			once.Do(func() { close(ready) })
+		})
+	}()
+
+	select {
+	case <-ready:
+		return nil
+	case err := <-done:
+		return err
+	case <-time.After(time.Duration(slf._opts.SocketTimeout) * time.Millisecond):
+		return code.ErrTimeOut
+	}
+}
+
+//update doc
+//@Summary Replace the endpoint set, the connections of the new endpoints are opened
+func (slf *RPCClientPool) update(addrs []string) {
+	if slf._isShutdown {
+		return
+	}
+
+	for _, ep := range slf.setEndpoints(addrs) {
+		slf.fill(ep)
+	}
+}
+
+//setEndpoints doc
+//@Summary Replace the endpoint set, the connections of the removed endpoints
+//@Summary are drained, the calls in progress finish
+//@Return []*Endpoint the new endpoints
+func (slf *RPCClientPool) setEndpoints(addrs []string) []*Endpoint {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	var added []*Endpoint
+	endpoints := make([]*Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		if endpointOf(endpoints, addr) != nil {
+			continue
+		}
+
+		ep := endpointOf(slf._endpoints, addr)
+		if ep == nil {
+			ep = newEndpoint(addr)
+			if slf._opts.Breaker != nil {
+				ep._breaker = newCircuitBreaker(*slf._opts.Breaker)
+			}
+			added = append(added, ep)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	slf._endpoints = endpoints
+
+	for _, v := range slf._cs {
+		if endpointOf(endpoints, v._endpoint._addr) != v._endpoint {
+			v._status = constClientDel
+		}
+	}
+	return added
+}
+
+func endpointOf(endpoints []*Endpoint, addr string) *Endpoint {
+	for _, v := range endpoints {
+		if v._addr == addr {
+			return v
+		}
+	}
+	return nil
+}
+
+//fill doc
+//@Summary Open the idle connections of the endpoint
+func (slf *RPCClientPool) fill(ep *Endpoint) error {
+	for i := 0; i < slf._opts.Idle; i++ {
+		slf._sync.Lock()
+		slf.connecting(ep)
+		slf._sync.Unlock()
+
+		newid, cc, err := slf._dial(ep._addr)
+		slf._sync.Lock()
+		if err != nil {
+			slf.failing(ep)
+			slf._sync.Unlock()
+			return err
+		}
+
+		ep.succeed()
+		h := &rpcHandle{_id: newid, _client: cc, _endpoint: ep, _ref: 1, _status: constClientIdle}
+		if endpointOf(slf._endpoints, ep._addr) != ep {
+			h._status = constClientDel
+		}
+		slf._cs = append(slf._cs, h)
+		slf._sz++
+		slf._sync.Unlock()
+	}
+	return nil
+}
+
+func (slf *RPCClientPool) netClient(addr string) (int64, *RPCClient, error) {
 	var err error
 	newid := atomic.AddInt64(&slf._ids, 1)
-	cc := handler.Spawn(fmt.Sprintf("%s/%s/%d", slf._opts.Name, slf._opts.Addr, newid), func() handler.IService {
+	cc := handler.Spawn(fmt.Sprintf("%s/%s/%d", slf._opts.Name, addr, newid), func() handler.IService {
 
 		rpc := &RPCClient{}
 
@@ -285,11 +744,22 @@ func (slf *RPCClientPool) netClient() (int64, *RPCClient, error) {
 		rpc._parent = slf
 		rpc._timeOut = slf._opts.Timeout
 		rpc._connTimeout = slf._opts.SocketTimeout
+		rpc._maxFrame = slf._opts.MaxFrameSize
+		rpc._authenticator = slf._opts.Auth
+		rpc._minVer = slf._opts.MinVersion
+		rpc._maxVer = slf._opts.MaxVersion
+		rpc._compressors = slf._opts.Compressors
+		rpc._threshold = slf._opts.CompressThreshold
+		rpc._codec = common.GetCodec(slf._opts.Codec)
+		rpc._tags = slf._opts.Tags
 		rpc._idletime = (time.Now().UnixNano() / int64(time.Millisecond))
 
 		rpc.NetConnector = *l
 
 		rpc.Initial()
+		if slf._opts.TLSConfig != nil {
+			rpc.withTLS(slf._opts.TLSConfig, slf._opts.MaxFrameSize)
+		}
 
 		return rpc
 	}).(*RPCClient)
@@ -298,7 +768,7 @@ func (slf *RPCClientPool) netClient() (int64, *RPCClient, error) {
 		return 0, nil, err
 	}
 
-	err = cc.Connection(slf._opts.Addr)
+	err = cc.Connection(addr)
 	if err != nil {
 		cc.Shutdown()
 		return 0, nil, err
@@ -311,16 +781,108 @@ func (slf *RPCClientPool) netClient() (int64, *RPCClient, error) {
 	return newid, cc, nil
 }
 
-//getPool Return Client
-func (slf *RPCClientPool) getPool() (*rpcHandle, error) {
+//waitPool Return Client of the balanced endpoint, waiting until the context is done
+//or socket time out, the endpoints failing to connect are skipped, fails fast when
+//all endpoints are in transient failure, the tried connections are avoided
+func (slf *RPCClientPool) waitPool(ctx context.Context, tried []*rpcHandle) (*rpcHandle, error) {
+	key := balanceKeyOf(ctx)
+	ick := 0
+	startTime := time.Now().UnixNano()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if slf.endpointsDown() {
+			return nil, code.ErrEndpointDown
+		}
+
+		h, err := slf.getPool(key, tried)
+		if err == nil {
+			return h, nil
+		}
+
+		if err == code.ErrCircuitOpen {
+			return nil, err
+		}
+
+		if err != code.ErrConnectNoAvailable {
+			if slf.endpointsDown() {
+				return nil, err
+			}
+			continue
+		}
+		ick++
+		if ick > 8 {
+			ick = 0
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(100) * time.Millisecond):
+			}
+		}
+		currentTime := time.Now().UnixNano()
+		if (currentTime-startTime)/int64(time.Millisecond) >
+			slf._opts.SocketTimeout {
+			return nil, code.ErrTimeOut
+		}
+	}
+}
+
+//getPool Return Client of the balanced endpoint, the least busy connection
+//is shared until it reaches MaxCalls, a tried connection only when no other is available
+func (slf *RPCClientPool) getPool(key string, tried []*rpcHandle) (*rpcHandle, error) {
 	slf._sync.Lock()
-	for idx, v := range slf._cs {
-		if v._status != constClientIdle {
+	ep := slf.pick(key, tried)
+	if ep == nil {
+		slf._sync.Unlock()
+		return nil, code.ErrConnectNoAvailable
+	}
+
+	if !ep.available() {
+		slf._sync.Unlock()
+		return nil, code.ErrEndpointDown
+	}
+
+	if !ep.allow() {
+		slf._sync.Unlock()
+		return nil, code.ErrCircuitOpen
+	}
+
+	idx := -1
+	fallback := -1
+	conns := ep._dialing
+	for k, v := range slf._cs {
+		if v._endpoint != ep {
+			continue
+		}
+		conns++
+
+		if v._status == constClientDel || (v._ref-1) >= slf._opts.MaxCalls {
+			continue
+		}
+
+		if triedHandle(tried, v) {
+			if fallback < 0 || v._ref < slf._cs[fallback]._ref {
+				fallback = k
+			}
 			continue
 		}
 
+		if idx < 0 || v._ref < slf._cs[idx]._ref {
+			idx = k
+		}
+	}
+
+	if idx < 0 && conns >= slf._opts.Active {
+		idx = fallback
+	}
+
+	if idx >= 0 {
+		v := slf._cs[idx]
 		v._ref++
 		v._status = constClientRun
+		atomic.AddInt64(&ep._outstanding, 1)
 
 		if len(slf._cs) > 1 {
 			slf.removeClient(idx)
@@ -331,28 +893,250 @@ func (slf *RPCClientPool) getPool() (*rpcHandle, error) {
 		return v, nil
 	}
 
-	if slf._sz < slf._opts.Active {
+	if conns < slf._opts.Active {
 		slf._sz++
+		ep._dialing++
+		slf.connecting(ep)
 		slf._sync.Unlock()
-		newid, c, err := slf.netClient()
+		newid, c, err := slf._dial(ep._addr)
 		slf._sync.Lock()
+		ep._dialing--
 		if err != nil {
 			slf._sz--
+			slf.failing(ep)
+			ep.record(err)
 			slf._sync.Unlock()
 			return nil, err
 		}
 
-		h := &rpcHandle{_id: newid, _client: c, _ref: 2, _status: constClientRun}
+		ep.succeed()
+		atomic.AddInt64(&ep._outstanding, 1)
+		h := &rpcHandle{_id: newid, _client: c, _endpoint: ep, _ref: 2, _status: constClientRun}
 		slf._cs = append(slf._cs, h)
 		slf._sync.Unlock()
 		return h, nil
 	}
+	ep.release()
 	slf._sync.Unlock()
 	return nil, code.ErrConnectNoAvailable
 }
 
+//pick doc
+//@Summary Returns the balanced endpoint of the key, the failing endpoints are skipped
+//@Summary unless all are failing, the endpoints whose breaker rejects unless all reject,
+//@Summary the tried endpoints unless all are tried, must be called under the pool lock
+func (slf *RPCClientPool) pick(key string, tried []*rpcHandle) *Endpoint {
+	if len(slf._endpoints) == 0 {
+		return nil
+	}
+
+	endpoints := make([]*Endpoint, 0, len(slf._endpoints))
+	for _, v := range slf._endpoints {
+		if v.available() && v.passes() && !triedEndpoint(tried, v) {
+			endpoints = append(endpoints, v)
+		}
+	}
+
+	if len(endpoints) == 0 {
+		for _, v := range slf._endpoints {
+			if v.available() && v.passes() {
+				endpoints = append(endpoints, v)
+			}
+		}
+	}
+
+	if len(endpoints) == 0 {
+		for _, v := range slf._endpoints {
+			if v.available() {
+				endpoints = append(endpoints, v)
+			}
+		}
+	}
+
+	if len(endpoints) == 0 {
+		endpoints = slf._endpoints
+	}
+	return slf._balancer.Pick(endpoints, key)
+}
+
+//endpointsDown doc
+//@Summary Returns whether all endpoints are in transient failure
+func (slf *RPCClientPool) endpointsDown() bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	for _, v := range slf._endpoints {
+		if v.available() {
+			return false
+		}
+	}
+	return len(slf._endpoints) > 0
+}
+
+//Endpoints doc
+//@Summary Returns the current endpoints of the pool
+//@Return []*Endpoint
+func (slf *RPCClientPool) Endpoints() []*Endpoint {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return append([]*Endpoint(nil), slf._endpoints...)
+}
+
+//EndpointStats doc
+//@Summary Stats of a pool endpoint
+//@Member string            address
+//@Member ConnectivityState connectivity state
+//@Member BreakerState      circuit breaker state
+//@Member int               live connections
+//@Member int               connections in use
+//@Member int64             calls and streams in progress
+//@Member int               connection failures in a row
+type EndpointStats struct {
+	Addr        string
+	State       ConnectivityState
+	Breaker     BreakerState
+	Connections int
+	Busy        int
+	Outstanding int64
+	Failures    int
+}
+
+//PoolStats doc
+//@Summary Stats of the pool
+//@Member int             live connections
+//@Member []EndpointStats stats of the endpoints
+//@Member uint64          hedged copies sent after the delay
+//@Member uint64          hedged copies won
+//@Member uint64          copies re-sent after a connection error
+type PoolStats struct {
+	Connections int
+	Endpoints   []EndpointStats
+	Hedged      uint64
+	HedgeWins   uint64
+	Resent      uint64
+}
+
+//Stats doc
+//@Summary Returns the stats of the pool and its endpoints
+//@Return PoolStats
+func (slf *RPCClientPool) Stats() PoolStats {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	stats := PoolStats{Endpoints: make([]EndpointStats, len(slf._endpoints)),
+		Hedged:    atomic.LoadUint64(&slf._hedged),
+		HedgeWins: atomic.LoadUint64(&slf._hedgeWins),
+		Resent:    atomic.LoadUint64(&slf._resent)}
+	for i, ep := range slf._endpoints {
+		es := &stats.Endpoints[i]
+		es.Addr = ep._addr
+		es.State = ep.State()
+		es.Breaker = ep.Breaker()
+		es.Outstanding = ep.Outstanding()
+		es.Failures = ep._fails
+		for _, v := range slf._cs {
+			if v._endpoint != ep || v._status == constClientDel {
+				continue
+			}
+
+			es.Connections++
+			if v._ref > 1 {
+				es.Busy++
+			}
+		}
+		stats.Connections += es.Connections
+	}
+	return stats
+}
+
+//connecting doc
+//@Summary Mark the endpoint connecting unless ready, must be called under the pool lock
+func (slf *RPCClientPool) connecting(ep *Endpoint) {
+	if ep.State() != StateReady {
+		ep.setState(StateConnecting)
+	}
+}
+
+//failing doc
+//@Summary Mark the endpoint in transient failure after a failed dial and reconnect
+//@Summary in background after the backoff, must be called under the pool lock
+func (slf *RPCClientPool) failing(ep *Endpoint) {
+	ep.fail(time.Now().UnixNano()/int64(time.Millisecond), slf._opts.BackoffBase, slf._opts.BackoffMax)
+	slf.redial(ep)
+}
+
+//disconnected doc
+//@Summary Mark the ready endpoint idle after its last connection closed and reconnect
+//@Summary in background at once, must be called under the pool lock
+func (slf *RPCClientPool) disconnected(ep *Endpoint) {
+	if ep.State() == StateReady {
+		ep._retry = 0
+		ep.setState(StateIdle)
+	}
+	slf.redial(ep)
+}
+
+//redial doc
+//@Summary Reconnect the endpoint in background unless reconnecting or shutdown,
+//@Summary must be called under the pool lock
+func (slf *RPCClientPool) redial(ep *Endpoint) {
+	if ep._reconnecting || slf._isShutdown {
+		return
+	}
+
+	ep._reconnecting = true
+	slf._wait.Add(1)
+	go slf.reconnect(ep)
+}
+
+//reconnect doc
+//@Summary Reconnect the endpoint after the retry time until connected, removed or shutdown,
+//@Summary the connection joins the pool idle
+func (slf *RPCClientPool) reconnect(ep *Endpoint) {
+	defer slf._wait.Done()
+	for {
+		slf._sync.Lock()
+		delay := ep._retry - time.Now().UnixNano()/int64(time.Millisecond)
+		slf._sync.Unlock()
+
+		select {
+		case <-slf._ctx.Done():
+			return
+		case <-time.After(time.Duration(delay) * time.Millisecond):
+		}
+
+		slf._sync.Lock()
+		if slf._isShutdown || endpointOf(slf._endpoints, ep._addr) != ep {
+			ep._reconnecting = false
+			slf._sync.Unlock()
+			return
+		}
+
+		slf._sz++
+		ep._dialing++
+		ep.setState(StateConnecting)
+		slf._sync.Unlock()
+
+		newid, c, err := slf._dial(ep._addr)
+		slf._sync.Lock()
+		ep._dialing--
+		if err != nil {
+			slf._sz--
+			ep.fail(time.Now().UnixNano()/int64(time.Millisecond), slf._opts.BackoffBase, slf._opts.BackoffMax)
+			slf._sync.Unlock()
+			continue
+		}
+
+		ep.succeed()
+		ep._reconnecting = false
+		slf._cs = append(slf._cs, &rpcHandle{_id: newid, _client: c, _endpoint: ep, _ref: 1, _status: constClientIdle})
+		slf._sync.Unlock()
+		return
+	}
+}
+
 func (slf *RPCClientPool) putPool(h *rpcHandle) {
 	h._client._idletime = (time.Now().UnixNano() / int64(time.Millisecond))
+	atomic.AddInt64(&h._endpoint._outstanding, -1)
 
 	slf._sync.Lock()
 	defer slf._sync.Unlock()
@@ -363,6 +1147,10 @@ func (slf *RPCClientPool) putPool(h *rpcHandle) {
 		return
 	}
 	h._ref--
+	if h._ref > 1 {
+		return
+	}
+
 	if len(slf._cs) > 1 {
 		for k := len(slf._cs) - 1; k >= 0; k-- {
 			if slf._cs[k]._id == h._id {
@@ -397,11 +1185,25 @@ func (slf *RPCClientPool) closePool(handle int64) {
 				slf._cs = append(slf._cs, v)
 			}
 			v._client.closeStop()
+			if !slf.hasReady(v._endpoint) {
+				slf.disconnected(v._endpoint)
+			}
 			break
 		}
 	}
 }
 
+//hasReady doc
+//@Summary Returns whether the endpoint has a live connection, must be called under the pool lock
+func (slf *RPCClientPool) hasReady(ep *Endpoint) bool {
+	for _, v := range slf._cs {
+		if v._endpoint == ep && v._status != constClientDel {
+			return true
+		}
+	}
+	return false
+}
+
 func (slf *RPCClientPool) guard() {
 	var rm []*rpcHandle
 	var client, v *rpcHandle
@@ -409,21 +1211,20 @@ func (slf *RPCClientPool) guard() {
 	for !slf._isShutdown {
 		slf._sync.Lock()
 		startTime := (time.Now().UnixNano() / int64(time.Millisecond))
-		if slf._opts.Active > slf._opts.Idle && slf._sz > slf._opts.Idle {
-			for k := 0; k < len(slf._cs); {
-				v = slf._cs[k]
-				if v._status == constClientDel && v._ref <= 1 {
-					slf.removeClient(k)
-					v._ref = 0
-					rm = append(rm, v)
-					continue
-				}
+		idle := slf._opts.Active > slf._opts.Idle && slf._sz > slf._opts.Idle*len(slf._endpoints)
+		for k := 0; k < len(slf._cs); {
+			v = slf._cs[k]
+			if v._status == constClientDel && v._ref <= 1 {
+				slf.removeClient(k)
+				v._ref = 0
+				rm = append(rm, v)
+				continue
+			}
 
-				if (v._client._idletime - startTime) > slf._opts.IdleTimeout {
-					slf._cs[k]._status = constClientDel
-				}
-				k++
+			if idle && (v._client._idletime-startTime) > slf._opts.IdleTimeout {
+				slf._cs[k]._status = constClientDel
 			}
+			k++
 		}
 		slf._sync.Unlock()
 
@@ -450,7 +1251,7 @@ func (slf *RPCClientPool) removeClient(idx int) {
 	slf._sz--
 }
 
-func (slf *RPCClientPool) getRPC(name string) interface{} {
+func (slf *RPCClientPool) getRPC(name string) *common.RPCService {
 	f, ok := slf._rpcs[name]
 	if !ok {
 		return nil
@@ -459,8 +1260,8 @@ func (slf *RPCClientPool) getRPC(name string) interface{} {
 }
 
 //RegRPC doc
-//@Summary Register RPC Accesser function
-//@Param  string function name
+//@Summary Register RPC Accesser function, the exported methods signature
+//@Summary is validated and cached, see common.NewRPCService
 //@Param  interface{} function
 //@Return error
 func (slf *RPCClientPool) RegRPC(met interface{}) error {
@@ -468,6 +1269,10 @@ func (slf *RPCClientPool) RegRPC(met interface{}) error {
 		return errors.New("need object")
 	}
 
-	slf._rpcs[reflect.TypeOf(met).Elem().Name()] = met
+	svc, err := common.NewRPCService(reflect.TypeOf(met).Elem().Name(), met)
+	if err != nil {
+		return err
+	}
+	slf._rpcs[svc.Name()] = svc
 	return nil
 }
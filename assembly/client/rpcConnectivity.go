@@ -0,0 +1,65 @@
+package client
+
+import (
+	"math/rand"
+)
+
+const (
+	//ConstBackoffBase default reconnection backoff after the first failure/millsecond
+	ConstBackoffBase = 1000
+	//ConstBackoffMax default reconnection backoff max/millsecond
+	ConstBackoffMax = 1000 * 120
+	//backoff jitter, the backoff is randomized in [1-jitter, 1+jitter]
+	constBackoffJitter = 0.2
+)
+
+//ConnectivityState doc
+//@Summary Connectivity state of an endpoint
+type ConnectivityState int32
+
+const (
+	//StateIdle no connection, none made yet or the last one closed
+	StateIdle ConnectivityState = iota
+	//StateConnecting connection in progress
+	StateConnecting
+	//StateReady connected
+	StateReady
+	//StateTransientFailure dial failed, reconnecting in background after the backoff,
+	//the calls to the endpoint fail fast
+	StateTransientFailure
+)
+
+//String doc
+//@Summary Returns the state name
+//@Return string
+func (slf ConnectivityState) String() string {
+	switch slf {
+	case StateIdle:
+		return "IDLE"
+	case StateConnecting:
+		return "CONNECTING"
+	case StateReady:
+		return "READY"
+	case StateTransientFailure:
+		return "TRANSIENT_FAILURE"
+	}
+	return "UNKNOWN"
+}
+
+//backoff doc
+//@Summary Returns the jittered backoff after the failures, doubled by each failure up to the max
+//@Param  int   failures before
+//@Param  int64 base/millsecond
+//@Param  int64 max/millsecond
+//@Return int64 millsecond
+func backoff(fails int, base, max int64) int64 {
+	d := base
+	for i := 0; i < fails && d < max; i++ {
+		d <<= 1
+	}
+
+	if d > max {
+		d = max
+	}
+	return int64(float64(d) * (1 + constBackoffJitter*(rand.Float64()*2-1)))
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//HedgePolicy doc
+//@Summary Hedging policy of the idempotent methods, a further copy of the call is sent
+//@Summary to another connection or endpoint after each delay without a response,
+//@Summary the first success is taken and the other copies are cancelled
+//@Member int   copies max, the first call included
+//@Member int64 delay before each further copy/millsecond
+type HedgePolicy struct {
+	MaxAttempts int
+	Delay       int64
+}
+
+//hedgePolicy doc
+//@Summary Returns the hedging policy of the method, the method policy or the default policy,
+//@Summary nil the method is not idempotent or not hedged
+func (slf *RPCClientPool) hedgePolicy(method string) *HedgePolicy {
+	if !slf._opts.Idempotent[method] {
+		return nil
+	}
+
+	if p, ok := slf._opts.Hedge[method]; ok {
+		return &p
+	}
+
+	if p, ok := slf._opts.Hedge[""]; ok {
+		return &p
+	}
+	return nil
+}
+
+type hedgeResult struct {
+	_reply proto.Message
+	_err   error
+	_hedge bool
+}
+
+//invokeHedged doc
+//@Summary Call with the hedging policy, a connection error re-sends the next copy at once,
+//@Summary a method error is returned at once, only the copies sent after the delay are hedged
+func (slf *RPCClientPool) invokeHedged(ctx context.Context, method string, param proto.Message, policy *HedgePolicy) (proto.Message, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var tried []*rpcHandle
+	var triedSync sync.Mutex
+	results := make(chan hedgeResult, policy.MaxAttempts)
+	attempt := func(hedge bool) {
+		triedSync.Lock()
+		skip := append([]*rpcHandle(nil), tried...)
+		triedSync.Unlock()
+
+		h, err := slf.waitPool(ctx, skip)
+		if err != nil {
+			results <- hedgeResult{_err: err, _hedge: hedge}
+			return
+		}
+
+		triedSync.Lock()
+		tried = append(tried, h)
+		triedSync.Unlock()
+
+		r, err := slf._call(ctx, h, method, param, true)
+		results <- hedgeResult{_reply: r, _err: err, _hedge: hedge}
+	}
+
+	go attempt(false)
+	sent, pending := 1, 1
+	delay := time.NewTimer(time.Duration(policy.Delay) * time.Millisecond)
+	defer delay.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case res := <-results:
+			pending--
+			if res._err == nil {
+				if res._hedge {
+					atomic.AddUint64(&slf._hedgeWins, 1)
+				}
+				return res._reply, nil
+			}
+
+			lastErr = res._err
+			if !unavailable(res._err) && res._err != code.ErrCircuitOpen {
+				return nil, lastErr
+			}
+
+			if sent < policy.MaxAttempts && ctx.Err() == nil {
+				go attempt(false)
+				sent++
+				pending++
+				atomic.AddUint64(&slf._resent, 1)
+				continue
+			}
+
+			if pending == 0 {
+				return nil, lastErr
+			}
+		case <-delay.C:
+			if sent < policy.MaxAttempts {
+				go attempt(true)
+				sent++
+				pending++
+				atomic.AddUint64(&slf._hedged, 1)
+				delay.Reset(time.Duration(policy.Delay) * time.Millisecond)
+			}
+		}
+	}
+}
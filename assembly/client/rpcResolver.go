@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//ConstDNSInterval default dns resolve interval/millsecond
+	ConstDNSInterval = 1000 * 30
+	//ConstFileInterval default file check interval/millsecond
+	ConstFileInterval = 1000
+)
+
+//Resolver doc
+//@Summary Service discovery of the pool endpoints
+//@Method Resolve reports the endpoint address sets by the update until the
+//@Method         context is done, the set replaces the previous one, the transient
+//@Method         errors keep the previous set
+type Resolver interface {
+	Resolve(ctx context.Context, update func(addrs []string)) error
+}
+
+//StaticResolver doc
+//@Summary Reports a static address list once
+type StaticResolver struct {
+	_addrs []string
+}
+
+//NewStaticResolver doc
+//@Summary Returns a resolver of the addresses
+//@Param  ...string addresses
+//@Return *StaticResolver
+func NewStaticResolver(addrs ...string) *StaticResolver {
+	return &StaticResolver{_addrs: addrs}
+}
+
+//Resolve doc
+//@Summary Reports the addresses and waits the context done
+//@Param  context.Context
+//@Param  func([]string) update
+//@Return error
+func (slf *StaticResolver) Resolve(ctx context.Context, update func(addrs []string)) error {
+	update(slf._addrs)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+//DNSResolver doc
+//@Summary Resolves the A/AAAA records of a host or the SRV records of a service,
+//@Summary polled at the interval
+//@Member string host name, SRV domain
+//@Member int    port of the A/AAAA records
+//@Member string SRV service, empty A/AAAA records
+//@Member string SRV protocol
+//@Member int64  resolve interval/millsecond
+//@Member *net.Resolver
+type DNSResolver struct {
+	_host     string
+	_port     int
+	_service  string
+	_proto    string
+	_interval int64
+	_resolver *net.Resolver
+}
+
+//NewDNSResolver doc
+//@Summary Returns a resolver of the A/AAAA records of the host
+//@Param  string host name
+//@Param  int    port
+//@Return *DNSResolver
+func NewDNSResolver(host string, port int) *DNSResolver {
+	return &DNSResolver{_host: host, _port: port, _interval: ConstDNSInterval, _resolver: net.DefaultResolver}
+}
+
+//NewSRVResolver doc
+//@Summary Returns a resolver of the SRV records _service._proto.name
+//@Param  string service
+//@Param  string protocol, tcp
+//@Param  string domain name
+//@Return *DNSResolver
+func NewSRVResolver(service, proto, name string) *DNSResolver {
+	return &DNSResolver{_host: name, _service: service, _proto: proto, _interval: ConstDNSInterval, _resolver: net.DefaultResolver}
+}
+
+//WithInterval doc
+//@Summary Set resolve interval/millsecond
+//@Param  int64 interval
+//@Return *DNSResolver
+func (slf *DNSResolver) WithInterval(interval int64) *DNSResolver {
+	slf._interval = interval
+	return slf
+}
+
+//Resolve doc
+//@Summary Reports the resolved addresses when changed, until the context is done
+//@Param  context.Context
+//@Param  func([]string) update
+//@Return error
+func (slf *DNSResolver) Resolve(ctx context.Context, update func(addrs []string)) error {
+	var last []string
+	for {
+		addrs, err := slf.lookup(ctx)
+		if err == nil && len(addrs) > 0 && !sameAddrs(addrs, last) {
+			last = addrs
+			update(addrs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(slf._interval) * time.Millisecond):
+		}
+	}
+}
+
+func (slf *DNSResolver) lookup(ctx context.Context) ([]string, error) {
+	var addrs []string
+	if slf._service != "" {
+		_, srvs, err := slf._resolver.LookupSRV(ctx, slf._service, slf._proto, slf._host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range srvs {
+			addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(v.Target, "."), strconv.Itoa(int(v.Port))))
+		}
+	} else {
+		ips, err := slf._resolver.LookupHost(ctx, slf._host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range ips {
+			addrs = append(addrs, net.JoinHostPort(v, strconv.Itoa(slf._port)))
+		}
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+//FileResolver doc
+//@Summary Watches a file of the addresses, one host:port a line, # comments,
+//@Summary the file is checked at the interval, replace the file by an atomic
+//@Summary rename, a file being written may be read half
+//@Member string file path
+//@Member int64  check interval/millsecond
+type FileResolver struct {
+	_path     string
+	_interval int64
+}
+
+//NewFileResolver doc
+//@Summary Returns a resolver of the address file
+//@Param  string file path
+//@Return *FileResolver
+func NewFileResolver(path string) *FileResolver {
+	return &FileResolver{_path: path, _interval: ConstFileInterval}
+}
+
+//WithInterval doc
+//@Summary Set check interval/millsecond
+//@Param  int64 interval
+//@Return *FileResolver
+func (slf *FileResolver) WithInterval(interval int64) *FileResolver {
+	slf._interval = interval
+	return slf
+}
+
+//Resolve doc
+//@Summary Reports the addresses of the file when changed, until the context is done,
+//@Summary an unreadable file, a file of no address or of a line not host:port
+//@Summary keeps the previous addresses
+//@Param  context.Context
+//@Param  func([]string) update
+//@Return error
+func (slf *FileResolver) Resolve(ctx context.Context, update func(addrs []string)) error {
+	var last []byte
+	for {
+		data, err := ioutil.ReadFile(slf._path)
+		if err == nil && (last == nil || !bytes.Equal(data, last)) {
+			if addrs, err := parseAddrs(data); err == nil && len(addrs) > 0 {
+				last = data
+				update(addrs)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(slf._interval) * time.Millisecond):
+		}
+	}
+}
+
+//parseAddrs doc
+//@Summary Returns the addresses of the file data, error a line not host:port
+func parseAddrs(data []byte) ([]string, error) {
+	addrs := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("rpc endpoint %s missing host or port", line)
+		}
+		addrs = append(addrs, line)
+	}
+	return addrs, scanner.Err()
+}
+
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
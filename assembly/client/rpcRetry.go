@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//RetryPolicy doc
+//@Summary Retry policy of the idempotent methods, the attempts are made on another
+//@Summary connection or endpoint when possible, within the call deadline
+//@Member int     attempts max, the first call included
+//@Member int64   backoff after the first attempt/millsecond, doubled by each attempt
+//@Member int64   backoff max/millsecond
+//@Member []int32 retriable error codes, the connection errors are code.CodeUnavailable
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     int64
+	BackoffMax  int64
+	Codes       []int32
+}
+
+//DefaultRetryPolicy retries the unavailable connections 3 attempts
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: 50, BackoffMax: 1000, Codes: []int32{code.CodeUnavailable}}
+
+//retriable doc
+//@Summary Returns whether the error code is retriable, the context errors never are
+func (slf *RetryPolicy) retriable(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded || err == code.ErrTimeOut {
+		return false
+	}
+
+	var c int32
+	if e, ok := err.(*code.RPCError); ok {
+		c = e.Code
+	} else if unavailable(err) {
+		c = code.CodeUnavailable
+	} else {
+		return false
+	}
+
+	for _, v := range slf.Codes {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+//unavailable doc
+//@Summary Returns whether the error is a connection error
+func unavailable(err error) bool {
+	switch err {
+	case code.ErrConnectClosed, code.ErrConnectNon, code.ErrConnectFull,
+		code.ErrConnectNoAvailable, code.ErrEndpointDown:
+		return true
+	}
+
+	_, ok := err.(net.Error)
+	return ok
+}
+
+//retryPolicy doc
+//@Summary Returns the retry policy of the method, the method policy or the default policy,
+//@Summary nil the method is not idempotent
+func (slf *RPCClientPool) retryPolicy(method string) *RetryPolicy {
+	if !slf._opts.Idempotent[method] {
+		return nil
+	}
+
+	if p, ok := slf._opts.Retry[method]; ok {
+		return &p
+	}
+
+	if p, ok := slf._opts.Retry[""]; ok {
+		return &p
+	}
+	return &DefaultRetryPolicy
+}
+
+//invokeRetry doc
+//@Summary Call with the retry policy of the method, the attempts share the call deadline,
+//@Summary the pool time out when the context has none
+func (slf *RPCClientPool) invokeRetry(ctx context.Context, method string, param proto.Message, wait bool) (proto.Message, error) {
+	policy := slf.retryPolicy(method)
+	if policy == nil || policy.MaxAttempts <= 1 {
+		r, _, err := slf.invoke(ctx, method, param, wait, nil)
+		return r, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(slf._opts.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	var tried []*rpcHandle
+	for attempt := 1; ; attempt++ {
+		r, h, err := slf.invoke(ctx, method, param, wait, tried)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retriable(err) {
+			return r, err
+		}
+
+		if h != nil {
+			tried = append(tried, h)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(time.Duration(backoff(attempt-1, policy.Backoff, policy.BackoffMax)) * time.Millisecond):
+		}
+	}
+}
+
+func triedHandle(tried []*rpcHandle, h *rpcHandle) bool {
+	for _, v := range tried {
+		if v == h {
+			return true
+		}
+	}
+	return false
+}
+
+func triedEndpoint(tried []*rpcHandle, ep *Endpoint) bool {
+	for _, v := range tried {
+		if v._endpoint == ep {
+			return true
+		}
+	}
+	return false
+}
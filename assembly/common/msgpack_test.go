@@ -0,0 +1,148 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	array16 := make([]interface{}, 16)
+	for i := range array16 {
+		array16[i] = json.Number(strconv.Itoa(i))
+	}
+
+	map16 := make(map[string]interface{}, 16)
+	for i := 0; i < 16; i++ {
+		map16[strconv.Itoa(i)] = strings.Repeat("v", i)
+	}
+
+	cases := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{false, false},
+		{json.Number("0"), int64(0)},
+		{json.Number("127"), int64(127)},
+		{json.Number("128"), int64(128)},
+		{json.Number("-1"), int64(-1)},
+		{json.Number("-32"), int64(-32)},
+		{json.Number("-33"), int64(-33)},
+		{json.Number("2147483647"), int64(math.MaxInt32)},
+		{json.Number("-2147483648"), int64(math.MinInt32)},
+		{json.Number("2147483648"), int64(math.MaxInt32 + 1)},
+		{json.Number("9223372036854775807"), int64(math.MaxInt64)},
+		{json.Number("-9223372036854775808"), int64(math.MinInt64)},
+		{json.Number("1.5"), 1.5},
+		{json.Number("1e300"), 1e300},
+		{"", ""},
+		{strings.Repeat("s", 31), strings.Repeat("s", 31)},
+		{strings.Repeat("s", 32), strings.Repeat("s", 32)},
+		{strings.Repeat("s", 256), strings.Repeat("s", 256)},
+		{strings.Repeat("s", 65536), strings.Repeat("s", 65536)},
+		{[]interface{}{}, []interface{}{}},
+		{array16, func() interface{} {
+			r := make([]interface{}, 16)
+			for i := range r {
+				r[i] = int64(i)
+			}
+			return r
+		}()},
+		{map16, map16},
+		{map[string]interface{}{"a": []interface{}{json.Number("1"), "b", nil},
+			"c": map[string]interface{}{"d": true}},
+			map[string]interface{}{"a": []interface{}{int64(1), "b", nil},
+				"c": map[string]interface{}{"d": true}}},
+	}
+
+	for _, c := range cases {
+		data, err := msgpackAppend(nil, c.in)
+		if err != nil {
+			t.Fatalf("%v:%v", c.in, err)
+		}
+
+		v, n, err := msgpackRead(data, 0)
+		if err != nil {
+			t.Fatalf("%v:%v", c.in, err)
+		}
+
+		if n != len(data) {
+			t.Fatalf("%v read %d of %d", c.in, n, len(data))
+		}
+
+		if !reflect.DeepEqual(v, c.want) {
+			t.Fatalf("%v read %#v", c.in, v)
+		}
+	}
+
+	if _, err := msgpackAppend(nil, 1); err == nil {
+		t.Fatal("unsupported type appended")
+	}
+}
+
+func TestMsgpackRead(t *testing.T) {
+	cases := []struct {
+		data []byte
+		want interface{}
+	}{
+		{[]byte{0xcc, 0xff}, uint64(0xff)},
+		{[]byte{0xcd, 0xff, 0xff}, uint64(0xffff)},
+		{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+		{[]byte{0xd0, 0x80}, int64(-128)},
+		{[]byte{0xd1, 0x80, 0x00}, int64(math.MinInt16)},
+		{[]byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, 1.5},
+		{[]byte{0xc4, 0x03, 'a', 'b', 'c'}, "YWJj"},
+		{[]byte{0xd9, 0x01, 'a'}, "a"},
+		{[]byte{0xdc, 0x00, 0x01, 0xc0}, []interface{}{nil}},
+		{[]byte{0x81, 0x01, 0xc3}, map[string]interface{}{"1": true}},
+	}
+
+	for _, c := range cases {
+		v, n, err := msgpackRead(c.data, 0)
+		if err != nil || n != len(c.data) || !reflect.DeepEqual(v, c.want) {
+			t.Fatalf("%x read %#v %d:%v", c.data, v, n, err)
+		}
+	}
+}
+
+func TestMsgpackMalformed(t *testing.T) {
+	data, err := msgpackAppend(nil, map[string]interface{}{"a": []interface{}{json.Number("1000000"), "text", json.Number("2.5")},
+		"b": strings.Repeat("s", 300)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(data); i++ {
+		if _, _, err := msgpackRead(data[:i], 0); err == nil {
+			t.Fatalf("truncated at %d of %d read", i, len(data))
+		}
+	}
+
+	cases := map[string][]byte{
+		//never used tag
+		"tag": {0xc1},
+		//ext unsupported
+		"ext": {0xd4, 0x01, 0x02},
+		//array longer than the data
+		"array": {0xdd, 0xff, 0xff, 0xff, 0xff},
+		//map longer than the data
+		"map": {0xdf, 0x7f, 0xff, 0xff, 0xff, 0xc0},
+		//string longer than the data
+		"string": {0xdb, 0x00, 0x01, 0x00, 0x00, 'a'},
+		//binary longer than the data
+		"binary": {0xc6, 0xff, 0xff, 0xff, 0xff},
+		//fixmap value missing
+		"value": {0x81, 0xa1, 'a'},
+	}
+
+	for name, data := range cases {
+		if _, _, err := msgpackRead(data, 0); err == nil {
+			t.Fatalf("%s read", name)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstAuthName rpc authentication handshake data name
+	ConstAuthName = "magicRpc.Auth"
+
+	constAuthChallengeSize = 32
+)
+
+//Authenticator doc
+//@Summary RPC connection authenticator of the handshake, the server sends
+//@Summary a challenge, the client replies its identity and proof, the server verifies
+//@Method Name      authenticator name, must be the same on both sides
+//@Method Challenge server side, returns a new challenge of the connection
+//@Method Response  client side, returns the identity and the proof of the challenge
+//@Method Verify    server side, verifies the proof of the identity
+type Authenticator interface {
+	Name() string
+	Challenge() ([]byte, error)
+	Response(challenge []byte) (string, []byte, error)
+	Verify(challenge []byte, identity string, proof []byte) error
+}
+
+//TokenAuth doc
+//@Summary Shared token authenticator, the token is sent as the proof, use with TLS
+//@Member string client identity
+//@Member string token, client proof and server shared token
+//@Member func(string)(string, bool) server side token of the identity, nil the shared token
+type TokenAuth struct {
+	Identity string
+	Token    string
+	Tokens   func(identity string) (string, bool)
+}
+
+//Name doc
+//@Summary Returns authenticator name
+//@Return string
+func (slf *TokenAuth) Name() string {
+	return "token"
+}
+
+//Challenge doc
+//@Summary Returns a random challenge
+//@Return []byte
+//@Return error
+func (slf *TokenAuth) Challenge() ([]byte, error) {
+	return authChallenge()
+}
+
+//Response doc
+//@Summary Returns the identity and the token
+//@Param  []byte challenge
+//@Return string
+//@Return []byte
+//@Return error
+func (slf *TokenAuth) Response(challenge []byte) (string, []byte, error) {
+	return slf.Identity, []byte(slf.Token), nil
+}
+
+//Verify doc
+//@Summary Verify the token of the identity
+//@Param  []byte challenge
+//@Param  string identity
+//@Param  []byte token
+//@Return error
+func (slf *TokenAuth) Verify(challenge []byte, identity string, proof []byte) error {
+	token := slf.Token
+	if slf.Tokens != nil {
+		var ok bool
+		if token, ok = slf.Tokens(identity); !ok {
+			return code.ErrUnauthenticated
+		}
+	}
+
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), proof) != 1 {
+		return code.ErrUnauthenticated
+	}
+	return nil
+}
+
+//HMACAuth doc
+//@Summary HMAC-SHA256 challenge-response authenticator, the key never travels
+//@Member string client identity
+//@Member []byte key, client key and server shared key
+//@Member func(string)([]byte, bool) server side key of the identity, nil the shared key
+type HMACAuth struct {
+	Identity string
+	Key      []byte
+	Keys     func(identity string) ([]byte, bool)
+}
+
+//Name doc
+//@Summary Returns authenticator name
+//@Return string
+func (slf *HMACAuth) Name() string {
+	return "hmac-sha256"
+}
+
+//Challenge doc
+//@Summary Returns a random challenge
+//@Return []byte
+//@Return error
+func (slf *HMACAuth) Challenge() ([]byte, error) {
+	return authChallenge()
+}
+
+//Response doc
+//@Summary Returns the identity and the HMAC of the challenge and identity
+//@Param  []byte challenge
+//@Return string
+//@Return []byte
+//@Return error
+func (slf *HMACAuth) Response(challenge []byte) (string, []byte, error) {
+	return slf.Identity, authHMAC(slf.Key, challenge, slf.Identity), nil
+}
+
+//Verify doc
+//@Summary Verify the HMAC of the challenge and identity
+//@Param  []byte challenge
+//@Param  string identity
+//@Param  []byte proof
+//@Return error
+func (slf *HMACAuth) Verify(challenge []byte, identity string, proof []byte) error {
+	key := slf.Key
+	if slf.Keys != nil {
+		var ok bool
+		if key, ok = slf.Keys(identity); !ok {
+			return code.ErrUnauthenticated
+		}
+	}
+
+	if len(key) == 0 || !hmac.Equal(authHMAC(key, challenge, identity), proof) {
+		return code.ErrUnauthenticated
+	}
+	return nil
+}
+
+func authChallenge() ([]byte, error) {
+	challenge := make([]byte, constAuthChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func authHMAC(key, challenge []byte, identity string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(challenge)
+	mac.Write([]byte(identity))
+	return mac.Sum(nil)
+}
+
+//rpcAuth doc
+//@Summary RPC authentication handshake message
+//@Member []byte server challenge
+//@Member string client identity
+//@Member []byte client proof
+//@Member int32  result code
+//@Member string result message
+type rpcAuth struct {
+	Challenge []byte `protobuf:"bytes,1,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	Identity  string `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
+	Proof     []byte `protobuf:"bytes,3,opt,name=proof,proto3" json:"proof,omitempty"`
+	Code      int32  `protobuf:"varint,4,opt,name=code,proto3" json:"code,omitempty"`
+	Message   string `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (m *rpcAuth) Reset()         { *m = rpcAuth{} }
+func (m *rpcAuth) String() string { return proto.CompactTextString(m) }
+func (*rpcAuth) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*rpcAuth)(nil), ConstAuthName)
+}
+
+//EncodeAuth doc
+//@Summary Encode authentication handshake frame, the challenge and the proof
+//@Summary are requests, the result is a response
+//@Param  int        version
+//@Param  *AuthEvent handshake message
+//@Return []byte
+//@Return error
+func EncodeAuth(ver int, event *AuthEvent) ([]byte, error) {
+	data, err := proto.Marshal(&rpcAuth{Challenge: event.Challenge,
+		Identity: event.Identity,
+		Proof:    event.Proof,
+		Code:     event.Code,
+		Message:  event.Message})
+	if err != nil {
+		return nil, err
+	}
+	return Encode(ver, event.Name, 0, event.Oper, ConstAuthName, data)
+}
+
+func authDecode(block *Block) (interface{}, error) {
+	msg := &rpcAuth{}
+	if err := proto.Unmarshal(block.Data, msg); err != nil {
+		return nil, err
+	}
+
+	return &AuthEvent{Name: block.Method,
+		Oper:      block.Oper,
+		Ver:       block.Ver,
+		Identity:  msg.Identity,
+		Challenge: msg.Challenge,
+		Proof:     msg.Proof,
+		Code:      msg.Code,
+		Message:   msg.Message}, nil
+}
@@ -2,7 +2,6 @@ package common
 
 import (
 	"encoding/binary"
-	"unicode/utf8"
 
 	"github.com/yamakiller/magicNet/handler/net"
 	"github.com/yamakiller/magicRpc/code"
@@ -19,6 +18,12 @@ const (
 	constVersionStart = 0
 	//version mask
 	constVersionMask = 0x7F
+	//version number mask, the high 3 bit of the version field is the compressor id
+	constVersionNumberMask = 0x0F
+	//compressor id mask
+	constCompressMask = 0x07
+	//compressor id shift
+	constCompressShift = constVersionShift + 4
 	//version shift
 	constVersionShift = constHeadSize - constVersionSize
 	//oper size
@@ -63,13 +68,28 @@ const (
 	constSerialShift = constDataNameLengthShift - constSerialSize
 	//rpc name length limit
 	constNameLimit = 65
+	//version 2 data length size 32 bit, follows the head
+	constDataLengthV2Byte = 4
+	//version 2 data head byte size
+	constHeadByteV2 = constHeadByte + constDataLengthV2Byte
+	//version 2 data length mask
+	constDataLengthV2Mask = 0xFFFFFFFF
 )
 
 const (
-	//ConstVersion rpc agee version code
+	//ConstVersion rpc agee version code, 16 bit data length
 	ConstVersion = 1
+	//ConstVersion2 rpc agee version code, 32 bit data length
+	ConstVersion2 = 2
+	//ConstVersionMax rpc agee highest supported version code
+	ConstVersionMax = ConstVersion2
 	//ConstHandShakeCode rpc handshake code
 	ConstHandShakeCode = 0xBF
+	//ConstHandShakeAuthCode rpc handshake code, the connection must authenticate
+	ConstHandShakeAuthCode = 0xBE
+	//ConstMaxFrameSize default frame size limit of the received frames, the data
+	//decompressed included
+	ConstMaxFrameSize = 1024 * 1024 * 4
 )
 
 //Header data header
@@ -92,6 +112,10 @@ const (
 //@Member string    method name
 //@Member int       param  data length
 //@Member uint32    call serial of number
+//@Member int64     call remaining time out/millsecond, 0 no time out
+//@Member map[string]string request metadata or response trailers
+//@Member int       data compressor id, 0 raw
+//@Member string    data codec name, empty protobuf
 type Block struct {
 	Ver      int
 	Oper     RPCOper
@@ -99,10 +123,18 @@ type Block struct {
 	Ser      uint32
 	DataName string
 	Data     []byte
+	Timeout  int64
+	Metadata map[string]string
+	Compress int
+	Codec    string
 }
 
 func getVersion(d uint64) int {
-	return int((d >> constVersionShift) & constVersionMask)
+	return int((d >> constVersionShift) & constVersionNumberMask)
+}
+
+func getCompress(d uint64) int {
+	return int((d >> constCompressShift) & constCompressMask)
 }
 
 func getOper(d uint64) int {
@@ -129,44 +161,88 @@ func getSerial(d uint64) uint32 {
 //-------------------------------------------------------------------------------------------------------------------------------------------------------
 //  7 Bit Version  | 1 Bit Operation mode| 16 Bit Data length | 6 Bit Method name length | 6 Bit data name length | 28 Bit Serial Number | data packet |
 //------------------------------------------------------------------------------------------------------------------------------------------------------
+//Version 2: the 16 Bit Data length is 0, a 32 Bit Data length follows the 64 Bit header
+//-------------------------------------------------------------------------------------------------------------------------------------------------------
+//Version field: 3 Bit Compressor id, 0 raw | 4 Bit Version number
+//-------------------------------------------------------------------------------------------------------------------------------------------------------
+//  64 Bit header(Version 2) | 32 Bit Data length | data packet |
+//-------------------------------------------------------------------------------------------------------------------------------------------------------
 //=======================================================================================================================================================
 
+//VersionLimit doc
+//@Summary Returns data length limit of the version
+//@Param  int version
+//@Return int
+func VersionLimit(ver int) int {
+	if ver == ConstVersion2 {
+		return constDataLengthV2Mask
+	}
+	return constDataLengthMask
+}
+
 //Decode doc
 //@Summary rpc network data decode
 //@Method Decode
 //@Param  *bytes.Buffer   recvice data buffer
+//@Param  int             frame size limit, the data decompressed included, 0 ConstMaxFrameSize
 //@Return *Block network data block
 //@Return error
-func Decode(data net.INetReceiveBuffer) (*Block, error) {
+func Decode(data net.INetReceiveBuffer, limit int) (*Block, error) {
+	if limit <= 0 {
+		limit = ConstMaxFrameSize
+	}
+
 	if data.GetBufferLen() < constHeadByte {
 		return nil, code.ErrIncompleteData
 	}
 
+	tmpHeadByte := constHeadByte
 	tmpHeader := binary.BigEndian.Uint64(data.GetBufferBytes()[:constHeadByte])
 	tmpDataLength := getDataLength(tmpHeader)
 	tmpMethodNameLength := getMethodLength(tmpHeader)
 	tmpDataNameLength := getDataNameLength(tmpHeader)
 
-	if data.GetBufferLen() < (constHeadByte + tmpDataLength + tmpMethodNameLength + tmpDataNameLength) {
-		return nil, code.ErrIncompleteData
+	switch getVersion(tmpHeader) {
+	case ConstVersion:
+	case ConstVersion2:
+		tmpHeadByte = constHeadByteV2
+		if data.GetBufferLen() < tmpHeadByte {
+			return nil, code.ErrIncompleteData
+		}
+		tmpDataLength = int(binary.BigEndian.Uint32(data.GetBufferBytes()[constHeadByte:tmpHeadByte]))
+	default:
+		return nil, code.ErrVersionUnsupported
 	}
 
 	if tmpMethodNameLength > constNameLimit || tmpDataNameLength > constNameLimit {
 		return nil, code.ErrMethodName
 	}
 
-	if (constHeadByte + tmpDataLength + tmpMethodNameLength + tmpDataNameLength) > (data.GetBufferCap() << 1) {
+	if (tmpHeadByte + tmpDataLength + tmpMethodNameLength + tmpDataNameLength) > limit {
 		return nil, code.ErrDataOverflow
 	}
 
-	data.TrunBuffer(constHeadByte)
+	if data.GetBufferLen() < (tmpHeadByte + tmpDataLength + tmpMethodNameLength + tmpDataNameLength) {
+		return nil, code.ErrIncompleteData
+	}
+
+	data.TrunBuffer(tmpHeadByte)
 
 	result := &Block{Ver: getVersion(tmpHeader),
 		Oper:     RPCOper(getOper(tmpHeader)),
 		Method:   string(data.ReadBuffer(tmpMethodNameLength)),
 		DataName: string(data.ReadBuffer(tmpDataNameLength)),
 		Ser:      getSerial(tmpHeader),
-		Data:     data.ReadBuffer(tmpDataLength)}
+		Data:     data.ReadBuffer(tmpDataLength),
+		Compress: getCompress(tmpHeader)}
+
+	if result.Compress != 0 {
+		tmpData, err := decompress(result.Compress, result.Data, limit)
+		if err != nil {
+			return nil, err
+		}
+		result.Data = tmpData
+	}
 
 	return result, nil
 }
@@ -181,28 +257,61 @@ func Decode(data net.INetReceiveBuffer) (*Block, error) {
 //@Param  string	param name/return name
 //@Param  []byte    params/return
 //@Return []byte
-func Encode(ver int, methodName string, ser uint32, oper RPCOper, dataName string, data []byte) []byte {
-	tmpMethodNameLength := utf8.RuneCountInString(methodName)
-	tmpDataNameLength := utf8.RuneCountInString(dataName)
-	tmpDataLength := 0
-	if data != nil {
-		tmpDataLength = len(data)
+//@Return error     data length exceeds the version limit
+func Encode(ver int, methodName string, ser uint32, oper RPCOper, dataName string, data []byte) ([]byte, error) {
+	return encode(ver, 0, methodName, ser, oper, dataName, data)
+}
+
+func encode(ver int, compress int, methodName string, ser uint32, oper RPCOper, dataName string, data []byte) ([]byte, error) {
+	tmpMethodNameLength := len(methodName)
+	tmpDataNameLength := len(dataName)
+	tmpDataLength := len(data)
+	if tmpMethodNameLength > constMethodNameLengthMask || tmpDataNameLength > constDataNameLengthMask {
+		return nil, code.ErrMethodName
+	}
+
+	if tmpDataLength > VersionLimit(ver) {
+		return nil, code.ErrDataTooLarge
+	}
+
+	tmpHeadByte := constHeadByte
+	tmpHeadDataLength := tmpDataLength
+	if ver == ConstVersion2 {
+		tmpHeadByte = constHeadByteV2
+		tmpHeadDataLength = 0
 	}
-	tmpData := make([]byte, constHeadByte)
-	tmpHeader := ((uint64(ver) & constVersionMask) << constVersionShift) |
+
+	tmpData := make([]byte, tmpHeadByte, tmpHeadByte+tmpMethodNameLength+tmpDataNameLength+tmpDataLength)
+	tmpHeader := ((uint64(ver) & constVersionNumberMask) << constVersionShift) |
+		((uint64(compress) & constCompressMask) << constCompressShift) |
 		((uint64(oper) & constOperMask) << constOperShift) |
-		((uint64(tmpDataLength) & constDataLengthMask) << constDataLengthShift) |
+		((uint64(tmpHeadDataLength) & constDataLengthMask) << constDataLengthShift) |
 		((uint64(tmpMethodNameLength) & constMethodNameLengthMask) << constMethodNameLengthShift) |
 		((uint64(tmpDataNameLength) & constDataNameLengthMask) << constDataNameLengthShift) |
 		(uint64(ser) & constSerialMask)
 
 	binary.BigEndian.PutUint64(tmpData, tmpHeader)
+	if ver == ConstVersion2 {
+		binary.BigEndian.PutUint32(tmpData[constHeadByte:], uint32(tmpDataLength))
+	}
 
 	tmpData = append(tmpData, []byte(methodName)...)
-	if data != nil {
-		tmpData = append(tmpData, []byte(dataName)...)
-		tmpData = append(tmpData, data...)
-	}
+	tmpData = append(tmpData, []byte(dataName)...)
+	tmpData = append(tmpData, data...)
 
-	return tmpData
+	return tmpData, nil
+}
+
+//SetSerial doc
+//@Summary Returns a copy of the encoded frame with the serial, a frame encoded once
+//@Summary is sent to many peers waiting replies this way
+//@Param  []byte encoded frame
+//@Param  uint32 serial
+//@Return []byte
+func SetSerial(frame []byte, ser uint32) []byte {
+	result := make([]byte, len(frame))
+	copy(result, frame)
+	tmpHeader := binary.BigEndian.Uint64(result)&^constSerialMask | (uint64(ser) & constSerialMask)
+	binary.BigEndian.PutUint64(result, tmpHeader)
+	return result
 }
@@ -1,9 +1,10 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"reflect"
-	"strings"
+	"time"
 
 	"github.com/yamakiller/magicNet/handler/net"
 
@@ -11,35 +12,70 @@ import (
 	"github.com/yamakiller/magicRpc/code"
 )
 
-//GetRPCMethod Return Register rpc method
-type GetRPCMethod func(name string) interface{}
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
 
-//MethodSplit Return class.method
-func methodSplit(name string) []string {
-	return strings.Split(name, ".")
-}
+//GetRPCMethod Return Register rpc service
+type GetRPCMethod func(name string) *RPCService
 
 //Call Run Remote function
 func Call(method string, param interface{}) ([]byte, error) {
+	return CallRequest(ConstVersion, ConstLegacyFeatures, method, 0, param, 0, nil, nil)
+}
+
+//CallRequest doc
+//@Summary Encode Remote function request, the time out, the metadata and the codec travel with
+//@Summary the request when the peer settled the features, a peer without the extended requests
+//@Summary gets the plain request without the time out and the metadata
+//@Param  int         version
+//@Param  uint32      features settled with the peer
+//@Param  string      method
+//@Param  uint32      serial, 0 non-return
+//@Param  interface{} param
+//@Param  int64       remaining time out/millsecond, 0 no time out
+//@Param  Metadata    request metadata, nil none
+//@Param  Codec       param codec, nil protobuf
+//@Return []byte
+//@Return error       code.ErrFeatureUnsupported a codec to a peer without the extended requests
+func CallRequest(ver int, features uint32, method string, ser uint32, param interface{}, timeout int64, md Metadata, codec Codec) ([]byte, error) {
+	if features&FeatureExtend == 0 {
+		if codecName(codec) != "" {
+			return nil, code.ErrFeatureUnsupported
+		}
+		timeout = 0
+	}
+
+	if features&FeatureMetadata == 0 {
+		md = nil
+	}
+
 	var data []byte
 	var err error
 	var dataName string
 	if param != nil {
-		data, err = proto.Marshal(param.(proto.Message))
+		data, err = marshal(codec, param.(proto.Message))
 		if err != nil {
 			return nil, err
 		}
 		dataName = proto.MessageName(param.(proto.Message))
 	}
 
-	data = Encode(ConstVersion, method, 0, RPCRequest, dataName, data)
+	if timeout > 0 || len(md) > 0 || codecName(codec) != "" {
+		data, err = encodeExtend(timeout, dataName, data, md, codecName(codec))
+		if err != nil {
+			return nil, err
+		}
+		dataName = ConstExtendName
+	}
 
-	return data, nil
+	return Encode(ver, method, ser, RPCRequest, dataName, data)
 }
 
 func rpcDecode(rpcGet GetRPCMethod,
-	bf net.INetReceiveBuffer) (*Block, interface{}, proto.Message, error) {
-	block, err := Decode(bf)
+	bf net.INetReceiveBuffer, limit int) (*Block, *RPCMethod, proto.Message, error) {
+	block, err := Decode(bf, limit)
 	if err != nil {
 		if err == code.ErrIncompleteData {
 			return nil, nil, nil, net.ErrAnalysisProceed
@@ -47,16 +83,21 @@ func rpcDecode(rpcGet GetRPCMethod,
 		return nil, nil, nil, err
 	}
 
-	methodName := methodSplit(block.Method)
-	var mObj interface{}
-	if block.Oper == RPCRequest {
-		mObj = rpcGet(methodName[0])
-		if mObj == nil || len(methodName) != 2 {
-			return nil, nil, nil, code.ErrMethodUndefined
-		}
+	if err := decodeExtend(block); err != nil {
+		return block, nil, nil, code.NewError(code.CodeParamUndefined, err.Error(), nil)
+	}
+
+	if (block.Oper == RPCRequest && block.DataName == ConstCancelName) ||
+		block.DataName == ConstStreamName || block.DataName == ConstAuthName ||
+		isHello(block) {
+		return block, nil, nil, nil
+	}
 
-		if !reflect.ValueOf(mObj).MethodByName(methodName[1]).IsValid() {
-			return nil, nil, nil, code.ErrMethodUndefined
+	var method *RPCMethod
+	if block.Oper == RPCRequest {
+		method = lookupMethod(rpcGet, block.Method)
+		if method == nil || method._stream {
+			return block, nil, nil, code.NewError(code.CodeMethodUndefined, code.ErrMethodUndefined.Error(), nil)
 		}
 	}
 
@@ -64,81 +105,267 @@ func rpcDecode(rpcGet GetRPCMethod,
 	if block.DataName != "" {
 		dt := proto.MessageType(block.DataName)
 		if dt == nil {
-			return nil, nil, nil, code.ErrParamUndefined
+			return block, nil, nil, code.NewError(code.CodeParamUndefined, code.ErrParamUndefined.Error(), nil)
+		}
+
+		codec := GetCodec(block.Codec)
+		if codec == nil {
+			return block, nil, nil, code.NewError(code.CodeParamUndefined, code.ErrCodecUndefined.Error()+":"+block.Codec, nil)
 		}
 
 		data = reflect.New(dt.Elem()).Interface().(proto.Message)
-		if err := proto.Unmarshal(block.Data, data); err != nil {
-			return nil, nil, nil, err
+		if err := codec.Unmarshal(block.Data, data); err != nil {
+			return block, nil, nil, code.NewError(code.CodeParamUndefined, err.Error(), nil)
 		}
 	}
-	return block, mObj, data, nil
+
+	if method != nil && method._param != nil &&
+		(data == nil || reflect.TypeOf(data) != method._param) {
+		return block, nil, nil, code.NewError(code.CodeParamUndefined, code.ErrParamUndefined.Error(), nil)
+	}
+	return block, method, data, nil
 }
 
-//RPCDecodeServer RPC Server decode
+//RPCDecodeServer RPC Server decode, the frames larger than the limit are rejected, 0 ConstMaxFrameSize
 func RPCDecodeServer(rpcGet GetRPCMethod,
-	bf net.INetReceiveBuffer) (interface{}, error) {
-	block, mObj, data, err := rpcDecode(rpcGet, bf)
+	bf net.INetReceiveBuffer, limit int) (interface{}, error) {
+	return rpcDecodeEvent(rpcGet, bf, limit)
+}
+
+//RPCDecodeClient RPC Client decode, the frames larger than the limit are rejected, 0 ConstMaxFrameSize
+func RPCDecodeClient(rpcGet GetRPCMethod,
+	bf net.INetReceiveBuffer, limit int) (interface{}, error) {
+	return rpcDecodeEvent(rpcGet, bf, limit)
+}
+
+//RPCDecodeHandshake RPC Server decode of the connections not authenticated, only
+//the authentication frames are decoded, the others return nil without looking up
+//the method or decoding the data
+func RPCDecodeHandshake(bf net.INetReceiveBuffer, limit int) (interface{}, error) {
+	block, err := Decode(bf, limit)
 	if err != nil {
+		if err == code.ErrIncompleteData {
+			return nil, net.ErrAnalysisProceed
+		}
 		return nil, err
 	}
 
-	return &RequestEvent{block.Method, mObj, data, block.Ser}, nil
+	if block.DataName == ConstAuthName {
+		return authDecode(block)
+	}
+	return nil, nil
 }
 
-//RPCDecodeClient RPC Client decode
-func RPCDecodeClient(rpcGet GetRPCMethod,
-	bf net.INetReceiveBuffer) (interface{}, error) {
-	block, mObj, data, err := rpcDecode(rpcGet, bf)
+func rpcDecodeEvent(rpcGet GetRPCMethod,
+	bf net.INetReceiveBuffer, limit int) (interface{}, error) {
+	block, method, data, err := rpcDecode(rpcGet, bf, limit)
 	if err != nil {
-		return nil, err
+		if block == nil {
+			return nil, err
+		}
+
+		if block.Oper == RPCRequest {
+			return &RequestEvent{MethodName: block.Method, Ser: block.Ser, Ver: block.Ver, Err: err}, nil
+		}
+		return &ResponseEvent{MethodName: block.Method, Ser: block.Ser, Err: err}, nil
 	}
 
 	var result interface{}
-	if block.Oper == RPCRequest {
-		result = &RequestEvent{block.Method, mObj, data, block.Ser}
+	if block.DataName == ConstStreamName {
+		return streamDecode(rpcGet, block)
+	} else if block.DataName == ConstAuthName {
+		return authDecode(block)
+	} else if isHello(block) {
+		return helloDecode(block)
+	} else if block.Oper == RPCRequest && block.DataName == ConstCancelName {
+		result = &CancelEvent{MethodName: block.Method, Ser: block.Ser}
+	} else if block.Oper == RPCRequest {
+		result = &RequestEvent{MethodName: block.Method,
+			Method:   method,
+			Param:    data,
+			Ser:      block.Ser,
+			Ver:      block.Ver,
+			Deadline: blockDeadline(block),
+			Metadata: block.Metadata,
+			Codec:    GetCodec(block.Codec)}
+	} else if msg, ok := data.(*rpcError); ok {
+		result = &ResponseEvent{MethodName: block.Method, Ser: block.Ser, Err: decodeError(msg), Trailer: block.Metadata}
 	} else {
-		result = &ResponseEvent{block.Method, data, block.Ser}
+		result = &ResponseEvent{MethodName: block.Method, Return: data, Ser: block.Ser, Trailer: block.Metadata}
 	}
 	return result, nil
 }
 
 //RPCRequestProcess doc
-//@Summary RPC Request proccess
+//@Summary RPC Request proccess, handler returns proto.Message, error or (proto.Message, error),
+//@Summary handler can take a context.Context first, done when the caller gone,
+//@Summary the context carries the request metadata, the trailers set on it reply with the response,
+//@Summary the connection interceptor chain runs around the method, a method panic is
+//@Summary recovered and replies an internal error unless the connection disables recovery
 //@Method RPCRequest
 //@Param  *event.RequestEvent
 //@Return []byte
 //@Return error
 func RPCRequestProcess(c interface{},
 	sendto func([]byte) error,
-	message interface{}) error {
+	message interface{}) (err error) {
 
 	request := message.(*RequestEvent)
-	methodName := methodSplit(request.MethodName)
-	method := reflect.ValueOf(request.Method).MethodByName(methodName[1])
-	paramNumber := 1
-	if request.Param != nil {
-		paramNumber++
+	if request.Err != nil {
+		return rpcResponseError(sendto, request, request.Err, nil)
 	}
-	params := make([]reflect.Value, paramNumber)
-	params[0] = reflect.ValueOf(c)
-	if request.Param != nil {
-		params[1] = reflect.ValueOf(request.Param)
+
+	if !request.Deadline.IsZero() && time.Now().After(request.Deadline) {
+		return rpcResponseError(sendto, request,
+			code.NewError(code.CodeTimeOut, code.ErrTimeOut.Error(), nil), nil)
 	}
 
-	rs := method.Call(params)
-	if len(rs) > 0 {
+	if recoveryEnabled(c) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = rpcResponseError(sendto, request, recoverPanic(c, request.MethodName, r), nil)
+			}
+		}()
+	}
 
-		msgPb := rs[0].Interface().(proto.Message)
-		data, err := proto.Marshal(msgPb)
-		if err != nil {
-			return fmt.Errorf("RPC Response error:%s  =>  %d[%+v]", request.Method, request.Ser, err)
+	if !hasFeature(c, FeatureMetadata) {
+		request.Metadata = nil
+	}
+
+	var interceptor UnaryServerInterceptor
+	if ui, ok := c.(unaryInterceptor); ok {
+		interceptor = ui.UnaryInterceptor()
+	}
+
+	var trailer Metadata
+	ctx := context.Background()
+	method := request.Method
+	if method._context || interceptor != nil {
+		var cancel context.CancelFunc
+		if rc, ok := c.(requestContexter); ok {
+			ctx, cancel = rc.RequestContext(request)
+		} else {
+			ctx, cancel = requestContext(ctx, request)
+		}
+		defer cancel()
+		if hasFeature(c, FeatureMetadata) {
+			trailer = serverTrailer(ctx)
 		}
+	}
+
+	handler := func(ctx context.Context, param proto.Message) (proto.Message, error) {
+		return rpcInvoke(ctx, c, method, param)
+	}
+
+	var msgPb proto.Message
+	var rerr error
+	if interceptor != nil {
+		msgPb, rerr = interceptor(ctx, request.Param,
+			&UnaryInfo{MethodName: request.MethodName, Ser: request.Ser, Peer: c}, handler)
+	} else {
+		msgPb, rerr = handler(ctx, request.Param)
+	}
+
+	if rerr != nil {
+		return rpcResponseError(sendto, request, rerr, trailer)
+	}
+
+	if method._reply < 0 {
+		return nil
+	}
+
+	if msgPb == nil {
+		return rpcResponseError(sendto, request,
+			code.NewError(code.CodeNoReturn, "RPC method returned nil", nil), trailer)
+	}
+
+	data, err := marshal(request.Codec, msgPb)
+	if err != nil {
+		return rpcResponseError(sendto, request,
+			code.NewError(code.CodeInternal, err.Error(), nil), trailer)
+	}
+
+	data, err = encodeResponse(request.Ver, request.MethodName, request.Ser, proto.MessageName(msgPb), data, trailer, codecName(request.Codec))
+	if err != nil {
+		return rpcResponseError(sendto, request,
+			code.NewError(code.CodeInternal, err.Error(), nil), trailer)
+	}
+
+	if err := sendto(data); err != nil {
+		return fmt.Errorf("RPC Response error:%s  => %d[%+v]", request.MethodName, request.Ser, err)
+	}
+	return nil
+}
+
+//rpcInvoke doc
+//@Summary Invoke the registered method, returns the reply, nil non-return method
+func rpcInvoke(ctx context.Context, c interface{}, method *RPCMethod, param proto.Message) (proto.Message, error) {
+	params := make([]reflect.Value, 0, 3)
+	if method._context {
+		params = append(params, reflect.ValueOf(ctx))
+	}
 
-		data = Encode(ConstVersion, request.MethodName, request.Ser, RPCResponse, proto.MessageName(msgPb), data)
-		if err := sendto(data); err != nil {
-			return fmt.Errorf("RPC Response error:%s  => %d[%+v]", request.Method, request.Ser, err)
+	params = append(params, reflect.ValueOf(c))
+	if method._param != nil {
+		if param == nil || reflect.TypeOf(param) != method._param {
+			return nil, code.NewError(code.CodeParamUndefined, code.ErrParamUndefined.Error(), nil)
 		}
+		params = append(params, reflect.ValueOf(param))
+	}
+
+	rs := method._fn.Call(params)
+	if method._error >= 0 && !rs[method._error].IsNil() {
+		return nil, rs[method._error].Interface().(error)
+	}
+
+	if method._reply < 0 || rs[method._reply].IsNil() {
+		return nil, nil
+	}
+	return rs[method._reply].Interface().(proto.Message), nil
+}
+
+func blockDeadline(block *Block) time.Time {
+	if block.Timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(block.Timeout) * time.Millisecond)
+}
+
+//encodeResponse doc
+//@Summary Encode response, wraps the return with the trailers and the codec when any
+func encodeResponse(ver int, methodName string, ser uint32, dataName string, data []byte, trailer Metadata, codec string) ([]byte, error) {
+	if len(trailer) > 0 || codec != "" {
+		var err error
+		if data, err = encodeExtend(0, dataName, data, trailer, codec); err != nil {
+			return nil, err
+		}
+		dataName = ConstExtendName
+	}
+	return Encode(ver, methodName, ser, RPCResponse, dataName, data)
+}
+
+//hasFeature doc
+//@Summary Returns the connection settled the feature, false the connection does not negotiate
+func hasFeature(c interface{}, feature uint32) bool {
+	f, ok := c.(interface{ Features() uint32 })
+	return ok && f.Features()&feature != 0
+}
+
+func rpcResponseError(sendto func([]byte) error,
+	request *RequestEvent,
+	rerr error,
+	trailer Metadata) error {
+	if request.Ser == 0 {
+		return fmt.Errorf("RPC Request error:%s  =>  %d[%+v]", request.MethodName, request.Ser, rerr)
+	}
+
+	data, err := encodeError(request.Ver, request.MethodName, request.Ser, rerr, trailer)
+	if err != nil {
+		return fmt.Errorf("RPC Response error:%s  =>  %d[%+v]", request.MethodName, request.Ser, err)
+	}
+
+	if err := sendto(data); err != nil {
+		return fmt.Errorf("RPC Response error:%s  => %d[%+v]", request.MethodName, request.Ser, err)
 	}
 	return nil
 }
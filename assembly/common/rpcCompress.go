@@ -0,0 +1,228 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstCompressThreshold default data length below which frames are sent raw
+	ConstCompressThreshold = 1024
+	//compressor id max, 3 bit of the version field
+	constCompressMax = constCompressMask
+)
+
+//Compressor doc
+//@Summary Frame data compressor, the id travels in the frame header
+//@Method ID         compressor id 1-7, must be the same on both sides
+//@Method Name       compressor name of the negotiation
+//@Method Compress   returns the compressed data
+//@Method Decompress returns the decompressed data, not longer than the limit
+type Compressor interface {
+	ID() byte
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte, limit int) ([]byte, error)
+}
+
+var (
+	compressors     [constCompressMax + 1]Compressor
+	compressorsSync sync.RWMutex
+)
+
+func init() {
+	RegisterCompressor(&GzipCompressor{})
+	RegisterCompressor(&SnappyCompressor{})
+}
+
+//RegisterCompressor doc
+//@Summary Register a frame data compressor
+//@Param  Compressor
+//@Return error
+func RegisterCompressor(c Compressor) error {
+	if c.ID() == 0 || c.ID() > constCompressMax {
+		return errors.New("rpc compressor id must be in [1, 7]")
+	}
+
+	compressorsSync.Lock()
+	defer compressorsSync.Unlock()
+	for _, v := range compressors {
+		if v != nil && v.Name() == c.Name() && v.ID() != c.ID() {
+			return errors.New("rpc compressor name registered")
+		}
+	}
+	compressors[c.ID()] = c
+	return nil
+}
+
+//GetCompressor doc
+//@Summary Returns the registered compressor of the name, nil none
+//@Param  string name
+//@Return Compressor
+func GetCompressor(name string) Compressor {
+	compressorsSync.RLock()
+	defer compressorsSync.RUnlock()
+	for _, v := range compressors {
+		if v != nil && v.Name() == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func compressorOf(id int) Compressor {
+	compressorsSync.RLock()
+	defer compressorsSync.RUnlock()
+	return compressors[id]
+}
+
+//NegotiateCompressor doc
+//@Summary Returns the first preferred compressor the peer offers, empty none
+//@Param  []string preferred compressor names
+//@Param  []string offered compressor names
+//@Return string
+func NegotiateCompressor(prefer, offer []string) string {
+	for _, name := range prefer {
+		if GetCompressor(name) == nil {
+			continue
+		}
+
+		for _, v := range offer {
+			if v == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+//CompressFrame doc
+//@Summary Compress the data of an encoded frame, the frame is sent raw when
+//@Summary the data is shorter than the threshold or does not shrink
+//@Param  []byte     encoded frame
+//@Param  Compressor compressor, nil none
+//@Param  int        data length threshold
+//@Return []byte
+//@Return error
+func CompressFrame(frame []byte, c Compressor, threshold int) ([]byte, error) {
+	if c == nil || len(frame) < constHeadByte {
+		return frame, nil
+	}
+
+	tmpHeader := binary.BigEndian.Uint64(frame[:constHeadByte])
+	if getCompress(tmpHeader) != 0 {
+		return frame, nil
+	}
+
+	tmpVer := getVersion(tmpHeader)
+	tmpHeadByte := constHeadByte
+	if tmpVer == ConstVersion2 {
+		tmpHeadByte = constHeadByteV2
+	}
+
+	tmpMethodEnd := tmpHeadByte + getMethodLength(tmpHeader)
+	tmpDataNameEnd := tmpMethodEnd + getDataNameLength(tmpHeader)
+	if len(frame) < tmpDataNameEnd || len(frame)-tmpDataNameEnd < threshold {
+		return frame, nil
+	}
+
+	data, err := c.Compress(frame[tmpDataNameEnd:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) >= len(frame)-tmpDataNameEnd {
+		return frame, nil
+	}
+
+	return encode(tmpVer, int(c.ID()),
+		string(frame[tmpHeadByte:tmpMethodEnd]),
+		getSerial(tmpHeader),
+		RPCOper(getOper(tmpHeader)),
+		string(frame[tmpMethodEnd:tmpDataNameEnd]),
+		data)
+}
+
+func decompress(id int, data []byte, limit int) ([]byte, error) {
+	c := compressorOf(id)
+	if c == nil {
+		return nil, code.ErrCompressorUndefined
+	}
+	return c.Decompress(data, limit)
+}
+
+//GzipCompressor doc
+//@Summary gzip compressor, id 1
+type GzipCompressor struct {
+	_writers sync.Pool
+}
+
+//ID doc
+//@Summary Returns compressor id
+//@Return byte
+func (slf *GzipCompressor) ID() byte {
+	return 1
+}
+
+//Name doc
+//@Summary Returns compressor name
+//@Return string
+func (slf *GzipCompressor) Name() string {
+	return "gzip"
+}
+
+//Compress doc
+//@Summary Returns the gzip data
+//@Param  []byte data
+//@Return []byte
+//@Return error
+func (slf *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, ok := slf._writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&b)
+	} else {
+		w = gzip.NewWriter(&b)
+	}
+	defer slf._writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//Decompress doc
+//@Summary Returns the data of the gzip data
+//@Param  []byte gzip data
+//@Param  int    data length limit
+//@Return []byte
+//@Return error
+func (slf *GzipCompressor) Decompress(data []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > limit {
+		return nil, code.ErrDataOverflow
+	}
+	return result, nil
+}
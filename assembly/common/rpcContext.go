@@ -0,0 +1,188 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstCancelName rpc cancel request data name
+	ConstCancelName = "magicRpc.Cancel"
+)
+
+type requestInfoKey struct{}
+
+//CallCancel doc
+//@Summary Encode cancel request of a waiting call
+//@Param  int    version
+//@Param  string method
+//@Param  uint32 serial of the call
+//@Return []byte
+//@Return error
+func CallCancel(ver int, method string, ser uint32) ([]byte, error) {
+	return Encode(ver, method, ser, RPCRequest, ConstCancelName, nil)
+}
+
+//WaitReturn doc
+//@Summary Wait the response of a call until the context is done, the time out or the stop,
+//@Summary the cancel request is sent when the call is no longer waited by the context or
+//@Summary the time out and the peer takes cancel requests, the response trailers fill
+//@Summary the context trailer
+//@Param  context.Context     context of the call
+//@Param  int                 version
+//@Param  string              method
+//@Param  uint32              serial of the call
+//@Param  chan *ResponseEvent response of the call
+//@Param  int64               time out/millsecond, 0 none
+//@Param  <-chan bool         connection stop, nil none
+//@Param  func([]byte) error  send of the cancel request, nil the peer does not take cancel requests
+//@Return proto.Message
+//@Return error
+func WaitReturn(ctx context.Context, ver int, method string, ser uint32, wait chan *ResponseEvent,
+	timeout int64, stop <-chan bool, sendto func([]byte) error) (proto.Message, error) {
+	var expire <-chan time.Time
+	if timeout > 0 {
+		tm := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+		defer tm.Stop()
+		expire = tm.C
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expire:
+		err = code.ErrTimeOut
+	case <-stop:
+		return nil, code.ErrConnectClosed
+	case result := <-wait:
+		CallTrailer(ctx, result)
+		return result.Return, result.Err
+	}
+
+	if sendto == nil {
+		return nil, err
+	}
+
+	if data, e := CallCancel(ver, method, ser); e == nil {
+		sendto(data)
+	}
+	return nil, err
+}
+
+//RequestInfo doc
+//@Summary RPC request information, carried by the handler context
+//@Member string    Request method name
+//@Member uint32    Request serial
+//@Member time.Time Request caller deadline, zero no deadline
+//@Member Metadata  Request metadata of the caller
+type RequestInfo struct {
+	MethodName string
+	Ser        uint32
+	Deadline   time.Time
+	Metadata   Metadata
+}
+
+//RequestFromContext doc
+//@Summary Returns the request information of the handler context
+//@Param  context.Context
+//@Return *RequestInfo
+//@Return bool
+func RequestFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info, ok
+}
+
+//RPCContexts doc
+//@Summary RPC request contexts of a connection, cancelled when the
+//@Summary connection shutdown or the caller sends a cancel request
+//@Member context.Context            connection context
+//@Member context.CancelFunc         connection context cancel
+//@Member map[uint32]context.CancelFunc running request cancel
+type RPCContexts struct {
+	_ctx    context.Context
+	_cancel context.CancelFunc
+	_calls  map[uint32]context.CancelFunc
+	_sync   sync.Mutex
+}
+
+//InitialContexts doc
+//@Summary Initial connection contexts
+func (slf *RPCContexts) InitialContexts() {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	slf._ctx, slf._cancel = context.WithCancel(context.Background())
+	slf._calls = make(map[uint32]context.CancelFunc)
+}
+
+//ShutdownContexts doc
+//@Summary Cancel all running request contexts
+func (slf *RPCContexts) ShutdownContexts() {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if slf._cancel != nil {
+		slf._cancel()
+		slf._cancel = nil
+	}
+	slf._calls = nil
+}
+
+//CancelRequest doc
+//@Summary Cancel a running request context
+//@Param uint32 request serial
+func (slf *RPCContexts) CancelRequest(ser uint32) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if f, ok := slf._calls[ser]; ok {
+		f()
+		delete(slf._calls, ser)
+	}
+}
+
+//RequestContext doc
+//@Summary Returns a request context, done when the request completes
+//@Param  *RequestEvent
+//@Return context.Context
+//@Return context.CancelFunc
+func (slf *RPCContexts) RequestContext(request *RequestEvent) (context.Context, context.CancelFunc) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	parent := slf._ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := requestContext(parent, request)
+	if request.Ser == 0 || slf._calls == nil {
+		return ctx, cancel
+	}
+
+	ser := request.Ser
+	slf._calls[ser] = cancel
+	return ctx, func() {
+		slf._sync.Lock()
+		delete(slf._calls, ser)
+		slf._sync.Unlock()
+		cancel()
+	}
+}
+
+type requestContexter interface {
+	RequestContext(request *RequestEvent) (context.Context, context.CancelFunc)
+}
+
+func requestContext(parent context.Context, request *RequestEvent) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(parent, requestInfoKey{}, &RequestInfo{MethodName: request.MethodName,
+		Ser:      request.Ser,
+		Deadline: request.Deadline,
+		Metadata: request.Metadata})
+	ctx = context.WithValue(ctx, serverTrailerKey{}, make(Metadata))
+	if request.Deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, request.Deadline)
+}
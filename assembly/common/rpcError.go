@@ -0,0 +1,84 @@
+package common
+
+import (
+	"reflect"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstErrorName rpc error response data name
+	ConstErrorName = "magicRpc.Error"
+)
+
+//rpcError doc
+//@Summary RPC error response message
+//@Member int32  error code
+//@Member string error message
+//@Member string error details data name
+//@Member []byte error details data
+type rpcError struct {
+	Code       int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message    string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	DetailName string `protobuf:"bytes,3,opt,name=detail_name,json=detailName,proto3" json:"detail_name,omitempty"`
+	Detail     []byte `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+}
+
+func (m *rpcError) Reset()         { *m = rpcError{} }
+func (m *rpcError) String() string { return proto.CompactTextString(m) }
+func (*rpcError) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*rpcError)(nil), ConstErrorName)
+}
+
+//EncodeError doc
+//@Summary rpc error response encode
+//@Param  int 		version
+//@Param  string 	method name
+//@Param  uint32    serial
+//@Param  error     error
+//@Return []byte
+//@Return error
+func EncodeError(ver int, methodName string, ser uint32, err error) ([]byte, error) {
+	return encodeError(ver, methodName, ser, err, nil)
+}
+
+func encodeError(ver int, methodName string, ser uint32, err error, trailer Metadata) ([]byte, error) {
+	rerr := code.ToError(err)
+	msg := &rpcError{Code: rerr.Code, Message: rerr.Message}
+	if rerr.Details != nil {
+		detail, err := proto.Marshal(rerr.Details)
+		if err != nil {
+			return nil, err
+		}
+		msg.DetailName = proto.MessageName(rerr.Details)
+		msg.Detail = detail
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeResponse(ver, methodName, ser, ConstErrorName, data, trailer, "")
+}
+
+func decodeError(msg *rpcError) *code.RPCError {
+	result := code.NewError(msg.Code, msg.Message, nil)
+	if msg.DetailName == "" {
+		return result
+	}
+
+	dt := proto.MessageType(msg.DetailName)
+	if dt == nil {
+		return result
+	}
+
+	details := reflect.New(dt.Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(msg.Detail, details); err == nil {
+		result.Details = details
+	}
+	return result
+}
@@ -1,17 +1,31 @@
 package common
 
-import "github.com/gogo/protobuf/proto"
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
 
 //RequestEvent doc
 //@Summary RPC Request event
 //@Member string Request method name
-//@Member interface{} Request method object
+//@Member *RPCMethod  Request method
 //@Member uint32      Request serial
+//@Member error       Request decode error, reply to the caller
+//@Member time.Time   Request caller deadline, zero no deadline
+//@Member int         Request protocol version, the response replies in kind
+//@Member Metadata    Request metadata of the caller
+//@Member Codec       Request param codec, the response replies in kind
 type RequestEvent struct {
 	MethodName string
-	Method     interface{}
+	Method     *RPCMethod
 	Param      proto.Message
 	Ser        uint32
+	Err        error
+	Deadline   time.Time
+	Ver        int
+	Metadata   Metadata
+	Codec      Codec
 }
 
 //ResponseEvent doc
@@ -19,8 +33,96 @@ type RequestEvent struct {
 //@Member string  Request Method Name
 //@Member proto.Message  Request Return Data
 //@Member uint32         Request serial
+//@Member error          Request remote error
+//@Member Metadata       Response trailers
 type ResponseEvent struct {
 	MethodName string
 	Return     proto.Message
 	Ser        uint32
+	Err        error
+	Trailer    Metadata
+}
+
+//CancelEvent doc
+//@Summary RPC Cancel request event
+//@Member string  Request Method Name
+//@Member uint32  Request serial
+type CancelEvent struct {
+	MethodName string
+	Ser        uint32
+}
+
+//StreamEvent doc
+//@Summary RPC Stream frame event
+//@Member string         Stream method name
+//@Member *RPCMethod     Stream method of open frame
+//@Member RPCOper        Frame oper, request from the stream opener
+//@Member uint32         Stream serial
+//@Member int            Frame protocol version
+//@Member int32          Frame flag open/data/end/window
+//@Member int32          Frame window update
+//@Member proto.Message  Frame message
+//@Member time.Time      Stream caller deadline of open frame
+//@Member Metadata       Stream caller metadata of open frame
+//@Member Codec          Frame message codec
+//@Member error          Frame message decode error, the stream fails
+type StreamEvent struct {
+	MethodName string
+	Method     *RPCMethod
+	Oper       RPCOper
+	Ser        uint32
+	Ver        int
+	Flag       int32
+	Window     int32
+	Data       proto.Message
+	Deadline   time.Time
+	Metadata   Metadata
+	Codec      Codec
+	Err        error
+}
+
+//AuthEvent doc
+//@Summary RPC Authentication handshake event, the challenge from the server,
+//@Summary the proof from the client, the result from the server
+//@Member string  Authenticator name
+//@Member RPCOper Frame oper, request the challenge and the proof, response the result
+//@Member int     Frame protocol version
+//@Member string  Client identity
+//@Member []byte  Server challenge
+//@Member []byte  Client proof
+//@Member int32   Result code, 0 accepted
+//@Member string  Result message
+type AuthEvent struct {
+	Name      string
+	Oper      RPCOper
+	Ver       int
+	Identity  string
+	Challenge []byte
+	Proof     []byte
+	Code      int32
+	Message   string
+}
+
+//HelloEvent doc
+//@Summary RPC Version and feature negotiation event, the offer from the server,
+//@Summary the answer from the client, the reject from the server
+//@Member int     Lowest supported version of the offer
+//@Member int     Highest supported version of the offer
+//@Member int     Settled version of the answer
+//@Member uint32  Supported features of the offer, settled features of the answer
+//@Member int32   Reject code, 0 accepted
+//@Member string  Reject message
+//@Member []string Supported compressors of the offer, settled compressor of the answer
+//@Member []string Supported codecs of the offer, settled codec of the answer
+//@Member map[string]string Client tags of the answer
+type HelloEvent struct {
+	MinVersion  int
+	MaxVersion  int
+	Version     int
+	Features    uint32
+	Code        int32
+	Message     string
+	Compressors []string
+	Codecs      []string
+	Tags        map[string]string
 }
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	//ConstExtendName rpc extend request data name
+	ConstExtendName = "magicRpc.Extend"
+)
+
+//rpcExtend doc
+//@Summary RPC extend request message, wraps the param with call information
+//@Member int64  remaining time out/millsecond
+//@Member string param data name
+//@Member []byte param data
+//@Member map[string]string request metadata or response trailers
+//@Member string param data codec name, empty protobuf
+type rpcExtend struct {
+	Timeout  int64             `protobuf:"varint,1,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	DataName string            `protobuf:"bytes,2,opt,name=data_name,json=dataName,proto3" json:"data_name,omitempty"`
+	Data     []byte            `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Codec    string            `protobuf:"bytes,5,opt,name=codec,proto3" json:"codec,omitempty"`
+}
+
+func (m *rpcExtend) Reset()         { *m = rpcExtend{} }
+func (m *rpcExtend) String() string { return proto.CompactTextString(m) }
+func (*rpcExtend) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*rpcExtend)(nil), ConstExtendName)
+}
+
+func encodeExtend(timeout int64, dataName string, data []byte, md Metadata, codec string) ([]byte, error) {
+	return proto.Marshal(&rpcExtend{Timeout: timeout, DataName: dataName, Data: data, Metadata: md, Codec: codec})
+}
+
+func decodeExtend(block *Block) error {
+	if block.DataName != ConstExtendName {
+		return nil
+	}
+
+	msg := &rpcExtend{}
+	if err := proto.Unmarshal(block.Data, msg); err != nil {
+		return err
+	}
+
+	block.Timeout = msg.Timeout
+	block.DataName = msg.DataName
+	block.Data = msg.Data
+	block.Metadata = msg.Metadata
+	block.Codec = msg.Codec
+	return nil
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstHelloName rpc version and feature negotiation method name
+	ConstHelloName = "magicRpc.Hello"
+	//ConstHelloSerial rpc negotiation frame serial, never waited by a peer without negotiation
+	ConstHelloSerial = constSerialMask
+)
+
+const (
+	//FeatureCompression compressed frames
+	FeatureCompression uint32 = 1 << iota
+	//FeatureLargeFrame 32 bit data length frames, version 2
+	FeatureLargeFrame
+	//FeatureStream stream calls
+	FeatureStream
+	//FeatureMetadata metadata headers and trailers
+	FeatureMetadata
+	//FeatureExtend extended requests carrying the deadline and the codec, and cancel requests
+	FeatureExtend
+)
+
+const (
+	//ConstFeatures features supported by this side
+	ConstFeatures = FeatureCompression | FeatureLargeFrame | FeatureStream | FeatureMetadata | FeatureExtend
+	//ConstLegacyFeatures features of a peer without negotiation
+	ConstLegacyFeatures uint32 = 0
+)
+
+//rpcHello doc
+//@Summary RPC version and feature negotiation message
+//@Member int32  lowest supported version
+//@Member int32  highest supported version
+//@Member int32  settled version
+//@Member uint32 supported or settled features
+//@Member int32  reject code, 0 accepted
+//@Member string reject message
+//@Member []string supported or settled compressors
+//@Member []string supported or settled codecs
+//@Member map[string]string client tags
+type rpcHello struct {
+	MinVersion  int32             `protobuf:"varint,1,opt,name=min_version,json=minVersion,proto3" json:"min_version,omitempty"`
+	MaxVersion  int32             `protobuf:"varint,2,opt,name=max_version,json=maxVersion,proto3" json:"max_version,omitempty"`
+	Version     int32             `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Features    uint32            `protobuf:"varint,4,opt,name=features,proto3" json:"features,omitempty"`
+	Code        int32             `protobuf:"varint,5,opt,name=code,proto3" json:"code,omitempty"`
+	Message     string            `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	Compressors []string          `protobuf:"bytes,7,rep,name=compressors,proto3" json:"compressors,omitempty"`
+	Codecs      []string          `protobuf:"bytes,8,rep,name=codecs,proto3" json:"codecs,omitempty"`
+	Tags        map[string]string `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *rpcHello) Reset()         { *m = rpcHello{} }
+func (m *rpcHello) String() string { return proto.CompactTextString(m) }
+func (*rpcHello) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*rpcHello)(nil), ConstHelloName)
+}
+
+//NegotiateVersion doc
+//@Summary Returns the highest version both sides support
+//@Param  int lowest version of this side
+//@Param  int highest version of this side
+//@Param  int lowest version of the peer
+//@Param  int highest version of the peer
+//@Return int
+//@Return error code.ErrVersionUnsupported the ranges do not overlap
+func NegotiateVersion(min, max, peerMin, peerMax int) (int, error) {
+	if peerMax < max {
+		max = peerMax
+	}
+
+	if peerMin > min {
+		min = peerMin
+	}
+
+	if max < min || max < ConstVersion || max > ConstVersionMax {
+		return 0, code.ErrVersionUnsupported
+	}
+	return max, nil
+}
+
+//NegotiateFeatures doc
+//@Summary Returns the features both sides support at the version
+//@Param  int    settled version
+//@Param  uint32 features of this side
+//@Param  uint32 features of the peer
+//@Return uint32
+func NegotiateFeatures(ver int, features, peerFeatures uint32) uint32 {
+	features &= peerFeatures
+	if ver < ConstVersion2 {
+		features &^= FeatureLargeFrame
+	}
+	return features
+}
+
+//EncodeHello doc
+//@Summary Encode negotiation frame, always a version 1 response without data name,
+//@Summary a peer without negotiation takes it for a response nobody waits and drops it
+//@Param  *HelloEvent negotiation message
+//@Return []byte
+//@Return error
+func EncodeHello(event *HelloEvent) ([]byte, error) {
+	data, err := proto.Marshal(&rpcHello{MinVersion: int32(event.MinVersion),
+		MaxVersion:  int32(event.MaxVersion),
+		Version:     int32(event.Version),
+		Features:    event.Features,
+		Code:        event.Code,
+		Message:     event.Message,
+		Compressors: event.Compressors,
+		Codecs:      event.Codecs,
+		Tags:        event.Tags})
+	if err != nil {
+		return nil, err
+	}
+	return Encode(ConstVersion, ConstHelloName, ConstHelloSerial, RPCResponse, "", data)
+}
+
+//isHello doc
+//@Summary Returns the block is a negotiation frame
+//@Param  *Block
+//@Return bool
+func isHello(block *Block) bool {
+	return block.Oper == RPCResponse &&
+		block.DataName == "" &&
+		block.Method == ConstHelloName &&
+		block.Ser == ConstHelloSerial
+}
+
+func helloDecode(block *Block) (interface{}, error) {
+	msg := &rpcHello{}
+	if err := proto.Unmarshal(block.Data, msg); err != nil {
+		return nil, err
+	}
+
+	return &HelloEvent{MinVersion:  int(msg.MinVersion),
+		MaxVersion:  int(msg.MaxVersion),
+		Version:     int(msg.Version),
+		Features:    msg.Features,
+		Code:        msg.Code,
+		Message:     msg.Message,
+		Compressors: msg.Compressors,
+		Codecs:      msg.Codecs,
+		Tags:        msg.Tags}, nil
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"context"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+//UnaryInfo doc
+//@Summary RPC unary call information of the server interceptor
+//@Member string      Request method name
+//@Member uint32      Request serial, 0 non-return
+//@Member interface{} Request connection
+type UnaryInfo struct {
+	MethodName string
+	Ser        uint32
+	Peer       interface{}
+}
+
+//UnaryHandler doc
+//@Summary Invokes the registered method, or the next server interceptor of the chain
+type UnaryHandler func(ctx context.Context, request proto.Message) (proto.Message, error)
+
+//UnaryServerInterceptor doc
+//@Summary Server interceptor of the unary calls, between the decode and the method,
+//@Summary the context carries the request metadata, returns the response and the error
+type UnaryServerInterceptor func(ctx context.Context, request proto.Message, info *UnaryInfo, handler UnaryHandler) (proto.Message, error)
+
+//UnaryInvoker doc
+//@Summary Sends the call to the server, or the next client interceptor of the chain
+type UnaryInvoker func(ctx context.Context, method string, request proto.Message) (proto.Message, error)
+
+//UnaryClientInterceptor doc
+//@Summary Client interceptor of the unary calls, around the call and the wait of the return,
+//@Summary the outgoing metadata is added to the context, returns the response and the error
+type UnaryClientInterceptor func(ctx context.Context, method string, request proto.Message, invoker UnaryInvoker) (proto.Message, error)
+
+//ChainUnaryServer doc
+//@Summary Returns a server interceptor of the chain, the first is the outermost
+//@Param  ...UnaryServerInterceptor
+//@Return UnaryServerInterceptor nil empty chain
+func ChainUnaryServer(interceptors ...UnaryServerInterceptor) UnaryServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(ctx context.Context, request proto.Message, info *UnaryInfo, handler UnaryHandler) (proto.Message, error) {
+		var next func(i int) UnaryHandler
+		next = func(i int) UnaryHandler {
+			if i == len(interceptors) {
+				return handler
+			}
+			return func(ctx context.Context, request proto.Message) (proto.Message, error) {
+				return interceptors[i](ctx, request, info, next(i+1))
+			}
+		}
+		return next(0)(ctx, request)
+	}
+}
+
+//ChainUnaryClient doc
+//@Summary Returns a client interceptor of the chain, the first is the outermost
+//@Param  ...UnaryClientInterceptor
+//@Return UnaryClientInterceptor nil empty chain
+func ChainUnaryClient(interceptors ...UnaryClientInterceptor) UnaryClientInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(ctx context.Context, method string, request proto.Message, invoker UnaryInvoker) (proto.Message, error) {
+		var next func(i int) UnaryInvoker
+		next = func(i int) UnaryInvoker {
+			if i == len(interceptors) {
+				return invoker
+			}
+			return func(ctx context.Context, method string, request proto.Message) (proto.Message, error) {
+				return interceptors[i](ctx, method, request, next(i+1))
+			}
+		}
+		return next(0)(ctx, method, request)
+	}
+}
+
+type unaryInterceptor interface {
+	UnaryInterceptor() UnaryServerInterceptor
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"context"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//Metadata doc
+//@Summary RPC metadata key-value headers, the request headers travel
+//@Summary with the request, the trailers travel with the response
+type Metadata map[string]string
+
+type outgoingMetadataKey struct{}
+type callTrailerKey struct{}
+type serverTrailerKey struct{}
+
+//NewMetadata doc
+//@Summary New metadata of the key, value pairs
+//@Param  ...string key, value pairs
+//@Return Metadata
+func NewMetadata(kv ...string) Metadata {
+	md := make(Metadata, len(kv)>>1)
+	for i := 0; i+1 < len(kv); i += 2 {
+		md[kv[i]] = kv[i+1]
+	}
+	return md
+}
+
+//Get doc
+//@Summary Returns the value of the key, empty none
+//@Param  string key
+//@Return string
+func (slf Metadata) Get(key string) string {
+	return slf[key]
+}
+
+//Set doc
+//@Summary Set the value of the key
+//@Param string key
+//@Param string value
+func (slf Metadata) Set(key, value string) {
+	slf[key] = value
+}
+
+//Copy doc
+//@Summary Returns a copy of the metadata
+//@Return Metadata
+func (slf Metadata) Copy() Metadata {
+	md := make(Metadata, len(slf))
+	for k, v := range slf {
+		md[k] = v
+	}
+	return md
+}
+
+//WithMetadata doc
+//@Summary Returns a context carries the outgoing metadata of the calls,
+//@Summary merged with the outgoing metadata of the parent
+//@Param  context.Context parent
+//@Param  Metadata        outgoing metadata
+//@Return context.Context
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	if parent := OutgoingMetadata(ctx); len(parent) > 0 {
+		merged := parent.Copy()
+		for k, v := range md {
+			merged[k] = v
+		}
+		md = merged
+	} else {
+		md = md.Copy()
+	}
+	return context.WithValue(ctx, outgoingMetadataKey{}, md)
+}
+
+//OutgoingMetadata doc
+//@Summary Returns the outgoing metadata of the context, nil none
+//@Param  context.Context
+//@Return Metadata
+func OutgoingMetadata(ctx context.Context) Metadata {
+	md, _ := ctx.Value(outgoingMetadataKey{}).(Metadata)
+	return md
+}
+
+//IncomingMetadata doc
+//@Summary Returns the request metadata of the handler context, nil none
+//@Param  context.Context handler context
+//@Return Metadata
+func IncomingMetadata(ctx context.Context) Metadata {
+	if info, ok := RequestFromContext(ctx); ok {
+		return info.Metadata
+	}
+	return nil
+}
+
+//SetTrailer doc
+//@Summary Set the response trailers of the handler context, sent with the response
+//@Param  context.Context handler context
+//@Param  Metadata        trailers
+//@Return error code.ErrContextUndefined not a handler context
+func SetTrailer(ctx context.Context, md Metadata) error {
+	trailer, ok := ctx.Value(serverTrailerKey{}).(Metadata)
+	if !ok {
+		return code.ErrContextUndefined
+	}
+
+	for k, v := range md {
+		trailer[k] = v
+	}
+	return nil
+}
+
+//WithTrailer doc
+//@Summary Returns a context of the call, the returned metadata is filled
+//@Summary with the response trailers when the call returns
+//@Param  context.Context parent
+//@Return context.Context
+//@Return Metadata
+func WithTrailer(ctx context.Context) (context.Context, Metadata) {
+	md := make(Metadata)
+	return context.WithValue(ctx, callTrailerKey{}, md), md
+}
+
+func callTrailer(ctx context.Context, trailer map[string]string) {
+	if len(trailer) == 0 {
+		return
+	}
+
+	if md, ok := ctx.Value(callTrailerKey{}).(Metadata); ok {
+		for k, v := range trailer {
+			md[k] = v
+		}
+	}
+}
+
+//CallTrailer doc
+//@Summary Fill the trailers of the response into the call context trailer
+//@Param  context.Context call context
+//@Param  *ResponseEvent  response
+func CallTrailer(ctx context.Context, response *ResponseEvent) {
+	callTrailer(ctx, response.Trailer)
+}
+
+func serverTrailer(ctx context.Context) Metadata {
+	if ctx == nil {
+		return nil
+	}
+
+	md, _ := ctx.Value(serverTrailerKey{}).(Metadata)
+	return md
+}
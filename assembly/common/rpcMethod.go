@@ -0,0 +1,210 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+var (
+	typeOfMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+//RPCMethod doc
+//@Summary RPC registered method, the signature is validated and cached once
+//@Member string        method name
+//@Member reflect.Value method bound to the registered object
+//@Member reflect.Type  request param type, nil no param
+//@Member bool          takes a context.Context first
+//@Member bool          stream method, last param *Stream
+//@Member int           index of the message return, -1 no message return
+//@Member int           index of the error return, -1 no error return
+type RPCMethod struct {
+	_name    string
+	_fn      reflect.Value
+	_param   reflect.Type
+	_context bool
+	_stream  bool
+	_reply   int
+	_error   int
+}
+
+//Name doc
+//@Summary Returns the method name
+//@Return string
+func (slf *RPCMethod) Name() string {
+	return slf._name
+}
+
+//IsStream doc
+//@Summary Returns the method is a stream method
+//@Return bool
+func (slf *RPCMethod) IsStream() bool {
+	return slf._stream
+}
+
+//IsReturn doc
+//@Summary Returns the method replies a message
+//@Return bool
+func (slf *RPCMethod) IsReturn() bool {
+	return slf._reply >= 0
+}
+
+//RPCService doc
+//@Summary RPC registered object, the dispatch table of its exported methods
+//@Member string                name
+//@Member map[string]*RPCMethod method table
+type RPCService struct {
+	_name    string
+	_methods map[string]*RPCMethod
+}
+
+//NewRPCService doc
+//@Summary Validate the exported methods signature of the object, and make the dispatch table,
+//@Summary method signature:
+//@Summary  func([ctx context.Context,] c net.INetClient[, param proto.Message]) [proto.Message][error]
+//@Summary  func([c net.INetClient,] s *Stream) [error]
+//@Param  string      name
+//@Param  interface{} object
+//@Return *RPCService
+//@Return error
+func NewRPCService(name string, obj interface{}) (*RPCService, error) {
+	if name == "" || strings.Contains(name, ".") {
+		return nil, fmt.Errorf("rpc name %s invalid", name)
+	}
+
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("rpc %s need object", name)
+	}
+
+	t := v.Type()
+	svc := &RPCService{_name: name, _methods: make(map[string]*RPCMethod)}
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.PkgPath != "" {
+			continue
+		}
+
+		method, err := newRPCMethod(m.Name, v.Method(i))
+		if err != nil {
+			return nil, fmt.Errorf("rpc method %s.%s %s", name, m.Name, err.Error())
+		}
+		svc._methods[m.Name] = method
+	}
+
+	if len(svc._methods) == 0 {
+		return nil, fmt.Errorf("rpc %s has no exported method", name)
+	}
+	return svc, nil
+}
+
+//Name doc
+//@Summary Returns the service name
+//@Return string
+func (slf *RPCService) Name() string {
+	return slf._name
+}
+
+//Method doc
+//@Summary Returns the method of the name, nil undefined
+//@Param  string method name
+//@Return *RPCMethod
+func (slf *RPCService) Method(name string) *RPCMethod {
+	return slf._methods[name]
+}
+
+func newRPCMethod(name string, fn reflect.Value) (*RPCMethod, error) {
+	ft := fn.Type()
+	if ft.IsVariadic() {
+		return nil, fmt.Errorf("can not be variadic")
+	}
+
+	m := &RPCMethod{_name: name, _fn: fn, _reply: -1, _error: -1}
+	in := 0
+	if ft.NumIn() > 0 && ft.In(ft.NumIn()-1) == typeOfStream {
+		m._stream = true
+		if ft.NumIn() > 2 {
+			return nil, fmt.Errorf("stream method takes (c, *Stream)")
+		}
+
+		if ft.NumIn() == 2 && !isClientType(ft.In(0)) {
+			return nil, fmt.Errorf("stream method first param must be the client")
+		}
+
+		if ft.NumOut() > 1 || (ft.NumOut() == 1 && ft.Out(0) != typeOfError) {
+			return nil, fmt.Errorf("stream method can only return error")
+		}
+
+		if ft.NumOut() == 1 {
+			m._error = 0
+		}
+		return m, nil
+	}
+
+	if ft.NumIn() > in && ft.In(in) == typeOfContext {
+		m._context = true
+		in++
+	}
+
+	if ft.NumIn() <= in || !isClientType(ft.In(in)) {
+		return nil, fmt.Errorf("param %d must be the client", in+1)
+	}
+	in++
+
+	if ft.NumIn() > in {
+		if !ft.In(in).Implements(typeOfMessage) || ft.In(in).Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("param %d must be a proto.Message", in+1)
+		}
+		m._param = ft.In(in)
+		in++
+	}
+
+	if ft.NumIn() > in {
+		return nil, fmt.Errorf("too many params")
+	}
+
+	switch ft.NumOut() {
+	case 0:
+	case 1:
+		if ft.Out(0) == typeOfError {
+			m._error = 0
+		} else if ft.Out(0).Implements(typeOfMessage) {
+			m._reply = 0
+		} else {
+			return nil, fmt.Errorf("must return proto.Message or error")
+		}
+	case 2:
+		if !ft.Out(0).Implements(typeOfMessage) || ft.Out(1) != typeOfError {
+			return nil, fmt.Errorf("must return (proto.Message, error)")
+		}
+		m._reply = 0
+		m._error = 1
+	default:
+		return nil, fmt.Errorf("too many returns")
+	}
+
+	return m, nil
+}
+
+func isClientType(t reflect.Type) bool {
+	if t == typeOfContext || t == typeOfStream || t.Implements(typeOfMessage) {
+		return false
+	}
+	return t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr
+}
+
+func lookupMethod(rpcGet GetRPCMethod, name string) *RPCMethod {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		return nil
+	}
+
+	svc := rpcGet(name[:i])
+	if svc == nil {
+		return nil
+	}
+	return svc.Method(name[i+1:])
+}
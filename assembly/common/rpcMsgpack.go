@@ -0,0 +1,258 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//msgpackAppend doc
+//@Summary Append the msgpack of a json value, nil, bool, json.Number, string,
+//@Summary []interface{} or map[string]interface{}
+func msgpackAppend(dst []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(dst, 0xc0), nil
+	case bool:
+		if val {
+			return append(dst, 0xc3), nil
+		}
+		return append(dst, 0xc2), nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return msgpackAppendInt(dst, i), nil
+		}
+
+		f, err := val.Float64()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, 0xcb)
+		return msgpackAppendUint(dst, math.Float64bits(f), 8), nil
+	case string:
+		n := len(val)
+		switch {
+		case n < 32:
+			dst = append(dst, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			dst = append(dst, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			dst = msgpackAppendUint(append(dst, 0xda), uint64(n), 2)
+		default:
+			dst = msgpackAppendUint(append(dst, 0xdb), uint64(n), 4)
+		}
+		return append(dst, val...), nil
+	case []interface{}:
+		dst = msgpackAppendHead(dst, len(val), 0x90, 0xdc)
+		var err error
+		for _, e := range val {
+			if dst, err = msgpackAppend(dst, e); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		dst = msgpackAppendHead(dst, len(val), 0x80, 0xde)
+		var err error
+		for _, k := range keys {
+			if dst, err = msgpackAppend(dst, k); err != nil {
+				return nil, err
+			}
+
+			if dst, err = msgpackAppend(dst, val[k]); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	}
+	return nil, fmt.Errorf("msgpack unsupported type %T", v)
+}
+
+func msgpackAppendHead(dst []byte, n int, fix byte, tag16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(dst, fix|byte(n))
+	case n <= math.MaxUint16:
+		return msgpackAppendUint(append(dst, tag16), uint64(n), 2)
+	}
+	return msgpackAppendUint(append(dst, tag16+1), uint64(n), 4)
+}
+
+func msgpackAppendInt(dst []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(dst, byte(i))
+	case i < 0 && i >= -32:
+		return append(dst, byte(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return msgpackAppendUint(append(dst, 0xd2), uint64(uint32(i)), 4)
+	}
+	return msgpackAppendUint(append(dst, 0xd3), uint64(i), 8)
+}
+
+func msgpackAppendUint(dst []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		dst = append(dst, byte(v>>(uint(i)*8)))
+	}
+	return dst
+}
+
+//msgpackRead doc
+//@Summary Read a msgpack value as a json value, the binaries are base64 strings
+//@Return interface{} value
+//@Return int         next position
+//@Return error
+func msgpackRead(data []byte, pos int) (interface{}, int, error) {
+	if pos >= len(data) {
+		return nil, 0, code.ErrCodecCorrupt
+	}
+
+	tag := data[pos]
+	pos++
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), pos, nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), pos, nil
+	case tag&0xf0 == 0x80:
+		return msgpackReadMap(data, pos, int(tag&0x0f))
+	case tag&0xf0 == 0x90:
+		return msgpackReadArray(data, pos, int(tag&0x0f))
+	case tag&0xe0 == 0xa0:
+		return msgpackReadString(data, pos, int(tag&0x1f))
+	}
+
+	switch tag {
+	case 0xc0:
+		return nil, pos, nil
+	case 0xc2:
+		return false, pos, nil
+	case 0xc3:
+		return true, pos, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, pos, err := msgpackReadLen(data, pos, 1<<(tag-0xc4))
+		if err != nil || pos+n > len(data) {
+			return nil, 0, code.ErrCodecCorrupt
+		}
+		return base64.StdEncoding.EncodeToString(data[pos : pos+n]), pos + n, nil
+	case 0xca:
+		v, pos, err := msgpackReadUint(data, pos, 4)
+		return float64(math.Float32frombits(uint32(v))), pos, err
+	case 0xcb:
+		v, pos, err := msgpackReadUint(data, pos, 8)
+		return math.Float64frombits(v), pos, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return msgpackReadUint(data, pos, 1<<(tag-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (tag - 0xd0)
+		v, pos, err := msgpackReadUint(data, pos, n)
+		shift := uint(64 - n*8)
+		return int64(v<<shift) >> shift, pos, err
+	case 0xd9, 0xda, 0xdb:
+		n, pos, err := msgpackReadLen(data, pos, 1<<(tag-0xd9))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgpackReadString(data, pos, n)
+	case 0xdc, 0xdd:
+		n, pos, err := msgpackReadLen(data, pos, 2<<(tag-0xdc))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgpackReadArray(data, pos, n)
+	case 0xde, 0xdf:
+		n, pos, err := msgpackReadLen(data, pos, 2<<(tag-0xde))
+		if err != nil {
+			return nil, 0, err
+		}
+		return msgpackReadMap(data, pos, n)
+	}
+	return nil, 0, fmt.Errorf("msgpack unsupported tag 0x%x", tag)
+}
+
+func msgpackReadUint(data []byte, pos int, n int) (uint64, int, error) {
+	if pos+n > len(data) {
+		return 0, 0, code.ErrCodecCorrupt
+	}
+
+	var v uint64
+	switch n {
+	case 1:
+		v = uint64(data[pos])
+	case 2:
+		v = uint64(binary.BigEndian.Uint16(data[pos:]))
+	case 4:
+		v = uint64(binary.BigEndian.Uint32(data[pos:]))
+	default:
+		v = binary.BigEndian.Uint64(data[pos:])
+	}
+	return v, pos + n, nil
+}
+
+func msgpackReadLen(data []byte, pos int, n int) (int, int, error) {
+	v, pos, err := msgpackReadUint(data, pos, n)
+	if err != nil || v > uint64(len(data)) {
+		return 0, 0, code.ErrCodecCorrupt
+	}
+	return int(v), pos, nil
+}
+
+func msgpackReadString(data []byte, pos int, n int) (interface{}, int, error) {
+	if pos+n > len(data) {
+		return nil, 0, code.ErrCodecCorrupt
+	}
+	return string(data[pos : pos+n]), pos + n, nil
+}
+
+func msgpackReadArray(data []byte, pos int, n int) (interface{}, int, error) {
+	if n > len(data)-pos {
+		return nil, 0, code.ErrCodecCorrupt
+	}
+
+	result := make([]interface{}, n)
+	var err error
+	for i := 0; i < n; i++ {
+		if result[i], pos, err = msgpackRead(data, pos); err != nil {
+			return nil, 0, err
+		}
+	}
+	return result, pos, nil
+}
+
+func msgpackReadMap(data []byte, pos int, n int) (interface{}, int, error) {
+	if n > len(data)-pos {
+		return nil, 0, code.ErrCodecCorrupt
+	}
+
+	result := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, next, err := msgpackRead(data, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		v, next, err := msgpackRead(data, next)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if s, ok := k.(string); ok {
+			result[s] = v
+		} else {
+			result[fmt.Sprint(k)] = v
+		}
+		pos = next
+	}
+	return result, pos, nil
+}
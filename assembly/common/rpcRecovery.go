@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+var panicCount uint64
+
+//Panics doc
+//@Summary Returns the number of method panics recovered
+//@Return uint64
+func Panics() uint64 {
+	return atomic.LoadUint64(&panicCount)
+}
+
+//recoverySwitch doc
+//@Summary Connection switch of the method panic recovery, recovered when not implemented
+type recoverySwitch interface {
+	Recovery() bool
+}
+
+//panicHandler doc
+//@Summary Connection handler of the recovered method panics, logs the method and the stack
+type panicHandler interface {
+	OnPanic(methodName string, r interface{}, stack []byte)
+}
+
+func recoveryEnabled(c interface{}) bool {
+	if rs, ok := c.(recoverySwitch); ok {
+		return rs.Recovery()
+	}
+	return true
+}
+
+//recoverPanic doc
+//@Summary Count and report a recovered method panic, must be called by the deferred function
+//@Return error internal error of the caller response
+func recoverPanic(c interface{}, methodName string, r interface{}) error {
+	atomic.AddUint64(&panicCount, 1)
+	if ph, ok := c.(panicHandler); ok {
+		ph.OnPanic(methodName, r, debug.Stack())
+	}
+	return code.NewError(code.CodeInternal, fmt.Sprintf("RPC method panic:%+v", r), nil)
+}
@@ -0,0 +1,256 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstCodecProto protobuf codec name, the default
+	ConstCodecProto = "proto"
+	//ConstCodecJSON json codec name
+	ConstCodecJSON = "json"
+	//ConstCodecMsgpack msgpack codec name
+	ConstCodecMsgpack = "msgpack"
+)
+
+//Codec doc
+//@Summary Serialization codec of the params and the returns, the message types
+//@Summary are still looked up by the data name
+//@Method Name      codec name, travels with the frames of a non-protobuf codec
+//@Method Marshal   returns the data of the message
+//@Method Unmarshal decodes the data into the message
+type Codec interface {
+	Name() string
+	Marshal(msg proto.Message) ([]byte, error)
+	Unmarshal(data []byte, msg proto.Message) error
+}
+
+type codecKey struct{}
+
+var (
+	codecs     []Codec
+	codecsSync sync.RWMutex
+)
+
+func init() {
+	RegisterCodec(&ProtoCodec{})
+	RegisterCodec(&JSONCodec{})
+	RegisterCodec(&MsgpackCodec{})
+}
+
+//RegisterCodec doc
+//@Summary Register a serialization codec, replaces the codec of the same name
+//@Param  Codec
+//@Return error
+func RegisterCodec(c Codec) error {
+	if c.Name() == "" {
+		return errors.New("rpc codec name undefined")
+	}
+
+	codecsSync.Lock()
+	defer codecsSync.Unlock()
+	for i, v := range codecs {
+		if v.Name() == c.Name() {
+			codecs[i] = c
+			return nil
+		}
+	}
+	codecs = append(codecs, c)
+	return nil
+}
+
+//GetCodec doc
+//@Summary Returns the registered codec of the name, empty protobuf, nil none
+//@Param  string codec name
+//@Return Codec
+func GetCodec(name string) Codec {
+	if name == "" {
+		name = ConstCodecProto
+	}
+
+	codecsSync.RLock()
+	defer codecsSync.RUnlock()
+	for _, v := range codecs {
+		if v.Name() == name {
+			return v
+		}
+	}
+	return nil
+}
+
+//Codecs doc
+//@Summary Returns the registered codec names
+//@Return []string
+func Codecs() []string {
+	codecsSync.RLock()
+	defer codecsSync.RUnlock()
+	names := make([]string, len(codecs))
+	for i, v := range codecs {
+		names[i] = v.Name()
+	}
+	return names
+}
+
+//WithCodec doc
+//@Summary Returns a context of the calls serialized by the codec, instead of the connection codec
+//@Param  context.Context parent
+//@Param  string          codec name
+//@Return context.Context
+func WithCodec(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, codecKey{}, name)
+}
+
+//CodecFromContext doc
+//@Summary Returns the codec of the context, nil none
+//@Param  context.Context
+//@Return Codec
+func CodecFromContext(ctx context.Context) Codec {
+	name, ok := ctx.Value(codecKey{}).(string)
+	if !ok {
+		return nil
+	}
+	return GetCodec(name)
+}
+
+//codecName doc
+//@Summary Returns the frame codec name, empty protobuf
+func codecName(c Codec) string {
+	if c == nil || c.Name() == ConstCodecProto {
+		return ""
+	}
+	return c.Name()
+}
+
+func marshal(c Codec, msg proto.Message) ([]byte, error) {
+	if c == nil {
+		return proto.Marshal(msg)
+	}
+	return c.Marshal(msg)
+}
+
+//ProtoCodec doc
+//@Summary gogo protobuf codec
+type ProtoCodec struct {
+}
+
+//Name doc
+//@Summary Returns codec name
+//@Return string
+func (slf *ProtoCodec) Name() string {
+	return ConstCodecProto
+}
+
+//Marshal doc
+//@Summary Returns the protobuf data of the message
+//@Param  proto.Message
+//@Return []byte
+//@Return error
+func (slf *ProtoCodec) Marshal(msg proto.Message) ([]byte, error) {
+	return proto.Marshal(msg)
+}
+
+//Unmarshal doc
+//@Summary Decodes the protobuf data into the message
+//@Param  []byte
+//@Param  proto.Message
+//@Return error
+func (slf *ProtoCodec) Unmarshal(data []byte, msg proto.Message) error {
+	return proto.Unmarshal(data, msg)
+}
+
+//JSONCodec doc
+//@Summary protobuf json mapping codec, the field names are the proto names
+type JSONCodec struct {
+}
+
+//Name doc
+//@Summary Returns codec name
+//@Return string
+func (slf *JSONCodec) Name() string {
+	return ConstCodecJSON
+}
+
+//Marshal doc
+//@Summary Returns the json data of the message
+//@Param  proto.Message
+//@Return []byte
+//@Return error
+func (slf *JSONCodec) Marshal(msg proto.Message) ([]byte, error) {
+	var b bytes.Buffer
+	if err := (&jsonpb.Marshaler{OrigName: true}).Marshal(&b, msg); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//Unmarshal doc
+//@Summary Decodes the json data into the message, unknown fields are ignored
+//@Param  []byte
+//@Param  proto.Message
+//@Return error
+func (slf *JSONCodec) Unmarshal(data []byte, msg proto.Message) error {
+	return (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(data), msg)
+}
+
+//MsgpackCodec doc
+//@Summary msgpack codec of the protobuf json mapping, a message is a map of the proto names
+type MsgpackCodec struct {
+	_json JSONCodec
+}
+
+//Name doc
+//@Summary Returns codec name
+//@Return string
+func (slf *MsgpackCodec) Name() string {
+	return ConstCodecMsgpack
+}
+
+//Marshal doc
+//@Summary Returns the msgpack data of the message
+//@Param  proto.Message
+//@Return []byte
+//@Return error
+func (slf *MsgpackCodec) Marshal(msg proto.Message) ([]byte, error) {
+	data, err := slf._json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return msgpackAppend(nil, v)
+}
+
+//Unmarshal doc
+//@Summary Decodes the msgpack data into the message
+//@Param  []byte
+//@Param  proto.Message
+//@Return error
+func (slf *MsgpackCodec) Unmarshal(data []byte, msg proto.Message) error {
+	v, n, err := msgpackRead(data, 0)
+	if err != nil {
+		return err
+	}
+
+	if n != len(data) {
+		return code.ErrCodecCorrupt
+	}
+
+	data, err = json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return slf._json.Unmarshal(data, msg)
+}
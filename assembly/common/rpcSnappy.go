@@ -0,0 +1,201 @@
+package common
+
+import (
+	"encoding/binary"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//snappy element tags
+	snappyTagLiteral = 0x00
+	snappyTagCopy1   = 0x01
+	snappyTagCopy2   = 0x02
+	snappyTagCopy4   = 0x03
+	//snappy encoder hash table bits
+	snappyTableBits = 14
+	snappyTableSize = 1 << snappyTableBits
+	//snappy encoder min match length
+	snappyMinMatch = 4
+	//snappy encoder max copy offset
+	snappyMaxOffset = 0xFFFF
+)
+
+//SnappyCompressor doc
+//@Summary snappy block format compressor, id 2, fast and light compression
+type SnappyCompressor struct {
+}
+
+//ID doc
+//@Summary Returns compressor id
+//@Return byte
+func (slf *SnappyCompressor) ID() byte {
+	return 2
+}
+
+//Name doc
+//@Summary Returns compressor name
+//@Return string
+func (slf *SnappyCompressor) Name() string {
+	return "snappy"
+}
+
+//Compress doc
+//@Summary Returns the snappy block data
+//@Param  []byte data
+//@Return []byte
+//@Return error
+func (slf *SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	dst := make([]byte, 0, binary.MaxVarintLen64+len(data)+len(data)/6+32)
+	dst = snappyAppendUvarint(dst, uint64(len(data)))
+
+	var table [snappyTableSize]int32
+	lit := 0
+	s := 0
+	for s+snappyMinMatch <= len(data) {
+		cur := binary.LittleEndian.Uint32(data[s:])
+		h := (cur * 0x1e35a7bd) >> (32 - snappyTableBits)
+		cand := int(table[h]) - 1
+		table[h] = int32(s + 1)
+		if cand < 0 || s-cand > snappyMaxOffset || binary.LittleEndian.Uint32(data[cand:]) != cur {
+			s++
+			continue
+		}
+
+		dst = snappyAppendLiteral(dst, data[lit:s])
+		m := snappyMinMatch
+		for s+m < len(data) && data[cand+m] == data[s+m] {
+			m++
+		}
+
+		dst = snappyAppendCopy(dst, s-cand, m)
+		s += m
+		lit = s
+	}
+
+	return snappyAppendLiteral(dst, data[lit:]), nil
+}
+
+//Decompress doc
+//@Summary Returns the data of the snappy block data
+//@Param  []byte snappy block data
+//@Param  int    data length limit
+//@Return []byte
+//@Return error
+func (slf *SnappyCompressor) Decompress(data []byte, limit int) ([]byte, error) {
+	n, k := binary.Uvarint(data)
+	if k <= 0 {
+		return nil, code.ErrCompressCorrupt
+	}
+
+	if n > uint64(limit) {
+		return nil, code.ErrDataOverflow
+	}
+
+	dst := make([]byte, 0, int(n))
+	s := k
+	for s < len(data) {
+		tag := data[s]
+		var length, offset int
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			x := int(tag >> 2)
+			s++
+			if x >= 60 {
+				b := x - 59
+				if s+b > len(data) {
+					return nil, code.ErrCompressCorrupt
+				}
+
+				x = 0
+				for i := b - 1; i >= 0; i-- {
+					x = x<<8 | int(data[s+i])
+				}
+				s += b
+			}
+
+			length = x + 1
+			if length <= 0 || length > len(data)-s || length > int(n)-len(dst) {
+				return nil, code.ErrCompressCorrupt
+			}
+			dst = append(dst, data[s:s+length]...)
+			s += length
+			continue
+		case snappyTagCopy1:
+			if s+2 > len(data) {
+				return nil, code.ErrCompressCorrupt
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xE0)<<3 | int(data[s+1])
+			s += 2
+		case snappyTagCopy2:
+			if s+3 > len(data) {
+				return nil, code.ErrCompressCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(data[s+1:]))
+			s += 3
+		case snappyTagCopy4:
+			if s+5 > len(data) {
+				return nil, code.ErrCompressCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(data[s+1:]))
+			s += 5
+		}
+
+		if offset <= 0 || offset > len(dst) || length > int(n)-len(dst) {
+			return nil, code.ErrCompressCorrupt
+		}
+
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if len(dst) != int(n) {
+		return nil, code.ErrCompressCorrupt
+	}
+	return dst, nil
+}
+
+func snappyAppendUvarint(dst []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(dst, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func snappyAppendLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n<<2)|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+func snappyAppendCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+
+	if length > 64 {
+		dst = append(dst, 59<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+
+	if length <= 11 && offset < 2048 {
+		return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|snappyTagCopy1, byte(offset))
+	}
+	return append(dst, byte(length-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+}
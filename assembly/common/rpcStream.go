@@ -0,0 +1,604 @@
+package common
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	//ConstStreamName rpc stream frame data name
+	ConstStreamName = "magicRpc.Stream"
+	//ConstStreamWindow stream initial window, messages the peer can send without window update
+	ConstStreamWindow = 32
+)
+
+const (
+	//stream open frame
+	streamOpen int32 = 0x1
+	//stream message frame
+	streamData int32 = 0x2
+	//stream end frame, the sender will not send anymore
+	streamEnd int32 = 0x4
+	//stream window update frame
+	streamWindow int32 = 0x8
+)
+
+var typeOfStream = reflect.TypeOf((*Stream)(nil))
+
+//rpcStream doc
+//@Summary RPC stream frame message
+//@Member int32  frame flag open/data/end/window
+//@Member int32  window update, messages the peer can send more
+//@Member int64  remaining time out/millsecond of open frame
+//@Member string message data name
+//@Member []byte message data
+//@Member map[string]string metadata of open frame
+//@Member string message data codec name, empty protobuf
+type rpcStream struct {
+	Flag     int32             `protobuf:"varint,1,opt,name=flag,proto3" json:"flag,omitempty"`
+	Window   int32             `protobuf:"varint,2,opt,name=window,proto3" json:"window,omitempty"`
+	Timeout  int64             `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	DataName string            `protobuf:"bytes,4,opt,name=data_name,json=dataName,proto3" json:"data_name,omitempty"`
+	Data     []byte            `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Codec    string            `protobuf:"bytes,7,opt,name=codec,proto3" json:"codec,omitempty"`
+}
+
+func (m *rpcStream) Reset()         { *m = rpcStream{} }
+func (m *rpcStream) String() string { return proto.CompactTextString(m) }
+func (*rpcStream) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*rpcStream)(nil), ConstStreamName)
+}
+
+func streamDecode(rpcGet GetRPCMethod, block *Block) (interface{}, error) {
+	msg := &rpcStream{}
+	if err := proto.Unmarshal(block.Data, msg); err != nil {
+		return nil, err
+	}
+
+	result := &StreamEvent{MethodName: block.Method,
+		Oper:   block.Oper,
+		Ser:    block.Ser,
+		Ver:    block.Ver,
+		Flag:   msg.Flag,
+		Window: msg.Window}
+
+	if block.Oper == RPCRequest && (msg.Flag&streamOpen) != 0 {
+		method := lookupMethod(rpcGet, block.Method)
+		if method == nil || !method._stream {
+			return &RequestEvent{MethodName: block.Method, Ser: block.Ser, Ver: block.Ver,
+				Err: code.NewError(code.CodeMethodUndefined, code.ErrMethodUndefined.Error(), nil)}, nil
+		}
+
+		result.Method = method
+		result.Metadata = msg.Metadata
+		if msg.Timeout > 0 {
+			result.Deadline = time.Now().Add(time.Duration(msg.Timeout) * time.Millisecond)
+		}
+	}
+
+	result.Codec = GetCodec(msg.Codec)
+	if result.Codec == nil {
+		result.Err = code.ErrCodecUndefined
+		return result, nil
+	}
+
+	if msg.DataName != "" {
+		dt := proto.MessageType(msg.DataName)
+		if dt == nil {
+			result.Err = code.ErrParamUndefined
+			return result, nil
+		}
+
+		result.Data = reflect.New(dt.Elem()).Interface().(proto.Message)
+		if err := result.Codec.Unmarshal(msg.Data, result.Data); err != nil {
+			result.Data = nil
+			result.Err = err
+		}
+	}
+
+	return result, nil
+}
+
+func streamEncode(ver int, method string, ser uint32, oper RPCOper, msg *rpcStream) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(ver, method, ser, oper, ConstStreamName, data)
+}
+
+//Stream doc
+//@Summary RPC stream, a sequence of messages in both directions tied to one serial
+//@Member context.Context stream context
+//@Member string          method name
+//@Member uint32          stream serial
+//@Member RPCOper         oper of sending frames
+//@Member int             protocol version
+//@Member Codec           message codec
+type Stream struct {
+	_ctx      context.Context
+	_cancel   context.CancelFunc
+	_method   string
+	_ser      uint32
+	_oper     RPCOper
+	_ver      int
+	_codec    Codec
+	_sendto   func([]byte) error
+	_recv     chan proto.Message
+	_recvEnd  chan struct{}
+	_recvErr  error
+	_failed   chan struct{}
+	_credit   int32
+	_creditC  chan struct{}
+	_consumed int32
+	_sendEnd  int32
+	_replied  int32
+	_once     sync.Once
+	_failOnce sync.Once
+}
+
+func newStream(ctx context.Context,
+	cancel context.CancelFunc,
+	method string,
+	ser uint32,
+	oper RPCOper,
+	ver int,
+	codec Codec,
+	sendto func([]byte) error) *Stream {
+	return &Stream{_ctx: ctx,
+		_cancel:  cancel,
+		_method:  method,
+		_ser:     ser,
+		_oper:    oper,
+		_ver:     ver,
+		_codec:   codec,
+		_sendto:  sendto,
+		_recv:    make(chan proto.Message, ConstStreamWindow),
+		_recvEnd: make(chan struct{}),
+		_failed:  make(chan struct{}),
+		_credit:  ConstStreamWindow,
+		_creditC: make(chan struct{}, 1)}
+}
+
+//Context doc
+//@Summary Returns stream context, done when the stream is cancelled
+//@Return context.Context
+func (slf *Stream) Context() context.Context {
+	return slf._ctx
+}
+
+//MethodName doc
+//@Summary Returns stream method name
+//@Return string
+func (slf *Stream) MethodName() string {
+	return slf._method
+}
+
+//Done doc
+//@Summary Returns a channel closed when the stream finished receiving
+//@Return <-chan struct{}
+func (slf *Stream) Done() <-chan struct{} {
+	return slf._recvEnd
+}
+
+//Send doc
+//@Summary Send a message, blocks while the peer window is full
+//@Param  proto.Message
+//@Return error
+func (slf *Stream) Send(msg proto.Message) error {
+	if atomic.LoadInt32(&slf._sendEnd) != 0 {
+		return code.ErrStreamClosed
+	}
+
+	for atomic.AddInt32(&slf._credit, -1) < 0 {
+		atomic.AddInt32(&slf._credit, 1)
+		select {
+		case <-slf._creditC:
+		case <-slf._failed:
+			return slf._recvErr
+		case <-slf._ctx.Done():
+			return slf._ctx.Err()
+		}
+	}
+
+	data, err := marshal(slf._codec, msg)
+	if err != nil {
+		return err
+	}
+
+	return slf.sendFrame(&rpcStream{Flag: streamData,
+		DataName: proto.MessageName(msg),
+		Data:     data,
+		Codec:    codecName(slf._codec)})
+}
+
+//CloseSend doc
+//@Summary Close the sending direction, the peer receives io.EOF
+//@Return error
+func (slf *Stream) CloseSend() error {
+	if !atomic.CompareAndSwapInt32(&slf._sendEnd, 0, 1) {
+		return nil
+	}
+	return slf.sendFrame(&rpcStream{Flag: streamEnd})
+}
+
+//Recv doc
+//@Summary Receive a message, returns io.EOF when the peer closed sending
+//@Return proto.Message
+//@Return error
+func (slf *Stream) Recv() (proto.Message, error) {
+	select {
+	case msg := <-slf._recv:
+		slf.consume()
+		return msg, nil
+	case <-slf._recvEnd:
+	case <-slf._ctx.Done():
+		select {
+		case <-slf._recvEnd:
+		default:
+			return nil, slf._ctx.Err()
+		}
+	}
+
+	select {
+	case msg := <-slf._recv:
+		return msg, nil
+	default:
+		return nil, slf._recvErr
+	}
+}
+
+func (slf *Stream) consume() {
+	n := atomic.AddInt32(&slf._consumed, 1)
+	if n < (ConstStreamWindow >> 1) {
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&slf._consumed, n, 0) {
+		slf.sendFrame(&rpcStream{Flag: streamWindow, Window: n})
+	}
+}
+
+func (slf *Stream) sendFrame(msg *rpcStream) error {
+	data, err := streamEncode(slf._ver, slf._method, slf._ser, slf._oper, msg)
+	if err != nil {
+		return err
+	}
+	return slf._sendto(data)
+}
+
+func (slf *Stream) onFrame(event *StreamEvent) {
+	if (event.Flag & streamWindow) != 0 {
+		atomic.AddInt32(&slf._credit, event.Window)
+		select {
+		case slf._creditC <- struct{}{}:
+		default:
+		}
+	}
+
+	if (event.Flag&streamData) != 0 && event.Data != nil {
+		select {
+		case slf._recv <- event.Data:
+		default:
+			slf.fail(code.ErrStreamWindow)
+			return
+		}
+	}
+
+	if (event.Flag & streamEnd) != 0 {
+		slf.closeRecv(io.EOF)
+	}
+}
+
+func (slf *Stream) closeRecv(err error) {
+	slf._once.Do(func() {
+		slf._recvErr = err
+		close(slf._recvEnd)
+	})
+}
+
+func (slf *Stream) fail(err error) {
+	slf.closeRecv(err)
+	slf._failOnce.Do(func() {
+		close(slf._failed)
+	})
+}
+
+//RPCStreams doc
+//@Summary RPC streams of a connection
+//@Member map[uint32]*Stream streams opened by this side
+//@Member map[uint32]*Stream streams served by this side
+type RPCStreams struct {
+	_opened map[uint32]*Stream
+	_served map[uint32]*Stream
+	_sync   sync.Mutex
+}
+
+//InitialStreams doc
+//@Summary Initial connection streams
+func (slf *RPCStreams) InitialStreams() {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	slf._opened = make(map[uint32]*Stream)
+	slf._served = make(map[uint32]*Stream)
+}
+
+//ShutdownStreams doc
+//@Summary Close all streams of the connection
+func (slf *RPCStreams) ShutdownStreams() {
+	slf._sync.Lock()
+	opened, served := slf._opened, slf._served
+	slf._opened, slf._served = nil, nil
+	slf._sync.Unlock()
+
+	for _, s := range opened {
+		s.fail(code.ErrConnectClosed)
+		s._cancel()
+	}
+
+	for _, s := range served {
+		s.fail(code.ErrConnectClosed)
+	}
+}
+
+//OpenStream doc
+//@Summary Open a stream to the remote method
+//@Param  context.Context stream context, the deadline travels with the open frame
+//@Param  int             protocol version
+//@Param  string          remote method
+//@Param  uint32          stream serial
+//@Param  Metadata        stream metadata, nil none
+//@Param  Codec           message codec, nil protobuf
+//@Param  func([]byte) error send function
+//@Return *Stream
+//@Return error
+func (slf *RPCStreams) OpenStream(ctx context.Context,
+	ver int,
+	method string,
+	ser uint32,
+	md Metadata,
+	codec Codec,
+	sendto func([]byte) error) (*Stream, error) {
+	var timeout int64
+	if dl, ok := ctx.Deadline(); ok {
+		timeout = int64(time.Until(dl) / time.Millisecond)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	s := newStream(sctx, cancel, method, ser, RPCRequest, ver, codec, sendto)
+	slf._sync.Lock()
+	if slf._opened == nil {
+		slf._sync.Unlock()
+		cancel()
+		return nil, code.ErrConnectClosed
+	}
+	slf._opened[ser] = s
+	slf._sync.Unlock()
+
+	if err := s.sendFrame(&rpcStream{Flag: streamOpen,
+		Window:   ConstStreamWindow,
+		Timeout:  timeout,
+		Metadata: md,
+		Codec:    codecName(codec)}); err != nil {
+		slf.closeOpened(s, err)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-s._recvEnd:
+		case <-sctx.Done():
+			select {
+			case <-s._recvEnd:
+				return
+			default:
+			}
+
+			if data, err := CallCancel(ver, method, ser); err == nil {
+				sendto(data)
+			}
+			slf.closeOpened(s, sctx.Err())
+		}
+	}()
+
+	return s, nil
+}
+
+//StreamError doc
+//@Summary Close the opened stream with the remote error
+//@Param  uint32 stream serial
+//@Param  error  remote error
+//@Return bool   the serial is an opened stream
+func (slf *RPCStreams) StreamError(ser uint32, err error) bool {
+	slf._sync.Lock()
+	s, ok := slf._opened[ser]
+	slf._sync.Unlock()
+	if !ok {
+		return false
+	}
+
+	slf.closeOpened(s, err)
+	return true
+}
+
+//StreamProcess doc
+//@Summary RPC stream frame proccess, open frames run the stream method on a new goroutine
+//@Param  interface{}         the connection passed to the stream method
+//@Param  func([]byte) error  send function
+//@Param  *StreamEvent        stream frame
+func (slf *RPCStreams) StreamProcess(c interface{},
+	sendto func([]byte) error,
+	event *StreamEvent) {
+	if event.Oper == RPCRequest && (event.Flag&streamOpen) != 0 {
+		if event.Err != nil {
+			if data, err := EncodeError(event.Ver, event.MethodName, event.Ser, event.Err); err == nil {
+				sendto(data)
+			}
+			return
+		}
+		slf.serveStream(c, sendto, event)
+		return
+	}
+
+	slf._sync.Lock()
+	var s *Stream
+	if event.Oper == RPCRequest {
+		s = slf._served[event.Ser]
+	} else {
+		s = slf._opened[event.Ser]
+	}
+	slf._sync.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	if event.Err != nil {
+		slf.abortStream(s, event.Err)
+		return
+	}
+
+	s.onFrame(event)
+	if event.Oper == RPCResponse && (event.Flag&streamEnd) != 0 {
+		slf.closeOpened(s, io.EOF)
+	}
+}
+
+//abortStream doc
+//@Summary Fail the stream of a frame that can not be decoded, the peer is told
+//@Summary by an error reply of the served stream or a cancel of the opened stream,
+//@Summary the other streams of the connection go on
+//@Param  *Stream stream
+//@Param  error   decode error
+func (slf *RPCStreams) abortStream(s *Stream, err error) {
+	if s._oper == RPCRequest {
+		if data, e := CallCancel(s._ver, s._method, s._ser); e == nil {
+			s._sendto(data)
+		}
+		slf.closeOpened(s, err)
+		return
+	}
+
+	atomic.StoreInt32(&s._sendEnd, 1)
+	if atomic.CompareAndSwapInt32(&s._replied, 0, 1) {
+		if data, e := EncodeError(s._ver, s._method, s._ser, err); e == nil {
+			s._sendto(data)
+		}
+	}
+	s.fail(err)
+	s._cancel()
+}
+
+func (slf *RPCStreams) closeOpened(s *Stream, err error) {
+	slf._sync.Lock()
+	if slf._opened != nil && slf._opened[s._ser] == s {
+		delete(slf._opened, s._ser)
+	}
+	slf._sync.Unlock()
+
+	atomic.StoreInt32(&s._sendEnd, 1)
+	if err == io.EOF {
+		s.closeRecv(err)
+	} else {
+		s.fail(err)
+	}
+	s._cancel()
+}
+
+func (slf *RPCStreams) serveStream(c interface{},
+	sendto func([]byte) error,
+	event *StreamEvent) {
+	request := &RequestEvent{MethodName: event.MethodName,
+		Method:   event.Method,
+		Ser:      event.Ser,
+		Ver:      event.Ver,
+		Deadline: event.Deadline}
+	if hasFeature(c, FeatureMetadata) {
+		request.Metadata = event.Metadata
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if rc, ok := c.(requestContexter); ok {
+		ctx, cancel = rc.RequestContext(request)
+	} else {
+		ctx, cancel = requestContext(context.Background(), request)
+	}
+
+	s := newStream(ctx, cancel, event.MethodName, event.Ser, RPCResponse, event.Ver, event.Codec, sendto)
+	if event.Window > 0 {
+		s._credit = event.Window
+	}
+
+	slf._sync.Lock()
+	if slf._served == nil {
+		slf._sync.Unlock()
+		cancel()
+		return
+	}
+	slf._served[event.Ser] = s
+	slf._sync.Unlock()
+
+	go func() {
+		defer func() {
+			slf._sync.Lock()
+			if slf._served != nil {
+				delete(slf._served, s._ser)
+			}
+			slf._sync.Unlock()
+			cancel()
+		}()
+
+		err := streamInvoke(c, request, s)
+		if err != nil {
+			if !atomic.CompareAndSwapInt32(&s._replied, 0, 1) {
+				return
+			}
+
+			if data, e := EncodeError(request.Ver, request.MethodName, request.Ser, err); e == nil {
+				sendto(data)
+			}
+			return
+		}
+
+		if atomic.CompareAndSwapInt32(&s._sendEnd, 0, 1) {
+			s.sendFrame(&rpcStream{Flag: streamEnd})
+		}
+	}()
+}
+
+func streamInvoke(c interface{}, request *RequestEvent, s *Stream) (err error) {
+	if recoveryEnabled(c) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(c, request.MethodName, r)
+			}
+		}()
+	}
+
+	method := request.Method
+	params := make([]reflect.Value, 0, 2)
+	if method._fn.Type().NumIn() > 1 {
+		params = append(params, reflect.ValueOf(c))
+	}
+	params = append(params, reflect.ValueOf(s))
+
+	rs := method._fn.Call(params)
+	if method._error >= 0 && !rs[method._error].IsNil() {
+		return rs[method._error].Interface().(error)
+	}
+	return nil
+}
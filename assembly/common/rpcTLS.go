@@ -0,0 +1,375 @@
+package common
+
+import (
+	"crypto/tls"
+	"io"
+	gonet "net"
+	"sync"
+	"time"
+
+	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+const (
+	constTLSReadSize = 16 * 1024
+	//constTLSRecordSize cipher bytes of a full tls record, the plain bytes and the
+	//record overhead
+	constTLSRecordSize = constTLSReadSize + 2048
+)
+
+//TLSConn doc
+//@Summary TLS session over the event driven connection, the received cipher
+//@Summary bytes are fed in, the plain bytes are decoded by the decoder on the
+//@Summary session goroutine, the plain bytes sent are encrypted in order
+//@Member *tls.Conn  tls session
+//@Member *tlsPipe   cipher bytes pipe, capped at the frame size limit
+//@Member *tlsBuffer plain bytes receive buffer, capped at the frame size limit
+//@Member func(net.INetReceiveBuffer) error plain bytes decoder
+//@Member func(error) session failed callback
+type TLSConn struct {
+	_conn      *tls.Conn
+	_pipe      *tlsPipe
+	_plain     *tlsBuffer
+	_decoder   func(net.INetReceiveBuffer) error
+	_closed    func(error)
+	_out       [][]byte
+	_outSignal chan struct{}
+	_handshake chan struct{}
+	_done      chan struct{}
+	_err       error
+	_sync      sync.Mutex
+	_start     sync.Once
+	_stop      sync.Once
+}
+
+//NewTLSServer doc
+//@Summary New a server side TLS session
+//@Param  *tls.Config tls config, ClientAuth for mutual TLS
+//@Param  int         frame size limit of the received bytes, 0 ConstMaxFrameSize
+//@Param  func([]byte) error connection raw send
+//@Param  func(net.INetReceiveBuffer) error plain bytes decoder
+//@Param  func(error) session failed callback
+//@Return *TLSConn
+func NewTLSServer(config *tls.Config,
+	limit int,
+	sendto func([]byte) error,
+	decoder func(net.INetReceiveBuffer) error,
+	closed func(error)) *TLSConn {
+	slf := newTLSConn(limit, sendto, decoder, closed)
+	slf._conn = tls.Server(slf._pipe, config)
+	return slf
+}
+
+//NewTLSClient doc
+//@Summary New a client side TLS session
+//@Param  *tls.Config tls config, Certificates for mutual TLS
+//@Param  int         frame size limit of the received bytes, 0 ConstMaxFrameSize
+//@Param  func([]byte) error connection raw send
+//@Param  func(net.INetReceiveBuffer) error plain bytes decoder
+//@Param  func(error) session failed callback
+//@Return *TLSConn
+func NewTLSClient(config *tls.Config,
+	limit int,
+	sendto func([]byte) error,
+	decoder func(net.INetReceiveBuffer) error,
+	closed func(error)) *TLSConn {
+	slf := newTLSConn(limit, sendto, decoder, closed)
+	slf._conn = tls.Client(slf._pipe, config)
+	return slf
+}
+
+//newTLSConn doc
+//@Summary New a TLS session, the cipher bytes waiting the session goroutine and the
+//@Summary plain bytes waiting the decoder are capped at a frame and a tls record
+func newTLSConn(limit int,
+	sendto func([]byte) error,
+	decoder func(net.INetReceiveBuffer) error,
+	closed func(error)) *TLSConn {
+	if limit <= 0 {
+		limit = ConstMaxFrameSize
+	}
+
+	pipe := &tlsPipe{_sendto: sendto, _cap: limit + constTLSRecordSize}
+	pipe._cond = sync.NewCond(&pipe._sync)
+	return &TLSConn{_pipe: pipe,
+		_plain:     &tlsBuffer{_cap: limit + constTLSReadSize},
+		_decoder:   decoder,
+		_closed:    closed,
+		_outSignal: make(chan struct{}, 1),
+		_handshake: make(chan struct{}),
+		_done:      make(chan struct{})}
+}
+
+//Start doc
+//@Summary Start the session handshake, client side after the connection connected
+func (slf *TLSConn) Start() {
+	slf._start.Do(func() {
+		go slf.readLoop()
+		go slf.writeLoop()
+	})
+}
+
+//Feed doc
+//@Summary Feed the received cipher bytes of the connection receive buffer
+//@Param  net.INetReceiveBuffer connection receive buffer
+//@Return error net.ErrAnalysisProceed, the plain bytes decoded on the session goroutine,
+//@Return       code.ErrDataOverflow the cipher bytes waiting exceed the cap, the session fails
+func (slf *TLSConn) Feed(bf net.INetReceiveBuffer) error {
+	if n := bf.GetBufferLen(); n > 0 {
+		if err := slf._pipe.feed(bf.ReadBuffer(n)); err != nil {
+			slf.fail(err, true)
+			return err
+		}
+	}
+	return net.ErrAnalysisProceed
+}
+
+//SendTo doc
+//@Summary Send plain bytes, encrypted in order after the handshake
+//@Param  []byte plain bytes
+//@Return error
+func (slf *TLSConn) SendTo(data []byte) error {
+	slf._sync.Lock()
+	if slf._err != nil {
+		slf._sync.Unlock()
+		return code.ErrConnectClosed
+	}
+	slf._out = append(slf._out, data)
+	slf._sync.Unlock()
+
+	select {
+	case slf._outSignal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//ConnectionState doc
+//@Summary Returns the session state, false the handshake is not complete
+//@Return tls.ConnectionState
+//@Return bool
+func (slf *TLSConn) ConnectionState() (tls.ConnectionState, bool) {
+	select {
+	case <-slf._handshake:
+		return slf._conn.ConnectionState(), true
+	default:
+		return tls.ConnectionState{}, false
+	}
+}
+
+//Err doc
+//@Summary Returns the session failed error, nil running
+//@Return error
+func (slf *TLSConn) Err() error {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._err
+}
+
+//Close doc
+//@Summary Close the session, the failed callback is not called
+func (slf *TLSConn) Close() {
+	slf.fail(code.ErrConnectClosed, false)
+}
+
+func (slf *TLSConn) fail(err error, notify bool) {
+	slf._stop.Do(func() {
+		slf._sync.Lock()
+		slf._err = err
+		slf._out = nil
+		slf._sync.Unlock()
+
+		close(slf._done)
+		slf._pipe.Close()
+		if notify && slf._closed != nil {
+			slf._closed(err)
+		}
+	})
+}
+
+func (slf *TLSConn) readLoop() {
+	if err := slf._conn.Handshake(); err != nil {
+		slf.fail(err, true)
+		return
+	}
+	close(slf._handshake)
+
+	buf := make([]byte, constTLSReadSize)
+	for {
+		n, err := slf._conn.Read(buf)
+		if n > 0 {
+			if _, e := slf._plain.WriteBuffer(buf[:n]); e != nil {
+				slf.fail(e, true)
+				return
+			}
+
+			for {
+				e := slf._decoder(slf._plain)
+				if e == net.ErrAnalysisSuccess {
+					continue
+				}
+
+				if e != nil && e != net.ErrAnalysisProceed {
+					slf.fail(e, true)
+					return
+				}
+				break
+			}
+		}
+
+		if err != nil {
+			slf.fail(err, true)
+			return
+		}
+	}
+}
+
+func (slf *TLSConn) writeLoop() {
+	select {
+	case <-slf._handshake:
+	case <-slf._done:
+		return
+	}
+
+	for {
+		slf._sync.Lock()
+		out := slf._out
+		slf._out = nil
+		slf._sync.Unlock()
+
+		for _, data := range out {
+			if _, err := slf._conn.Write(data); err != nil {
+				slf.fail(err, true)
+				return
+			}
+		}
+
+		select {
+		case <-slf._outSignal:
+		case <-slf._done:
+			return
+		}
+	}
+}
+
+//tlsPipe doc
+//@Summary net.Conn of the tls session, reads the fed cipher bytes,
+//@Summary writes to the connection
+//@Member int cipher bytes waiting limit
+type tlsPipe struct {
+	_in     []byte
+	_cap    int
+	_sendto func([]byte) error
+	_closed bool
+	_cond   *sync.Cond
+	_sync   sync.Mutex
+}
+
+func (slf *tlsPipe) feed(data []byte) error {
+	slf._sync.Lock()
+	if len(slf._in)+len(data) > slf._cap {
+		slf._sync.Unlock()
+		return code.ErrDataOverflow
+	}
+	slf._in = append(slf._in, data...)
+	slf._sync.Unlock()
+	slf._cond.Signal()
+	return nil
+}
+
+func (slf *tlsPipe) Read(b []byte) (int, error) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	for len(slf._in) == 0 && !slf._closed {
+		slf._cond.Wait()
+	}
+
+	if len(slf._in) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, slf._in)
+	slf._in = slf._in[n:]
+	if len(slf._in) == 0 {
+		slf._in = nil
+	}
+	return n, nil
+}
+
+func (slf *tlsPipe) Write(b []byte) (int, error) {
+	data := make([]byte, len(b))
+	copy(data, b)
+	if err := slf._sendto(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (slf *tlsPipe) Close() error {
+	slf._sync.Lock()
+	slf._closed = true
+	slf._sync.Unlock()
+	slf._cond.Broadcast()
+	return nil
+}
+
+func (slf *tlsPipe) LocalAddr() gonet.Addr              { return tlsAddr{} }
+func (slf *tlsPipe) RemoteAddr() gonet.Addr             { return tlsAddr{} }
+func (slf *tlsPipe) SetDeadline(t time.Time) error      { return nil }
+func (slf *tlsPipe) SetReadDeadline(t time.Time) error  { return nil }
+func (slf *tlsPipe) SetWriteDeadline(t time.Time) error { return nil }
+
+type tlsAddr struct{}
+
+func (tlsAddr) Network() string { return "magicRpc" }
+func (tlsAddr) String() string  { return "magicRpc" }
+
+//tlsBuffer doc
+//@Summary plain bytes receive buffer of the tls session
+//@Member int plain bytes waiting limit
+type tlsBuffer struct {
+	_b   []byte
+	_cap int
+}
+
+func (slf *tlsBuffer) ClearBuffer() {
+	slf._b = nil
+}
+
+func (slf *tlsBuffer) GetBufferLen() int {
+	return len(slf._b)
+}
+
+func (slf *tlsBuffer) GetBufferCap() int {
+	return slf._cap
+}
+
+func (slf *tlsBuffer) GetBufferBytes() []byte {
+	return slf._b
+}
+
+func (slf *tlsBuffer) TrunBuffer(n int) {
+	if n >= len(slf._b) {
+		slf._b = nil
+		return
+	}
+	slf._b = slf._b[n:]
+}
+
+func (slf *tlsBuffer) ReadBuffer(n int) []byte {
+	if n > len(slf._b) {
+		n = len(slf._b)
+	}
+	r := slf._b[:n]
+	slf.TrunBuffer(n)
+	return r
+}
+
+func (slf *tlsBuffer) WriteBuffer(b []byte) (int, error) {
+	if len(slf._b)+len(b) > slf._cap {
+		return 0, code.ErrDataOverflow
+	}
+	slf._b = append(slf._b, b...)
+	return len(b), nil
+}
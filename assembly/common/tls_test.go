@@ -0,0 +1,228 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testCertificate returns a certificate of the common name signed by the parent, self signed nil parent
+func testCertificate(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:     pkix.Name{CommonName: name},
+		DNSNames:    []string{name},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}}
+
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+//testTLSPeer tls session of the loopback tests, the decoded plain bytes and the failure
+type testTLSPeer struct {
+	conn   *TLSConn
+	plain  chan []byte
+	closed chan error
+}
+
+func newTestTLSPeer() *testTLSPeer {
+	return &testTLSPeer{plain: make(chan []byte, 16), closed: make(chan error, 1)}
+}
+
+func (slf *testTLSPeer) decode(bf net.INetReceiveBuffer) error {
+	data := make([]byte, bf.GetBufferLen())
+	copy(data, bf.ReadBuffer(len(data)))
+	slf.plain <- data
+	return net.ErrAnalysisProceed
+}
+
+func (slf *testTLSPeer) fail(err error) {
+	slf.closed <- err
+}
+
+//testTLSLoopback starts a server and a client session feeding each other
+func testTLSLoopback(srvConfig, cliConfig *tls.Config) (*testTLSPeer, *testTLSPeer) {
+	srv, cli := newTestTLSPeer(), newTestTLSPeer()
+	srv.conn = NewTLSServer(srvConfig, 0, func(data []byte) error {
+		cli.conn.Feed(rpctest.NewBuffer(data))
+		return nil
+	}, srv.decode, srv.fail)
+	cli.conn = NewTLSClient(cliConfig, 0, func(data []byte) error {
+		srv.conn.Feed(rpctest.NewBuffer(data))
+		return nil
+	}, cli.decode, cli.fail)
+
+	srv.conn.Start()
+	cli.conn.Start()
+	return srv, cli
+}
+
+func testTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	ca := testCertificate(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	srvConfig := &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "server", &ca)},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool}
+	cliConfig := &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "client", &ca)},
+		RootCAs:    pool,
+		ServerName: "server"}
+	return srvConfig, cliConfig
+}
+
+func testPlain(t *testing.T, peer *testTLSPeer, want string) {
+	var got []byte
+	for len(got) < len(want) {
+		select {
+		case data := <-peer.plain:
+			got = append(got, data...)
+		case err := <-peer.closed:
+			t.Fatalf("session failed:%v", err)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("plain bytes %q not received", want)
+		}
+	}
+
+	if string(got) != want {
+		t.Fatalf("plain bytes %q, want %q", got, want)
+	}
+}
+
+func TestTLSLoopback(t *testing.T) {
+	srvConfig, cliConfig := testTLSConfigs(t)
+	srv, cli := testTLSLoopback(srvConfig, cliConfig)
+	defer srv.conn.Close()
+	defer cli.conn.Close()
+
+	if err := cli.conn.SendTo([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	testPlain(t, srv, "ping")
+
+	if err := srv.conn.SendTo([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	testPlain(t, cli, "pong")
+
+	//mutual TLS, the server sees the client certificate
+	state, ok := srv.conn.ConnectionState()
+	if !ok || len(state.PeerCertificates) == 0 || state.PeerCertificates[0].Subject.CommonName != "client" {
+		t.Fatalf("server peer certificates %+v", state.PeerCertificates)
+	}
+
+	if state, ok = cli.conn.ConnectionState(); !ok || state.PeerCertificates[0].Subject.CommonName != "server" {
+		t.Fatalf("client peer certificates %+v", state.PeerCertificates)
+	}
+}
+
+func TestTLSPeerRejected(t *testing.T) {
+	//the client certificate of an unknown authority fails the handshake
+	srvConfig, cliConfig := testTLSConfigs(t)
+	rogue := testCertificate(t, "rogue", nil)
+	cliConfig.Certificates = []tls.Certificate{testCertificate(t, "client", &rogue)}
+
+	srv, cli := testTLSLoopback(srvConfig, cliConfig)
+	defer srv.conn.Close()
+	defer cli.conn.Close()
+
+	select {
+	case err := <-srv.closed:
+		if err == nil {
+			t.Fatal("server session failed without error")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("rogue client certificate accepted")
+	}
+
+	if _, ok := srv.conn.ConnectionState(); ok {
+		t.Fatal("server handshake complete with the rogue client")
+	}
+
+	if err := srv.conn.SendTo([]byte("pong")); err != code.ErrConnectClosed {
+		t.Fatalf("send of the failed session:%v", err)
+	}
+
+	//the client without certificate fails the mutual TLS handshake
+	srvConfig, cliConfig = testTLSConfigs(t)
+	cliConfig.Certificates = nil
+	srv, cli = testTLSLoopback(srvConfig, cliConfig)
+	defer srv.conn.Close()
+	defer cli.conn.Close()
+
+	select {
+	case <-srv.closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("client without certificate accepted")
+	}
+}
+
+func TestTLSBufferCap(t *testing.T) {
+	//the cipher bytes waiting the session goroutine are capped at a frame
+	limit := 1024
+	closed := make(chan error, 1)
+	conn := NewTLSServer(&tls.Config{}, limit, func([]byte) error { return nil },
+		func(net.INetReceiveBuffer) error { return net.ErrAnalysisProceed },
+		func(err error) { closed <- err })
+	defer conn.Close()
+
+	if err := conn.Feed(rpctest.NewBuffer(make([]byte, limit))); err != net.ErrAnalysisProceed {
+		t.Fatalf("feed below the cap:%v", err)
+	}
+
+	if err := conn.Feed(rpctest.NewBuffer(make([]byte, constTLSRecordSize+1))); err != code.ErrDataOverflow {
+		t.Fatalf("feed above the cap:%v", err)
+	}
+
+	select {
+	case err := <-closed:
+		if err != code.ErrDataOverflow {
+			t.Fatalf("session failed:%v", err)
+		}
+	default:
+		t.Fatal("session not failed above the cap")
+	}
+
+	//the plain bytes waiting the decoder are capped at a frame and a read
+	plain := &tlsBuffer{_cap: limit + constTLSReadSize}
+	if _, err := plain.WriteBuffer(make([]byte, limit)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := plain.WriteBuffer(make([]byte, constTLSReadSize+1)); err != code.ErrDataOverflow {
+		t.Fatalf("plain bytes above the cap:%v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//testGroup returns the group of the connected accessers by handle, ids from 1
+func testGroup(clients ...*RPCSrvClient) *RPCSrvGroup {
+	group := &RPCSrvGroup{_handles: make(map[uint64]net.INetClient),
+		_sockets: make(map[int32]net.INetClient),
+		_allocer: &RPCSrvClientAllocer{_pool: &sync.Pool{}}}
+	for i, c := range clients {
+		c.SetRef(2)
+		c.WithID(uint64(i + 1))
+		group._handles[c.GetID()] = c
+		group._sz++
+	}
+	return group
+}
+
+//testWaiting waits the calls to the accesser waiting replies
+func testWaiting(t *testing.T, c *RPCSrvClient, n int) {
+	for i := 0; i < 100; i++ {
+		c._pendingSync.Lock()
+		waiting := len(c._pending)
+		c._pendingSync.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("%d calls not waiting", n)
+}
+
+func TestCallReturnDisconnect(t *testing.T) {
+	c := testMulticastClient(common.ConstVersion, nil)
+	c._closed = make(chan bool)
+	group := testGroup(c)
+	srv := &RPCServer{_clients: group, _callTimeout: 1000 * 10}
+
+	done := make(chan error, 1)
+	multicast := make(chan map[uint64]*MulticastResult, 1)
+	go func() {
+		done <- srv.CallReturn(1, "test.Call", &testMulticastMessage{}, &testMulticastMessage{})
+	}()
+	go func() {
+		multicast <- srv.MulticastReturn(context.Background(), []uint64{1}, "test.Call", &testMulticastMessage{})
+	}()
+
+	//the disconnection wakes the calls waiting the replies before the time out
+	testWaiting(t, c, 2)
+	group.Erase(1)
+	select {
+	case err := <-done:
+		if err != code.ErrConnectClosed {
+			t.Fatalf("call of the disconnected accesser:%v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("call of the disconnected accesser still waiting")
+	}
+
+	select {
+	case results := <-multicast:
+		if err := results[1].Err; err != code.ErrConnectClosed {
+			t.Fatalf("multicast call of the disconnected accesser:%v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("multicast call of the disconnected accesser still waiting")
+	}
+
+	//the calls after the disconnection return at once
+	if err := srv.CallReturn(1, "test.Call", &testMulticastMessage{}, &testMulticastMessage{}); err != code.ErrConnectNon {
+		t.Fatalf("call of the erased accesser:%v", err)
+	}
+
+	start := time.Now()
+	if _, err := c.CallReturn("test.Call", &testMulticastMessage{}); err != code.ErrConnectClosed ||
+		time.Since(start) > time.Second {
+		t.Fatalf("call of the disconnected accesser:%v", err)
+	}
+}
+
+func TestCallReturnLegacy(t *testing.T) {
+	//the accesser without negotiation gets no cancel request
+	c := testMulticastClient(common.ConstVersion, nil)
+	if c.canceller() != nil {
+		t.Fatal("cancel request sent to the accesser without negotiation")
+	}
+
+	c.withNegotiated(common.ConstVersion, common.ConstFeatures)
+	if c.canceller() == nil {
+		t.Fatal("cancel request not sent to the negotiated accesser")
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testMulticastMessage message of the multicast tests
+type testMulticastMessage struct {
+	Blob []byte `protobuf:"bytes,1,opt,name=blob,proto3" json:"blob,omitempty"`
+}
+
+func (m *testMulticastMessage) Reset()         { *m = testMulticastMessage{} }
+func (m *testMulticastMessage) String() string { return proto.CompactTextString(m) }
+func (*testMulticastMessage) ProtoMessage()    {}
+
+//testCompressor compressor counting the compressions
+type testCompressor struct {
+	common.Compressor
+	_n int
+}
+
+func (slf *testCompressor) Compress(data []byte) ([]byte, error) {
+	slf._n++
+	return slf.Compressor.Compress(data)
+}
+
+//testClients accessers of the server by handle
+type testClients map[uint64]*RPCSrvClient
+
+func (slf testClients) Grap(h uint64) net.INetClient {
+	if c, ok := slf[h]; ok {
+		return c
+	}
+	return nil
+}
+
+func (slf testClients) Release(c net.INetClient) {}
+
+//testMulticastClient returns the accesser of the version settled on the compressor
+func testMulticastClient(ver int, compressor common.Compressor) *RPCSrvClient {
+	c := &RPCSrvClient{_ver: int32(ver), _pending: make(map[uint32]chan *common.ResponseEvent)}
+	if compressor != nil {
+		c.withCompressor(compressor, common.ConstCompressThreshold)
+	}
+	return c
+}
+
+func TestMulticastFrames(t *testing.T) {
+	snappy := &testCompressor{Compressor: common.GetCompressor("snappy")}
+	param := &testMulticastMessage{Blob: bytes.Repeat([]byte("multicast "), 1000)}
+	frames := &multicastFrames{_ctx: context.Background(), _method: "test.Notify", _param: param,
+		_frames: make(map[multicastKey][]byte)}
+
+	clients := []*RPCSrvClient{testMulticastClient(common.ConstVersion, snappy),
+		testMulticastClient(common.ConstVersion, snappy),
+		testMulticastClient(common.ConstVersion2, snappy),
+		testMulticastClient(common.ConstVersion, nil)}
+
+	data := make([][]byte, len(clients))
+	for i, c := range clients {
+		frame, err := frames.frame(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[i] = frame
+	}
+
+	//compressed once by variant
+	if snappy._n != 2 || &data[0][0] != &data[1][0] {
+		t.Fatalf("%d compressions of 2 variants", snappy._n)
+	}
+
+	for i, compressed := range []bool{true, true, true, false} {
+		blk, err := common.Decode(rpctest.NewBuffer(data[i]), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (blk.Compress != 0) != compressed || blk.Ver != clients[i].Version() || blk.Method != "test.Notify" {
+			t.Fatalf("frame %d version %d compress %d", i, blk.Ver, blk.Compress)
+		}
+
+		msg := &testMulticastMessage{}
+		if err := proto.Unmarshal(blk.Data, msg); err != nil || !bytes.Equal(msg.Blob, param.Blob) {
+			t.Fatalf("frame %d data mismatch:%v", i, err)
+		}
+	}
+
+	//the compressed frame of a call keeps decoding with its serial
+	blk, err := common.Decode(rpctest.NewBuffer(common.SetSerial(data[0], 9)), 0)
+	if err != nil || blk.Ser != 9 || blk.Compress == 0 {
+		t.Fatalf("compressed frame of serial %d:%v", blk.Ser, err)
+	}
+}
+
+func TestMulticastFramesError(t *testing.T) {
+	param := &testMulticastMessage{Blob: bytes.Repeat([]byte{1}, common.VersionLimit(common.ConstVersion))}
+	frames := &multicastFrames{_ctx: context.Background(), _method: "test.Notify", _param: param,
+		_frames: make(map[multicastKey][]byte)}
+
+	if _, err := frames.frame(testMulticastClient(common.ConstVersion2, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := frames.frame(testMulticastClient(common.ConstVersion, nil)); err != code.ErrDataTooLarge {
+		t.Fatalf("version 1 frame over the limit:%v", err)
+	}
+}
+
+func TestMulticastReturnGone(t *testing.T) {
+	//the connections gone, the version 1 connections failing to encode at once,
+	//and the version 2 connections waiting the replies
+	clients := make(testClients)
+	var handles []uint64
+	for i := uint64(1); i <= 48; i++ {
+		handles = append(handles, i)
+		switch i % 3 {
+		case 1:
+			clients[i] = testMulticastClient(common.ConstVersion, nil)
+		case 2:
+			clients[i] = testMulticastClient(common.ConstVersion2, nil)
+		}
+	}
+
+	srv := &RPCServer{_clients: clients, _callTimeout: 1000}
+	param := &testMulticastMessage{Blob: bytes.Repeat([]byte{1}, common.VersionLimit(common.ConstVersion))}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	results := srv.MulticastReturn(ctx, handles, "test.Notify", param)
+	if len(results) != len(handles) {
+		t.Fatalf("%d results of %d connections", len(results), len(handles))
+	}
+
+	for _, handle := range handles {
+		err := results[handle].Err
+		switch handle % 3 {
+		case 0:
+			if err != code.ErrConnectNon {
+				t.Fatalf("connection gone %d:%v", handle, err)
+			}
+		case 1:
+			if err != code.ErrDataTooLarge {
+				t.Fatalf("version 1 connection %d:%v", handle, err)
+			}
+		case 2:
+			if err != context.DeadlineExceeded && err != code.ErrTimeOut {
+				t.Fatalf("version 2 connection %d:%v", handle, err)
+			}
+		}
+	}
+}
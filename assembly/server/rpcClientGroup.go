@@ -154,7 +154,7 @@ func (slf *RPCSrvGroup) GrapSocket(sock int32) net.INetClient {
 }
 
 //Erase doc
-//@Summary remove client
+//@Summary remove client, the calls waiting its replies return code.ErrConnectClosed
 //@Param (uint64) a client is (Handle/ID)
 func (slf *RPCSrvGroup) Erase(h uint64) {
 	slf._sync.Lock()
@@ -173,6 +173,7 @@ func (slf *RPCSrvGroup) Erase(h uint64) {
 	}
 
 	delete(slf._handles, h)
+	c.(*RPCSrvClient).disconnect()
 
 	if c.DecRef() <= 0 {
 		slf.Allocer().Delete(c)
@@ -207,6 +208,23 @@ func (slf *RPCSrvGroup) Cap() int {
 	return slf._cap
 }
 
+//Select doc
+//@Summary Returns the clients Handle/ID whose tags match the selector
+//@Param  Selector
+//@Return []uint64
+func (slf *RPCSrvGroup) Select(selector Selector) []uint64 {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+
+	var result []uint64
+	for _, v := range slf._handles {
+		if v.(*RPCSrvClient).Matches(selector) {
+			result = append(result, v.GetID())
+		}
+	}
+	return result
+}
+
 //GetHandles doc
 //@Summary Returns Clients in all connections Handle/ID
 //@Return ([]uint64) all client of (Handle/ID)
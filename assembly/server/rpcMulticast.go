@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//MulticastResult doc
+//@Summary Reply of a client to a multicast call
+//@Member proto.Message reply
+//@Member error         error of the call
+type MulticastResult struct {
+	Reply proto.Message
+	Err   error
+}
+
+//multicastKey doc
+//@Summary Frame variant of the clients, the clients of a variant share the frame
+type multicastKey struct {
+	_ver       int
+	_features  uint32
+	_codec     string
+	_md        bool
+	_compress  byte
+	_threshold int
+}
+
+//multicastFrames doc
+//@Summary Request frames of a multicast call, encoded and compressed once by variant
+type multicastFrames struct {
+	_ctx     context.Context
+	_method  string
+	_param   interface{}
+	_timeout int64
+	_frames  map[multicastKey][]byte
+	_sync    sync.Mutex
+}
+
+func (slf *multicastFrames) frame(c *RPCSrvClient) ([]byte, error) {
+	codec := c.codec(slf._ctx)
+	md := c.outgoing(slf._ctx)
+	compressor, threshold := c.compression()
+	key := multicastKey{_ver: c.Version(), _features: c.Features(), _md: md != nil}
+	if codec != nil {
+		key._codec = codec.Name()
+	}
+
+	if compressor != nil {
+		key._compress = compressor.ID()
+		key._threshold = threshold
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if data, ok := slf._frames[key]; ok {
+		return data, nil
+	}
+
+	data, err := common.CallRequest(key._ver, key._features, slf._method, 0, slf._param, slf._timeout, md, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	if compressor != nil {
+		if data, err = common.CompressFrame(data, compressor, threshold); err != nil {
+			return nil, err
+		}
+	}
+	slf._frames[key] = data
+	return data, nil
+}
+
+//Broadcast doc
+//@Summary RPC Call the function of all connections non-return, the request is encoded once
+//@Summary by protocol version, codec and compressor
+//@Param  string      remote method
+//@Param  interface{} remote method param
+//@Return error       encoding error
+func (slf *RPCServer) Broadcast(method string, param interface{}) error {
+	return slf.MulticastContext(context.Background(), slf._group.GetHandles(), method, param)
+}
+
+//Multicast doc
+//@Summary RPC Call the function of the connections non-return, the request is encoded once
+//@Summary by protocol version, codec and compressor, the connections gone are skipped,
+//@Summary nothing is sent when a variant fails to encode
+//@Param  []uint64    connection ids
+//@Param  string      remote method
+//@Param  interface{} remote method param
+//@Return error       encoding error
+func (slf *RPCServer) Multicast(handles []uint64, method string, param interface{}) error {
+	return slf.MulticastContext(context.Background(), handles, method, param)
+}
+
+//MulticastContext doc
+//@Summary RPC Call the function of the connections non-return, the context metadata
+//@Summary travels with the request, see Multicast
+//@Param  context.Context context
+//@Param  []uint64        connection ids
+//@Param  string          remote method
+//@Param  interface{}     remote method param
+//@Return error           encoding error
+func (slf *RPCServer) MulticastContext(ctx context.Context, handles []uint64, method string, param interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	frames := &multicastFrames{_ctx: ctx, _method: method, _param: param, _frames: make(map[multicastKey][]byte)}
+	clients := make([]*RPCSrvClient, 0, len(handles))
+	data := make([][]byte, 0, len(handles))
+	defer func() {
+		for _, c := range clients {
+			slf._clients.Release(c)
+		}
+	}()
+
+	for _, handle := range handles {
+		c := slf._clients.Grap(handle)
+		if c == nil {
+			continue
+		}
+		clients = append(clients, c.(*RPCSrvClient))
+
+		frame, err := frames.frame(c.(*RPCSrvClient))
+		if err != nil {
+			return err
+		}
+		data = append(data, frame)
+	}
+
+	for i, c := range clients {
+		c.sendFrame(data[i])
+	}
+	return nil
+}
+
+//BroadcastReturn doc
+//@Summary RPC Call the function of all connections and collect the replies
+//@Summary until the shared deadline, see MulticastReturn
+//@Param  context.Context context
+//@Param  string          remote method
+//@Param  interface{}     remote method param
+//@Return map[uint64]*MulticastResult replies by connection id
+func (slf *RPCServer) BroadcastReturn(ctx context.Context, method string, param interface{}) map[uint64]*MulticastResult {
+	return slf.MulticastReturn(ctx, slf._group.GetHandles(), method, param)
+}
+
+//MulticastReturn doc
+//@Summary RPC Call the function of the connections and collect the replies, the request
+//@Summary is encoded once by protocol version, codec and compressor, the calls share the deadline
+//@Summary of the context or the call time out
+//@Param  context.Context context
+//@Param  []uint64        connection ids
+//@Param  string          remote method
+//@Param  interface{}     remote method param
+//@Return map[uint64]*MulticastResult replies by connection id, code.ErrConnectNon the connections gone
+func (slf *RPCServer) MulticastReturn(ctx context.Context, handles []uint64, method string, param interface{}) map[uint64]*MulticastResult {
+	results := make(map[uint64]*MulticastResult, len(handles))
+	timeout, err := callTimeout(ctx, slf._callTimeout)
+	if err != nil {
+		for _, handle := range handles {
+			results[handle] = &MulticastResult{Err: err}
+		}
+		return results
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	frames := &multicastFrames{_ctx: ctx, _method: method, _param: param, _timeout: timeout, _frames: make(map[multicastKey][]byte)}
+	var wait sync.WaitGroup
+	var resultSync sync.Mutex
+	var gone []uint64
+	for _, handle := range handles {
+		c := slf._clients.Grap(handle)
+		if c == nil {
+			gone = append(gone, handle)
+			continue
+		}
+
+		wait.Add(1)
+		go func(handle uint64, c *RPCSrvClient) {
+			defer wait.Done()
+			defer slf._clients.Release(c)
+
+			result := &MulticastResult{}
+			data, err := frames.frame(c)
+			if err == nil {
+				result.Reply, result.Err = c.callFrame(ctx, method, data, timeout)
+			} else {
+				result.Err = err
+			}
+
+			resultSync.Lock()
+			results[handle] = result
+			resultSync.Unlock()
+		}(handle, c.(*RPCSrvClient))
+	}
+
+	wait.Wait()
+	for _, handle := range gone {
+		results[handle] = &MulticastResult{Err: code.ErrConnectNon}
+	}
+	return results
+}
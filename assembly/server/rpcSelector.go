@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yamakiller/magicRpc/code"
+)
+
+//selector term operators
+const (
+	selectorEqual = iota
+	selectorNotEqual
+	selectorExists
+	selectorNotExists
+)
+
+type selectorTerm struct {
+	_key   string
+	_value string
+	_op    int
+}
+
+//TagFilter doc
+//@Summary Filter of the tags claimed by a client handshake, returns whether the tag is set,
+//@Summary the client is authenticated when the server authenticates
+//@Param  *RPCSrvClient client
+//@Param  string        key
+//@Param  string        value
+//@Return bool
+type TagFilter func(c *RPCSrvClient, key, value string) bool
+
+//Selector doc
+//@Summary Tag selector of the clients, all terms must match
+type Selector []selectorTerm
+
+//ParseSelector doc
+//@Summary Returns the selector of the comma separated terms, key=value, key!=value,
+//@Summary key tagged, !key not tagged, empty matches all
+//@Param  string selector, e.g. service=gateway,shard=3
+//@Return Selector
+//@Return error
+func ParseSelector(s string) (Selector, error) {
+	var result Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var t selectorTerm
+		if i := strings.Index(term, "!="); i >= 0 {
+			t = selectorTerm{_key: term[:i], _value: term[i+2:], _op: selectorNotEqual}
+		} else if i := strings.IndexByte(term, '='); i >= 0 {
+			t = selectorTerm{_key: term[:i], _value: term[i+1:], _op: selectorEqual}
+		} else if strings.HasPrefix(term, "!") {
+			t = selectorTerm{_key: term[1:], _op: selectorNotExists}
+		} else {
+			t = selectorTerm{_key: term, _op: selectorExists}
+		}
+
+		t._key = strings.TrimSpace(t._key)
+		t._value = strings.TrimSpace(t._value)
+		if t._key == "" {
+			return nil, fmt.Errorf("rpc selector term %s without key", term)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+//SelectorOf doc
+//@Summary Returns the selector of the tags, all must be equal
+//@Param  map[string]string
+//@Return Selector
+func SelectorOf(tags map[string]string) Selector {
+	result := make(Selector, 0, len(tags))
+	for k, v := range tags {
+		result = append(result, selectorTerm{_key: k, _value: v, _op: selectorEqual})
+	}
+	return result
+}
+
+//Matches doc
+//@Summary Returns whether the tags match all terms
+//@Param  map[string]string tags
+//@Return bool
+func (slf Selector) Matches(tags map[string]string) bool {
+	for _, t := range slf {
+		v, ok := tags[t._key]
+		switch t._op {
+		case selectorEqual:
+			if !ok || v != t._value {
+				return false
+			}
+		case selectorNotEqual:
+			if ok && v == t._value {
+				return false
+			}
+		case selectorExists:
+			if !ok {
+				return false
+			}
+		case selectorNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//SetTag doc
+//@Summary Set a tag of the specified connection
+//@Param  uint64 connection id
+//@Param  string key
+//@Param  string value
+//@Return error
+func (slf *RPCServer) SetTag(handle uint64, key, value string) error {
+	c := slf._clients.Grap(handle)
+	if c == nil {
+		return code.ErrConnectNon
+	}
+	defer slf._clients.Release(c)
+
+	c.(*RPCSrvClient).SetTag(key, value)
+	return nil
+}
+
+//Select doc
+//@Summary Returns the connection ids whose tags match the selector
+//@Param  string selector, see ParseSelector
+//@Return []uint64
+//@Return error
+func (slf *RPCServer) Select(selector string) ([]uint64, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return slf._group.Select(s), nil
+}
+
+//BroadcastSelector doc
+//@Summary RPC Call the function of the connections whose tags match the selector
+//@Summary non-return, see Multicast
+//@Param  string      selector, see ParseSelector
+//@Param  string      remote method
+//@Param  interface{} remote method param
+//@Return error
+func (slf *RPCServer) BroadcastSelector(selector string, method string, param interface{}) error {
+	handles, err := slf.Select(selector)
+	if err != nil {
+		return err
+	}
+	return slf.Multicast(handles, method, param)
+}
+
+//BroadcastSelectorReturn doc
+//@Summary RPC Call the function of the connections whose tags match the selector
+//@Summary and collect the replies, see MulticastReturn
+//@Param  context.Context context
+//@Param  string          selector, see ParseSelector
+//@Param  string          remote method
+//@Param  interface{}     remote method param
+//@Return map[uint64]*MulticastResult replies by connection id
+//@Return error
+func (slf *RPCServer) BroadcastSelectorReturn(ctx context.Context, selector string, method string, param interface{}) (map[uint64]*MulticastResult, error) {
+	handles, err := slf.Select(selector)
+	if err != nil {
+		return nil, err
+	}
+	return slf.MulticastReturn(ctx, handles, method, param), nil
+}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/yamakiller/magicNet/network"
 
@@ -16,17 +20,30 @@ import (
 
 //Options RPC Server Options
 type Options struct {
-	Name         string
-	ServerID     int
-	Cap          int
-	KeepTime     int
-	BufferCap    int
-	OutCChanSize int
+	Name              string
+	ServerID          int
+	Cap               int
+	KeepTime          int
+	BufferCap         int
+	MaxFrameSize      int
+	OutCChanSize      int
+	TLSConfig         *tls.Config
+	Auth              common.Authenticator
+	AuthTimeout       int64
+	MinVersion        int
+	MaxVersion        int
+	Interceptors      []common.UnaryServerInterceptor
+	Recovery          bool
+	Compressors       []string
+	CompressThreshold int
+	CallTimeout       int64
+	TagFilter         TagFilter
 
 	AsyncError    listener.AsyncErrorFunc
 	AsyncComplete listener.AsyncCompleteFunc
 	AsyncClosed   listener.AsyncClosedFunc
 	AsyncAccept   func(uint64)
+	AsyncAuth     func(uint64, string) error
 }
 
 //Option is a function on the options for a rpc listen.
@@ -72,6 +89,19 @@ func WithClientBufferCap(cap int) Option {
 	}
 }
 
+//WithMaxFrameSize Set frame size limit option of the frames from the clients,
+//the data decompressed included, a larger frame closes the connection,
+//the tls bytes waiting decode are capped at a frame
+func WithMaxFrameSize(n int) Option {
+	return func(o *Options) error {
+		if n <= 0 {
+			return errors.New("rpc max frame size must be positive")
+		}
+		o.MaxFrameSize = n
+		return nil
+	}
+}
+
 //WithClientOutSize Set client recvice call chan size option
 func WithClientOutSize(outSize int) Option {
 	return func(o *Options) error {
@@ -80,6 +110,121 @@ func WithClientOutSize(outSize int) Option {
 	}
 }
 
+//WithTLSConfig Set TLS config option, the connections are served over TLS,
+//set ClientAuth and ClientCAs to require the client certificate for mutual TLS
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *Options) error {
+		o.TLSConfig = config
+		return nil
+	}
+}
+
+//WithVersions Set supported protocol versions option, the handshake settles
+//on the highest version both sides support, raise min to retire old clients
+func WithVersions(min, max int) Option {
+	return func(o *Options) error {
+		if min < common.ConstVersion || max > common.ConstVersionMax || min > max {
+			return code.ErrVersionUnsupported
+		}
+		o.MinVersion = min
+		o.MaxVersion = max
+		return nil
+	}
+}
+
+//WithAuthenticator Set handshake authenticator option, the connections
+//must authenticate before calling
+func WithAuthenticator(auth common.Authenticator) Option {
+	return func(o *Options) error {
+		o.Auth = auth
+		return nil
+	}
+}
+
+//WithAuthTimeout Set time out option of the handshake authentication/millsecond,
+//the connections not authenticated in time are closed
+func WithAuthTimeout(tm int64) Option {
+	return func(o *Options) error {
+		if tm <= 0 {
+			return errors.New("rpc auth time out must be positive")
+		}
+		o.AuthTimeout = tm
+		return nil
+	}
+}
+
+//WithUnaryInterceptor Append unary interceptors option, the chain runs in order
+//around the registered methods
+func WithUnaryInterceptor(interceptors ...common.UnaryServerInterceptor) Option {
+	return func(o *Options) error {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+		return nil
+	}
+}
+
+//WithCompressor Set compressors option in order of preference, the client
+//settles the first it supports, registered names only
+func WithCompressor(names ...string) Option {
+	return func(o *Options) error {
+		for _, name := range names {
+			if common.GetCompressor(name) == nil {
+				return fmt.Errorf("rpc compressor %s undefined", name)
+			}
+		}
+		o.Compressors = names
+		return nil
+	}
+}
+
+//WithCompressThreshold Set data length option below which frames are sent raw
+func WithCompressThreshold(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return errors.New("rpc compress threshold must not be negative")
+		}
+		o.CompressThreshold = n
+		return nil
+	}
+}
+
+//WithRecovery Set method panic recovery option, enabled by default, a recovered
+//panic is logged with the stack and replies an internal error, disable in tests
+//to let the panic through
+func WithRecovery(enable bool) Option {
+	return func(o *Options) error {
+		o.Recovery = enable
+		return nil
+	}
+}
+
+//WithCallTimeout Set time out of the calls to the clients waiting replies/millsecond
+func WithCallTimeout(tm int64) Option {
+	return func(o *Options) error {
+		if tm <= 0 {
+			return errors.New("rpc call time out must be positive")
+		}
+		o.CallTimeout = tm
+		return nil
+	}
+}
+
+//WithTagFilter Set filter of the tags claimed by the client handshakes, nil sets all
+//claimed tags, a tag set by the server is never replaced by a claimed tag
+func WithTagFilter(f TagFilter) Option {
+	return func(o *Options) error {
+		o.TagFilter = f
+		return nil
+	}
+}
+
+//WithAsyncAuth Set client authenticated callback, accept or reject the identity
+func WithAsyncAuth(f func(uint64, string) error) Option {
+	return func(o *Options) error {
+		o.AsyncAuth = f
+		return nil
+	}
+}
+
 //WithAsyncError Set Listen fail Async Error callback option
 func WithAsyncError(f listener.AsyncErrorFunc) Option {
 	return func(o *Options) error {
@@ -114,11 +259,18 @@ func WithAsyncClosed(f listener.AsyncClosedFunc) Option {
 
 var (
 	defaultOption = Options{Name: "rpc server",
-		ServerID:     1,
-		Cap:          1024,
-		BufferCap:    8196,
-		KeepTime:     1000 * 60,
-		OutCChanSize: 512,
+		ServerID:          1,
+		Cap:               1024,
+		BufferCap:         8196,
+		MaxFrameSize:      common.ConstMaxFrameSize,
+		KeepTime:          1000 * 60,
+		OutCChanSize:      512,
+		MinVersion:        common.ConstVersion,
+		MaxVersion:        common.ConstVersionMax,
+		Recovery:          true,
+		CompressThreshold: common.ConstCompressThreshold,
+		CallTimeout:       1000 * 10,
+		AuthTimeout:       1000 * 10,
 	}
 )
 
@@ -135,11 +287,26 @@ func New(options ...Option) (*RPCServer, error) {
 		}
 	}
 
-	rpc := &RPCServer{_rpcs: make(map[string]interface{})}
+	rpc := &RPCServer{_rpcs: make(map[string]*common.RPCService)}
 	rpc._asyncAccept = opts.AsyncAccept
 	rpc._asyncClosed = opts.AsyncClosed
+	rpc._tlsConfig = opts.TLSConfig
+	rpc._auth = opts.Auth
+	rpc._authTimeout = opts.AuthTimeout
+	rpc._minVer = opts.MinVersion
+	rpc._maxVer = opts.MaxVersion
+	rpc._asyncAuth = opts.AsyncAuth
+	rpc._bfSize = opts.BufferCap
+	rpc._maxFrame = opts.MaxFrameSize
+	rpc._interceptor = common.ChainUnaryServer(opts.Interceptors...)
+	rpc._recovery = opts.Recovery
+	rpc._compressors = opts.Compressors
+	rpc._threshold = opts.CompressThreshold
+	rpc._callTimeout = opts.CallTimeout
+	rpc._tagFilter = opts.TagFilter
 	handler.Spawn(opts.Name, func() handler.IService {
 		group := &RPCSrvGroup{_id: opts.ServerID, _bfSize: opts.BufferCap, _cap: opts.Cap}
+		rpc._group = group
 
 		h, err := listener.Spawn(
 			listener.WithListener(&net.TCPListen{}),
@@ -156,6 +323,7 @@ func New(options ...Option) (*RPCServer, error) {
 			return nil
 		}
 		rpc._listen = h
+		rpc._clients = h
 		rpc._listen.Initial()
 		return rpc._listen
 	})
@@ -166,12 +334,49 @@ func New(options ...Option) (*RPCServer, error) {
 //RPCServer doc
 //@Summary RPC Server
 //@
-//@Member map[string]interface{}  RPC Function map table
+//@Member map[string]*common.RPCService  RPC Function dispatch table
+//@Member *tls.Config  TLS config, nil plain TCP
+//@Member common.Authenticator handshake authenticator, nil non-authentication
+//@Member int64 time out of the handshake authentication/millsecond
+//@Member int lowest supported version
+//@Member int highest supported version
+//@Member common.UnaryServerInterceptor interceptor chain, nil none
+//@Member bool method panic recovery
+//@Member []string compressors in order of preference
+//@Member int data length below which frames are sent raw
+//@Member int64 time out of the calls waiting replies/millsecond
+//@Member TagFilter filter of the tags claimed by the client handshakes, nil sets all
+//@Member int frame size limit of the received frames
+//@Member *RPCSrvGroup connections of the broadcasts
+//@Member rpcClients accessers of the calls by handle, the listener
 type RPCServer struct {
 	_listen      *listener.NetListener
-	_rpcs        map[string]interface{}
+	_clients     rpcClients
+	_group       *RPCSrvGroup
+	_rpcs        map[string]*common.RPCService
 	_asyncAccept func(uint64)
 	_asyncClosed listener.AsyncClosedFunc
+	_asyncAuth   func(uint64, string) error
+	_tlsConfig   *tls.Config
+	_auth        common.Authenticator
+	_authTimeout int64
+	_minVer      int
+	_maxVer      int
+	_bfSize      int
+	_maxFrame    int
+	_interceptor common.UnaryServerInterceptor
+	_recovery    bool
+	_compressors []string
+	_threshold   int
+	_callTimeout int64
+	_tagFilter   TagFilter
+}
+
+//rpcClients doc
+//@Summary Accessers by handle, a grapped accesser is released once done
+type rpcClients interface {
+	Grap(uint64) net.INetClient
+	Release(net.INetClient)
 }
 
 //Listen doc
@@ -186,13 +391,13 @@ func (slf *RPCServer) Listen(addr string) error {
 //@Summary RPC Server close client
 //@Param client handle
 func (slf *RPCServer) CloseClient(handle uint64) {
-	c := slf._listen.Grap(handle)
+	c := slf._clients.Grap(handle)
 	if c == nil {
 		return
 	}
 
 	network.OperClose(c.GetSocket())
-	slf._listen.Release(c)
+	slf._clients.Release(c)
 }
 
 //Shutdown doc
@@ -212,13 +417,64 @@ func (slf *RPCServer) Shutdown() {
 //@Param  string remote method
 //@Param  interface{} remote method param
 func (slf *RPCServer) Call(handle uint64, method string, param interface{}) error {
-	c := slf._listen.Grap(handle)
+	return slf.CallContext(context.Background(), handle, method, param)
+}
+
+//CallContext doc
+//@Summary RPC Call the function of the specified connection, the context metadata travels with the request
+//@Param context.Context context
+//@Param uint64  connection id
+//@Param  string remote method
+//@Param  interface{} remote method param
+func (slf *RPCServer) CallContext(ctx context.Context, handle uint64, method string, param interface{}) error {
+	c := slf._clients.Grap(handle)
 	if c == nil {
 		return code.ErrConnectNon
 	}
 
-	defer slf._listen.Release(c)
-	return c.(*RPCSrvClient).Call(method, param)
+	defer slf._clients.Release(c)
+	return c.(*RPCSrvClient).CallContext(ctx, method, param)
+}
+
+//CallReturn doc
+//@Summary RPC Call the function of the specified connection and wait the reply
+//@Summary until the call time out
+//@Param uint64       connection id
+//@Param string       remote method
+//@Param interface{}  remote method param
+//@Param interface{}  reply, pointer of the reply message
+//@Return error
+func (slf *RPCServer) CallReturn(handle uint64, method string, param, ret interface{}) error {
+	return slf.CallReturnContext(context.Background(), handle, method, param, ret)
+}
+
+//CallReturnContext doc
+//@Summary RPC Call the function of the specified connection and wait the reply
+//@Summary until the context is done or the call time out, the remaining time out
+//@Summary and the context metadata travel with the request
+//@Param context.Context context
+//@Param uint64       connection id
+//@Param string       remote method
+//@Param interface{}  remote method param
+//@Param interface{}  reply, pointer of the reply message
+//@Return error
+func (slf *RPCServer) CallReturnContext(ctx context.Context, handle uint64, method string, param, ret interface{}) error {
+	c := slf._clients.Grap(handle)
+	if c == nil {
+		return code.ErrConnectNon
+	}
+	defer slf._clients.Release(c)
+
+	r, err := c.(*RPCSrvClient).CallReturnContext(ctx, method, param)
+	if err != nil {
+		return err
+	}
+
+	if r == nil {
+		return code.NewError(code.CodeNoReturn, "RPC call returned nil", nil)
+	}
+	reflect.ValueOf(ret).Elem().Set(reflect.ValueOf(r).Elem())
+	return nil
 }
 
 func (slf *RPCServer) rpcClosed(id uint64) error {
@@ -229,12 +485,37 @@ func (slf *RPCServer) rpcClosed(id uint64) error {
 }
 
 func (slf *RPCServer) rpcAccept(c net.INetClient) error {
-	x := make([]byte, 1)
-	x[0] = common.ConstHandShakeCode
-	if err := c.(*RPCSrvClient).SendTo(x); err != nil {
+	slf.rpcTLS(c.(*RPCSrvClient))
+	c.(*RPCSrvClient).withInterceptor(slf._interceptor)
+	c.(*RPCSrvClient).withRecovery(slf._recovery)
+	c.(*RPCSrvClient).withCallTimeout(slf._callTimeout)
+	//the 1 byte handshake code, followed by the offer a client without negotiation
+	//drops, or the challenge when the connection must authenticate
+	x := []byte{common.ConstHandShakeCode}
+	var data []byte
+	var err error
+	if slf._auth != nil {
+		x[0] = common.ConstHandShakeAuthCode
+		data, err = slf.rpcChallenge(c.(*RPCSrvClient))
+	} else {
+		data, err = slf.rpcOffer()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.(*RPCSrvClient).SendTo(append(x, data...)); err != nil {
 		return err
 	}
 
+	if slf._auth != nil {
+		listen, handle := slf._listen, c.GetID()
+		time.AfterFunc(time.Duration(slf._authTimeout)*time.Millisecond, func() {
+			slf.rpcAuthExpire(listen, handle)
+		})
+	}
+
 	if slf._asyncAccept != nil {
 		slf._asyncAccept(c.GetID())
 	}
@@ -243,7 +524,22 @@ func (slf *RPCServer) rpcAccept(c net.INetClient) error {
 
 func (slf *RPCServer) rpcDecode(context actor.Context, params ...interface{}) error {
 	c := params[1].(net.INetClient)
-	data, err := common.RPCDecodeServer(slf.getRPC, c)
+	if t := slf.rpcTLS(c.(*RPCSrvClient)); t != nil {
+		return t.Feed(c)
+	}
+	return slf.rpcDispatch(c.(*RPCSrvClient), c)
+}
+
+func (slf *RPCServer) rpcDispatch(c *RPCSrvClient, bf net.INetReceiveBuffer) error {
+	if slf._auth != nil && !c.Authenticated() {
+		data, err := common.RPCDecodeHandshake(bf, slf._bfSize)
+		if err != nil {
+			return err
+		}
+		return slf.rpcAuthenticate(c, data)
+	}
+
+	data, err := common.RPCDecodeServer(slf.getRPC, bf, slf._maxFrame)
 	if err != nil {
 		if err == code.ErrIncompleteData {
 			return net.ErrAnalysisProceed
@@ -251,11 +547,209 @@ func (slf *RPCServer) rpcDecode(context actor.Context, params ...interface{}) er
 		return err
 	}
 
-	actor.DefaultSchedulerContext.Send((c.(*RPCSrvClient)).GetPID(), data)
+	if event, ok := data.(*common.HelloEvent); ok {
+		return slf.rpcNegotiate(c, event)
+	}
+
+	//the first frame other than the answer, the accesser without negotiation
+	c.settle()
+	if err := slf.rpcVersionCheck(c, data); err != nil {
+		return err
+	}
+
+	if c.route(data) {
+		return net.ErrAnalysisSuccess
+	}
+
+	actor.DefaultSchedulerContext.Send(c.GetPID(), data)
+	return net.ErrAnalysisSuccess
+}
+
+func (slf *RPCServer) rpcOffer() ([]byte, error) {
+	return common.EncodeHello(&common.HelloEvent{MinVersion: slf._minVer,
+		MaxVersion:  slf._maxVer,
+		Features:    common.ConstFeatures,
+		Compressors: slf._compressors,
+		Codecs:      common.Codecs()})
+}
+
+//rpcNegotiate doc
+//@Summary Settle the version and features the accesser answered once after the authentication,
+//@Summary reject the version out of the supported and an answer after the settlement,
+//@Summary the claimed tags are kept and set through the tag filter
+//@Param  *RPCSrvClient     accesser
+//@Param  *common.HelloEvent answer
+//@Return error
+func (slf *RPCServer) rpcNegotiate(c *RPCSrvClient, event *common.HelloEvent) error {
+	if (slf._auth != nil && !c.Authenticated()) || !c.settle() {
+		c.LogError("RPC version negotiation error:%+v", code.ErrHandshake)
+		return code.ErrHandshake
+	}
+
+	if event.Version < slf._minVer || event.Version > slf._maxVer {
+		c.LogError("RPC version negotiation error:%d not in [%d, %d]", event.Version, slf._minVer, slf._maxVer)
+		data, err := common.EncodeHello(&common.HelloEvent{MinVersion: slf._minVer,
+			MaxVersion: slf._maxVer,
+			Code:       code.CodeVersionUnsupported,
+			Message:    code.ErrVersionUnsupported.Error()})
+		if err == nil {
+			c.SendTo(data)
+		}
+		return code.ErrVersionUnsupported
+	}
+
+	features := common.NegotiateFeatures(event.Version, common.ConstFeatures, event.Features)
+	compressor := common.NegotiateCompressor(event.Compressors, slf._compressors)
+	if compressor == "" {
+		features &^= common.FeatureCompression
+	}
+
+	c.withNegotiated(event.Version, features)
+	c.withCompressor(common.GetCompressor(compressor), slf._threshold)
+	if len(event.Codecs) > 0 {
+		c.withCodec(common.GetCodec(event.Codecs[0]))
+	}
+
+	c.claim(event.Tags, slf._tagFilter)
+	return net.ErrAnalysisSuccess
+}
+
+//rpcVersionCheck doc
+//@Summary Reject the frames of a version out of the supported, the accesser
+//@Summary without negotiation
+//@Param  *RPCSrvClient accesser
+//@Param  interface{}   decoded event
+//@Return error
+func (slf *RPCServer) rpcVersionCheck(c *RPCSrvClient, data interface{}) error {
+	var request *common.RequestEvent
+	var ver int
+	switch event := data.(type) {
+	case *common.RequestEvent:
+		request = event
+		ver = event.Ver
+	case *common.StreamEvent:
+		ver = event.Ver
+	default:
+		return nil
+	}
+
+	if ver >= slf._minVer && ver <= slf._maxVer {
+		return nil
+	}
+
+	c.LogError("RPC version error:%d not in [%d, %d]", ver, slf._minVer, slf._maxVer)
+	if request != nil && request.Ser != 0 {
+		if result, err := common.EncodeError(ver, request.MethodName, request.Ser,
+			code.NewError(code.CodeVersionUnsupported, code.ErrVersionUnsupported.Error(), nil)); err == nil {
+			c.SendTo(result)
+		}
+	}
+	return code.ErrVersionUnsupported
+}
+
+func (slf *RPCServer) rpcChallenge(c *RPCSrvClient) ([]byte, error) {
+	challenge, err := slf._auth.Challenge()
+	if err != nil {
+		return nil, err
+	}
+
+	c.withChallenge(challenge)
+	return common.EncodeAuth(c.Version(), &common.AuthEvent{Name: slf._auth.Name(),
+		Oper:      common.RPCRequest,
+		Challenge: challenge})
+}
+
+//rpcAuthenticate doc
+//@Summary Verify the proof of the accesser, anything else before authenticated
+//@Summary is rejected and the connection closed
+//@Param  *RPCSrvClient accesser
+//@Param  interface{}   decoded event
+//@Return error
+func (slf *RPCServer) rpcAuthenticate(c *RPCSrvClient, data interface{}) error {
+	event, ok := data.(*common.AuthEvent)
+	if !ok || event.Oper != common.RPCRequest || event.Name != slf._auth.Name() {
+		slf.rpcReject(c, code.ErrUnauthenticated)
+		return code.ErrUnauthenticated
+	}
+
+	err := slf._auth.Verify(c.challenge(), event.Identity, event.Proof)
+	if err == nil && slf._asyncAuth != nil {
+		err = slf._asyncAuth(c.GetID(), event.Identity)
+	}
+
+	if err != nil {
+		slf.rpcReject(c, err)
+		return code.ErrUnauthenticated
+	}
+
+	c.withIdentity(event.Identity)
+	result, err := common.EncodeAuth(c.Version(), &common.AuthEvent{Name: slf._auth.Name(),
+		Oper:     common.RPCResponse,
+		Identity: event.Identity})
+	if err != nil {
+		return err
+	}
+
+	//the offer follows the result, negotiation runs once authenticated
+	offer, err := slf.rpcOffer()
+	if err != nil {
+		return err
+	}
+
+	if err := c.SendTo(append(result, offer...)); err != nil {
+		return err
+	}
 	return net.ErrAnalysisSuccess
 }
 
-func (slf *RPCServer) getRPC(name string) interface{} {
+//rpcAuthExpire doc
+//@Summary Close the accesser not authenticated in time
+//@Param  *listener.NetListener listener of the accesser
+//@Param  uint64                accesser handle
+func (slf *RPCServer) rpcAuthExpire(listen *listener.NetListener, handle uint64) {
+	c := listen.Grap(handle)
+	if c == nil {
+		return
+	}
+	defer listen.Release(c)
+
+	if c.(*RPCSrvClient).Authenticated() {
+		return
+	}
+
+	c.(*RPCSrvClient).LogError("RPC authentication error:%+v", code.ErrTimeOut)
+	network.OperClose(c.GetSocket())
+}
+
+func (slf *RPCServer) rpcReject(c *RPCSrvClient, err error) {
+	c.LogError("RPC authentication error:%+v", err)
+	data, e := common.EncodeAuth(c.Version(), &common.AuthEvent{Name: slf._auth.Name(),
+		Oper:    common.RPCResponse,
+		Code:    code.CodeUnauthenticated,
+		Message: code.ToError(err).Message})
+	if e == nil {
+		c.SendTo(data)
+	}
+}
+
+func (slf *RPCServer) rpcTLS(c *RPCSrvClient) *common.TLSConn {
+	if slf._tlsConfig == nil {
+		return nil
+	}
+
+	return c.withTLS(func(sendto func([]byte) error) *common.TLSConn {
+		return common.NewTLSServer(slf._tlsConfig, slf._maxFrame, sendto,
+			func(bf net.INetReceiveBuffer) error {
+				return slf.rpcDispatch(c, bf)
+			},
+			func(err error) {
+				c.LogError("RPC TLS error:%+v", err)
+				network.OperClose(c.GetSocket())
+			})
+	})
+}
+
+func (slf *RPCServer) getRPC(name string) *common.RPCService {
 	f, ok := slf._rpcs[name]
 	if !ok {
 		return nil
@@ -265,7 +759,8 @@ func (slf *RPCServer) getRPC(name string) interface{} {
 }
 
 //RegRPC doc
-//@Summary Register RPC Accesser function
+//@Summary Register RPC Accesser function, the exported methods signature
+//@Summary is validated and cached, see common.NewRPCService
 //@Method RegRPC
 //@Param  interface{} function
 //@Return error
@@ -273,6 +768,24 @@ func (slf *RPCServer) RegRPC(met interface{}) error {
 	if reflect.ValueOf(met).Type().Kind() != reflect.Ptr {
 		return errors.New("need object")
 	}
-	slf._rpcs[reflect.TypeOf(met).Elem().Name()] = met
+	return slf.RegRPCName(reflect.TypeOf(met).Elem().Name(), met)
+}
+
+//RegRPCName doc
+//@Summary Register RPC Accesser function with the name, called as name.method
+//@Method RegRPCName
+//@Param  string      name
+//@Param  interface{} function
+//@Return error
+func (slf *RPCServer) RegRPCName(name string, met interface{}) error {
+	if reflect.ValueOf(met).Type().Kind() != reflect.Ptr {
+		return errors.New("need object")
+	}
+
+	svc, err := common.NewRPCService(name, met)
+	if err != nil {
+		return err
+	}
+	slf._rpcs[name] = svc
 	return nil
 }
@@ -1,9 +1,18 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
 	"github.com/yamakiller/magicNet/engine/actor"
 	"github.com/yamakiller/magicNet/handler/implement/client"
 	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
 )
 
 //RPCSrvClient doc
@@ -11,9 +20,42 @@ import (
 //@Struct RPCSrvClient
 //@
 //@Member uint64 is handle/id
+//@Member *common.TLSConn tls session, nil plain TCP
+//@Member string identity authenticated by the handshake
+//@Member common.UnaryServerInterceptor server interceptor chain
+//@Member bool method panic recovery
+//@Member common.Compressor settled compressor, nil raw
+//@Member common.Codec settled codec of the calls to the accesser, nil protobuf
+//@Member int64 time out of the calls waiting replies/millsecond
+//@Member map[uint32]chan *common.ResponseEvent calls waiting replies
+//@Member chan bool closed when the accesser disconnects, wakes the calls waiting replies
+//@Member map[string]string tags of the accesser, set by the server or claimed by the handshake
+//@Member map[string]string tags claimed by the handshake
 type RPCSrvClient struct {
 	client.NetSSrvCleint
-	_handle uint64
+	common.RPCContexts
+	common.RPCStreams
+	_handle      uint64
+	_ver         int32
+	_features    uint32
+	_negotiated  int32
+	_serial      uint32
+	_tls         *common.TLSConn
+	_identity    string
+	_challenge   []byte
+	_authed      int32
+	_interceptor common.UnaryServerInterceptor
+	_recovery    bool
+	_compressor  common.Compressor
+	_threshold   int
+	_codec       common.Codec
+	_timeout     int64
+	_pending     map[uint32]chan *common.ResponseEvent
+	_pendingSync sync.Mutex
+	_closed      chan bool
+	_tags        map[string]string
+	_claims      map[string]string
+	_sync        sync.Mutex
 }
 
 //Initial doc
@@ -21,9 +63,320 @@ type RPCSrvClient struct {
 //@Method Initial
 func (slf *RPCSrvClient) Initial() {
 	slf.NetSSrvCleint.Initial()
+	slf.InitialContexts()
+	slf.InitialStreams()
+	atomic.StoreInt32(&slf._ver, common.ConstVersion)
+	slf._features = common.ConstLegacyFeatures
+	slf._negotiated = 0
+	slf._pending = make(map[uint32]chan *common.ResponseEvent)
+	slf._sync.Lock()
+	slf._closed = make(chan bool)
+	slf._sync.Unlock()
 	slf.RegisterMethod(&common.RequestEvent{}, slf.onRequest)
 }
 
+//Shutdown doc
+//@Summary Shutdown rpc server accesser, cancel running request contexts
+//@Method Shutdown
+func (slf *RPCSrvClient) Shutdown() {
+	slf.ShutdownContexts()
+	slf.ShutdownStreams()
+	slf.disconnect()
+	slf.shutdownPending()
+	slf._sync.Lock()
+	if slf._tls != nil {
+		slf._tls.Close()
+		slf._tls = nil
+	}
+	slf._identity = ""
+	slf._challenge = nil
+	slf._compressor = nil
+	slf._codec = nil
+	slf._tags = nil
+	slf._claims = nil
+	slf._sync.Unlock()
+	atomic.StoreInt32(&slf._authed, 0)
+	slf.NetSSrvCleint.Shutdown()
+}
+
+//UnaryInterceptor doc
+//@Summary Returns the server interceptor chain of the requests, nil none
+//@Return common.UnaryServerInterceptor
+func (slf *RPCSrvClient) UnaryInterceptor() common.UnaryServerInterceptor {
+	return slf._interceptor
+}
+
+//Recovery doc
+//@Summary Returns the method panics are recovered
+//@Return bool
+func (slf *RPCSrvClient) Recovery() bool {
+	return slf._recovery
+}
+
+//OnPanic doc
+//@Summary Log a recovered method panic with the stack
+//@Param string      method name
+//@Param interface{} panic value
+//@Param []byte      stack
+func (slf *RPCSrvClient) OnPanic(methodName string, r interface{}, stack []byte) {
+	slf.LogError("RPC method %s panic:%+v\n%s", methodName, r, stack)
+}
+
+func (slf *RPCSrvClient) withInterceptor(interceptor common.UnaryServerInterceptor) {
+	slf._interceptor = interceptor
+}
+
+func (slf *RPCSrvClient) withCallTimeout(tm int64) {
+	slf._timeout = tm
+}
+
+func (slf *RPCSrvClient) withRecovery(recovery bool) {
+	slf._recovery = recovery
+}
+
+//withCompressor doc
+//@Summary Settle the compressor of the frames sent to the accesser
+//@Param common.Compressor compressor, nil raw
+//@Param int               data length below which frames are sent raw
+func (slf *RPCSrvClient) withCompressor(compressor common.Compressor, threshold int) {
+	slf._sync.Lock()
+	slf._compressor = compressor
+	slf._threshold = threshold
+	slf._sync.Unlock()
+}
+
+//compression doc
+//@Summary Returns the settled compressor, nil raw, and the data length below which
+//@Summary frames are sent raw
+func (slf *RPCSrvClient) compression() (common.Compressor, int) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._compressor, slf._threshold
+}
+
+//SetTag doc
+//@Summary Set a tag of the accesser, service name, region, shard or label
+//@Param  string key
+//@Param  string value
+func (slf *RPCSrvClient) SetTag(key, value string) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if slf._tags == nil {
+		slf._tags = make(map[string]string)
+	}
+	slf._tags[key] = value
+}
+
+//DeleteTag doc
+//@Summary Delete a tag of the accesser
+//@Param  string key
+func (slf *RPCSrvClient) DeleteTag(key string) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	delete(slf._tags, key)
+}
+
+//Tag doc
+//@Summary Returns a tag of the accesser
+//@Param  string key
+//@Return string value
+//@Return bool   tagged
+func (slf *RPCSrvClient) Tag(key string) (string, bool) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	v, ok := slf._tags[key]
+	return v, ok
+}
+
+//Tags doc
+//@Summary Returns a copy of the tags of the accesser
+//@Return map[string]string
+func (slf *RPCSrvClient) Tags() map[string]string {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	result := make(map[string]string, len(slf._tags))
+	for k, v := range slf._tags {
+		result[k] = v
+	}
+	return result
+}
+
+//Claims doc
+//@Summary Returns a copy of the tags claimed by the handshake, set or not
+//@Return map[string]string
+func (slf *RPCSrvClient) Claims() map[string]string {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	result := make(map[string]string, len(slf._claims))
+	for k, v := range slf._claims {
+		result[k] = v
+	}
+	return result
+}
+
+//claim doc
+//@Summary Keep the tags claimed by the handshake, the tags the filter accepts are set
+//@Summary unless set by the server, nil filter accepts all
+func (slf *RPCSrvClient) claim(tags map[string]string, filter TagFilter) {
+	accepted := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if filter == nil || filter(slf, k, v) {
+			accepted[k] = v
+		}
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	slf._claims = make(map[string]string, len(tags))
+	for k, v := range tags {
+		slf._claims[k] = v
+	}
+
+	if slf._tags == nil {
+		slf._tags = make(map[string]string, len(accepted))
+	}
+
+	for k, v := range accepted {
+		if _, ok := slf._tags[k]; !ok {
+			slf._tags[k] = v
+		}
+	}
+}
+
+//Matches doc
+//@Summary Returns whether the tags of the accesser match the selector
+//@Param  Selector
+//@Return bool
+func (slf *RPCSrvClient) Matches(selector Selector) bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return selector.Matches(slf._tags)
+}
+
+//withCodec doc
+//@Summary Settle the codec of the calls to the accesser
+//@Param common.Codec codec, nil protobuf
+func (slf *RPCSrvClient) withCodec(codec common.Codec) {
+	slf._sync.Lock()
+	slf._codec = codec
+	slf._sync.Unlock()
+}
+
+//codec doc
+//@Summary Returns the codec of the context, otherwise the settled codec
+func (slf *RPCSrvClient) codec(ctx context.Context) common.Codec {
+	if codec := common.CodecFromContext(ctx); codec != nil {
+		return codec
+	}
+
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._codec
+}
+
+//SendTo doc
+//@Summary Send data to the accesser, compressed when settled, over the tls session when TLS
+//@Param  []byte data
+//@Return error
+func (slf *RPCSrvClient) SendTo(data []byte) error {
+	if compressor, threshold := slf.compression(); compressor != nil {
+		var err error
+		if data, err = common.CompressFrame(data, compressor, threshold); err != nil {
+			return err
+		}
+	}
+	return slf.sendFrame(data)
+}
+
+//sendFrame doc
+//@Summary Send a frame as it is, over the tls session when TLS
+//@Param  []byte frame
+//@Return error
+func (slf *RPCSrvClient) sendFrame(data []byte) error {
+	slf._sync.Lock()
+	t := slf._tls
+	slf._sync.Unlock()
+	if t != nil {
+		return t.SendTo(data)
+	}
+	return slf.NetSSrvCleint.SendTo(data)
+}
+
+//TLSState doc
+//@Summary Returns the TLS connection state, false non-TLS or handshake not complete
+//@Return tls.ConnectionState
+//@Return bool
+func (slf *RPCSrvClient) TLSState() (tls.ConnectionState, bool) {
+	slf._sync.Lock()
+	t := slf._tls
+	slf._sync.Unlock()
+	if t == nil {
+		return tls.ConnectionState{}, false
+	}
+	return t.ConnectionState()
+}
+
+//PeerCertificates doc
+//@Summary Returns the certificates the accesser presented, nil non-TLS or none
+//@Return []*x509.Certificate
+func (slf *RPCSrvClient) PeerCertificates() []*x509.Certificate {
+	state, ok := slf.TLSState()
+	if !ok {
+		return nil
+	}
+	return state.PeerCertificates
+}
+
+//Identity doc
+//@Summary Returns the identity authenticated by the handshake, empty non-authentication
+//@Return string
+func (slf *RPCSrvClient) Identity() string {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._identity
+}
+
+//Authenticated doc
+//@Summary Returns the handshake authenticated the accesser
+//@Return bool
+func (slf *RPCSrvClient) Authenticated() bool {
+	return atomic.LoadInt32(&slf._authed) == 1
+}
+
+func (slf *RPCSrvClient) withChallenge(challenge []byte) {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	slf._challenge = challenge
+}
+
+func (slf *RPCSrvClient) challenge() []byte {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._challenge
+}
+
+func (slf *RPCSrvClient) withIdentity(identity string) {
+	slf._sync.Lock()
+	slf._identity = identity
+	slf._challenge = nil
+	slf._sync.Unlock()
+	atomic.StoreInt32(&slf._authed, 1)
+}
+
+//withTLS doc
+//@Summary Returns the tls session, started by the new function once
+//@Param  func(func([]byte) error) *common.TLSConn new session of the raw send
+//@Return *common.TLSConn
+func (slf *RPCSrvClient) withTLS(f func(func([]byte) error) *common.TLSConn) *common.TLSConn {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if slf._tls == nil {
+		slf._tls = f(slf.NetSSrvCleint.SendTo)
+		slf._tls.Start()
+	}
+	return slf._tls
+}
+
 //WithID doc
 //@Summary Setting handle/id
 //@Param uint64  handle/id
@@ -38,15 +391,268 @@ func (slf *RPCSrvClient) GetID() uint64 {
 	return slf._handle
 }
 
+//Version doc
+//@Summary Returns the protocol version the client speaks
+//@Return int
+func (slf *RPCSrvClient) Version() int {
+	return int(atomic.LoadInt32(&slf._ver))
+}
+
+//Features doc
+//@Summary Returns the protocol features settled with the client
+//@Return uint32
+func (slf *RPCSrvClient) Features() uint32 {
+	return atomic.LoadUint32(&slf._features)
+}
+
+//settle doc
+//@Summary Settle the negotiation once, by the answer or by the first frame of
+//@Summary the client without negotiation, which keeps version 1 and the legacy features
+//@Return bool false settled already
+func (slf *RPCSrvClient) settle() bool {
+	return atomic.CompareAndSwapInt32(&slf._negotiated, 0, 1)
+}
+
+//withNegotiated doc
+//@Summary Settle the protocol version and features negotiated with the client
+//@Param int    version
+//@Param uint32 features
+func (slf *RPCSrvClient) withNegotiated(ver int, features uint32) {
+	atomic.StoreInt32(&slf._ver, int32(ver))
+	atomic.StoreUint32(&slf._features, features)
+}
+
 //Call doc
 func (slf *RPCSrvClient) Call(method string, param interface{}) error {
-	data, err := common.Call(method, param)
+	return slf.CallContext(context.Background(), method, param)
+}
+
+//CallContext doc
+//@Summary Call client function non-return, the context metadata travels with the request,
+//@Summary serialized by the context codec or the settled codec
+//@Param  context.Context context
+//@Param  string          method
+//@Param  interface{}     param
+//@Return error
+func (slf *RPCSrvClient) CallContext(ctx context.Context, method string, param interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := common.CallRequest(slf.Version(), slf.Features(), method, 0, param, 0, slf.outgoing(ctx), slf.codec(ctx))
 	if err != nil {
 		return err
 	}
 	return slf.SendTo(data)
 }
 
+//CallReturn doc
+//@Summary Call client function wait return until the call time out
+//@Param  string      method
+//@Param  interface{} param
+//@Return proto.Message
+//@Return error
+func (slf *RPCSrvClient) CallReturn(method string, param interface{}) (proto.Message, error) {
+	return slf.CallReturnContext(context.Background(), method, param)
+}
+
+//CallReturnContext doc
+//@Summary Call client function wait return until the context is done or the call time out,
+//@Summary the cancel request, the remaining time out and the context metadata travel when
+//@Summary the accesser settled the features, the response trailers fill the
+//@Summary common.WithTrailer metadata of the context
+//@Param  context.Context context
+//@Param  string          method
+//@Param  interface{}     param
+//@Return proto.Message
+//@Return error
+func (slf *RPCSrvClient) CallReturnContext(ctx context.Context, method string, param interface{}) (proto.Message, error) {
+	timeout, err := callTimeout(ctx, slf._timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ser, wait := slf.addPending()
+	defer slf.removePending(ser)
+
+	data, err := common.CallRequest(slf.Version(), slf.Features(), method, ser, param, timeout, slf.outgoing(ctx), slf.codec(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = slf.SendTo(data); err != nil {
+		return nil, err
+	}
+	return slf.waitReturn(ctx, method, ser, wait, timeout)
+}
+
+//callFrame doc
+//@Summary Send an encoded request frame with a new serial and wait return
+//@Param  context.Context context
+//@Param  string          method
+//@Param  []byte          encoded request frame, compressed by the settled compressor
+//@Param  int64           time out/millsecond
+//@Return proto.Message
+//@Return error
+func (slf *RPCSrvClient) callFrame(ctx context.Context, method string, frame []byte, timeout int64) (proto.Message, error) {
+	ser, wait := slf.addPending()
+	defer slf.removePending(ser)
+
+	if err := slf.sendFrame(common.SetSerial(frame, ser)); err != nil {
+		return nil, err
+	}
+	return slf.waitReturn(ctx, method, ser, wait, timeout)
+}
+
+//callTimeout doc
+//@Summary Returns the time out of a call, the remaining time of the context deadline
+//@Summary when shorter
+func callTimeout(ctx context.Context, timeout int64) (int64, error) {
+	if dl, ok := ctx.Deadline(); ok {
+		remain := int64(time.Until(dl) / time.Millisecond)
+		if remain <= 0 {
+			return 0, context.DeadlineExceeded
+		}
+
+		if timeout <= 0 || remain < timeout {
+			timeout = remain
+		}
+	}
+	return timeout, ctx.Err()
+}
+
+func (slf *RPCSrvClient) waitReturn(ctx context.Context, method string, ser uint32, wait chan *common.ResponseEvent, timeout int64) (proto.Message, error) {
+	return common.WaitReturn(ctx, slf.Version(), method, ser, wait, timeout, slf.closed(), slf.canceller())
+}
+
+//canceller doc
+//@Summary Returns the send of the cancel requests, nil the accesser does not take cancel requests
+//@Return func([]byte) error
+func (slf *RPCSrvClient) canceller() func([]byte) error {
+	if slf.Features()&common.FeatureExtend == 0 {
+		return nil
+	}
+	return slf.SendTo
+}
+
+//disconnect doc
+//@Summary Close the accesser disconnected, the calls waiting replies return
+//@Summary code.ErrConnectClosed
+func (slf *RPCSrvClient) disconnect() {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	if slf._closed == nil {
+		return
+	}
+
+	select {
+	case <-slf._closed:
+	default:
+		close(slf._closed)
+	}
+}
+
+//closed doc
+//@Summary Returns the channel closed when the accesser disconnects, nil never
+func (slf *RPCSrvClient) closed() <-chan bool {
+	slf._sync.Lock()
+	defer slf._sync.Unlock()
+	return slf._closed
+}
+
+func (slf *RPCSrvClient) addPending() (uint32, chan *common.ResponseEvent) {
+	wait := make(chan *common.ResponseEvent, 1)
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	for {
+		ser := slf.incSerial()
+		if _, ok := slf._pending[ser]; ok {
+			continue
+		}
+		slf._pending[ser] = wait
+		return ser, wait
+	}
+}
+
+func (slf *RPCSrvClient) removePending(ser uint32) chan *common.ResponseEvent {
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	wait, ok := slf._pending[ser]
+	if !ok {
+		return nil
+	}
+	delete(slf._pending, ser)
+	return wait
+}
+
+//shutdownPending doc
+//@Summary Wake the calls waiting replies with the connection closed error
+func (slf *RPCSrvClient) shutdownPending() {
+	slf._pendingSync.Lock()
+	defer slf._pendingSync.Unlock()
+	for ser, wait := range slf._pending {
+		wait <- &common.ResponseEvent{Ser: ser, Err: code.ErrConnectClosed}
+		delete(slf._pending, ser)
+	}
+}
+
+//NewStream doc
+//@Summary Open a stream to the client method
+//@Param  context.Context stream context
+//@Param  string          remote method
+//@Return *common.Stream
+//@Return error
+func (slf *RPCSrvClient) NewStream(ctx context.Context, method string) (*common.Stream, error) {
+	if slf.Features()&common.FeatureStream == 0 {
+		return nil, code.ErrFeatureUnsupported
+	}
+	return slf.OpenStream(ctx, slf.Version(), method, slf.incSerial(), slf.outgoing(ctx), slf.codec(ctx), slf.SendTo)
+}
+
+func (slf *RPCSrvClient) outgoing(ctx context.Context) common.Metadata {
+	if slf.Features()&common.FeatureMetadata == 0 {
+		return nil
+	}
+	return common.OutgoingMetadata(ctx)
+}
+
+func (slf *RPCSrvClient) incSerial() uint32 {
+	for {
+		ser := atomic.AddUint32(&slf._serial, 1) & 0xFFFFFFF
+		if ser != 0 {
+			return ser
+		}
+	}
+}
+
+//route doc
+//@Summary Handle control events on the decoding goroutine
+//@Param  interface{} decoded event
+//@Return bool        event consumed, otherwise send to the accesser
+func (slf *RPCSrvClient) route(data interface{}) bool {
+	switch event := data.(type) {
+	case *common.CancelEvent:
+		slf.CancelRequest(event.Ser)
+		return true
+	case *common.AuthEvent, *common.HelloEvent:
+		return true
+	case *common.StreamEvent:
+		slf.StreamProcess(slf, slf.SendTo, event)
+		return true
+	case *common.ResponseEvent:
+		if wait := slf.removePending(event.Ser); wait != nil {
+			wait <- event
+			return true
+		}
+
+		if event.Err == nil || !slf.StreamError(event.Ser, event.Err) {
+			slf.LogError("RPC Response error not request wait")
+		}
+		return true
+	}
+	return false
+}
+
 func (slf *RPCSrvClient) onRequest(context actor.Context, sender *actor.PID, message interface{}) {
 	if err := common.RPCRequestProcess(slf, slf.SendTo, message); err != nil {
 		slf.LogError("%s", err)
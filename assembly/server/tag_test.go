@@ -0,0 +1,74 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yamakiller/magicNet/handler/net"
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+func TestTagHandshake(t *testing.T) {
+	var identities []string
+	srv := &RPCServer{_auth: &common.TokenAuth{Token: "token"},
+		_minVer:   common.ConstVersion,
+		_maxVer:   common.ConstVersionMax,
+		_bfSize:   8196,
+		_maxFrame: common.ConstMaxFrameSize,
+		_tagFilter: func(c *RPCSrvClient, key, value string) bool {
+			identities = append(identities, c.Identity())
+			return key != "role"
+		}}
+
+	claims := map[string]string{"service": "gateway", "shard": "3", "role": "admin"}
+	hello, err := common.EncodeHello(&common.HelloEvent{MinVersion: common.ConstVersion,
+		MaxVersion: common.ConstVersionMax,
+		Version:    common.ConstVersion2,
+		Features:   common.ConstFeatures,
+		Tags:       claims})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the answer of the authenticated client, the shard set by the server on the authentication
+	c := &RPCSrvClient{}
+	c.withIdentity("gateway-1")
+	c.SetTag("shard", "9")
+	if err := srv.rpcDispatch(c, rpctest.NewBuffer(hello)); err != net.ErrAnalysisSuccess {
+		t.Fatalf("answer:%v", err)
+	}
+
+	if c.Version() != common.ConstVersion2 {
+		t.Fatalf("settled version %d", c.Version())
+	}
+
+	if tags := c.Tags(); !reflect.DeepEqual(tags, map[string]string{"service": "gateway", "shard": "9"}) {
+		t.Fatalf("tags %v", tags)
+	}
+
+	if !reflect.DeepEqual(c.Claims(), claims) {
+		t.Fatalf("claims %v", c.Claims())
+	}
+
+	if len(identities) != len(claims) || identities[0] != "gateway-1" {
+		t.Fatalf("filtered %v", identities)
+	}
+
+	//the answer is settled once
+	if c.settle() {
+		t.Fatal("answer not settled")
+	}
+
+	//without filter all claims are set
+	srv._tagFilter = nil
+	c = &RPCSrvClient{}
+	c.withIdentity("gateway-2")
+	if err := srv.rpcDispatch(c, rpctest.NewBuffer(hello)); err != net.ErrAnalysisSuccess {
+		t.Fatalf("answer:%v", err)
+	}
+
+	if !reflect.DeepEqual(c.Tags(), claims) {
+		t.Fatalf("tags %v", c.Tags())
+	}
+}
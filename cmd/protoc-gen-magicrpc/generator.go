@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	descriptor "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	plugin "github.com/gogo/protobuf/protoc-gen-gogo/plugin"
+)
+
+const (
+	contextPkg = "context"
+	netPkg     = "github.com/yamakiller/magicNet/handler/net"
+	clientPkg  = "github.com/yamakiller/magicRpc/assembly/client"
+	serverPkg  = "github.com/yamakiller/magicRpc/assembly/server"
+	commonPkg  = "github.com/yamakiller/magicRpc/assembly/common"
+	codePkg    = "github.com/yamakiller/magicRpc/code"
+)
+
+//goType doc
+//@Summary Go type of a proto message
+//@Member string go import path
+//@Member string go package name
+//@Member string go type name
+type goType struct {
+	importPath string
+	pkgName    string
+	name       string
+}
+
+//generator doc
+//@Summary magicRpc code generator of one .proto file
+type generator struct {
+	types   map[string]goType
+	file    *descriptor.FileDescriptorProto
+	imports map[string]string
+	buf     bytes.Buffer
+}
+
+func generate(req *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	resp := &plugin.CodeGeneratorResponse{}
+	files := make(map[string]*descriptor.FileDescriptorProto)
+	types := make(map[string]goType)
+	for _, f := range req.ProtoFile {
+		files[f.GetName()] = f
+		importPath, pkgName := goPackage(f)
+		prefix := "."
+		if f.GetPackage() != "" {
+			prefix += f.GetPackage() + "."
+		}
+
+		for _, m := range f.MessageType {
+			registerType(types, importPath, pkgName, prefix, "", m)
+		}
+	}
+
+	for _, name := range req.FileToGenerate {
+		f, ok := files[name]
+		if !ok || len(f.Service) == 0 {
+			continue
+		}
+
+		g := &generator{types: types, file: f, imports: make(map[string]string)}
+		content, err := g.generate()
+		if err != nil {
+			resp.Error = proto.String(err.Error())
+			return resp
+		}
+
+		resp.File = append(resp.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(strings.TrimSuffix(name, ".proto") + ".magicrpc.go"),
+			Content: proto.String(content),
+		})
+	}
+	return resp
+}
+
+func registerType(types map[string]goType,
+	importPath, pkgName, prefix, parent string,
+	m *descriptor.DescriptorProto) {
+	name := m.GetName()
+	if parent != "" {
+		name = parent + "_" + name
+	}
+
+	types[prefix+m.GetName()] = goType{importPath: importPath, pkgName: pkgName, name: name}
+	for _, n := range m.NestedType {
+		registerType(types, importPath, pkgName, prefix+m.GetName()+".", name, n)
+	}
+}
+
+func goPackage(f *descriptor.FileDescriptorProto) (string, string) {
+	goPkg := f.GetOptions().GetGoPackage()
+	if goPkg != "" {
+		if idx := strings.LastIndex(goPkg, ";"); idx >= 0 {
+			return goPkg[:idx], goPkg[idx+1:]
+		}
+		return goPkg, cleanName(path.Base(goPkg))
+	}
+
+	importPath := path.Dir(f.GetName())
+	if f.GetPackage() != "" {
+		return importPath, cleanName(f.GetPackage())
+	}
+	return importPath, cleanName(strings.TrimSuffix(path.Base(f.GetName()), ".proto"))
+}
+
+func cleanName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '/' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func (slf *generator) P(args ...interface{}) {
+	for _, v := range args {
+		fmt.Fprint(&slf.buf, v)
+	}
+	slf.buf.WriteByte('\n')
+}
+
+func (slf *generator) use(importPath string) string {
+	if name, ok := slf.imports[importPath]; ok {
+		return name
+	}
+
+	name := cleanName(path.Base(importPath))
+	for _, v := range slf.imports {
+		if v == name {
+			name = fmt.Sprintf("%s%d", name, len(slf.imports))
+			break
+		}
+	}
+	slf.imports[importPath] = name
+	return name
+}
+
+func (slf *generator) typeName(protoName string) (string, error) {
+	t, ok := slf.types[protoName]
+	if !ok {
+		return "", fmt.Errorf("%s: message type %s undefined", slf.file.GetName(), protoName)
+	}
+
+	importPath, _ := goPackage(slf.file)
+	if t.importPath == importPath {
+		return t.name, nil
+	}
+	slf.imports[t.importPath] = t.pkgName
+	return t.pkgName + "." + t.name, nil
+}
+
+func (slf *generator) generate() (string, error) {
+	var body bytes.Buffer
+	for _, s := range slf.file.Service {
+		if err := slf.generateService(s); err != nil {
+			return "", err
+		}
+	}
+	body.Write(slf.buf.Bytes())
+	slf.buf.Reset()
+
+	_, pkgName := goPackage(slf.file)
+	slf.P("// Code generated by protoc-gen-magicrpc. DO NOT EDIT.")
+	slf.P("// source: ", slf.file.GetName())
+	slf.P()
+	slf.P("package ", pkgName)
+	slf.P()
+	importPaths := make([]string, 0, len(slf.imports))
+	for importPath := range slf.imports {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+
+	slf.P("import (")
+	for _, importPath := range importPaths {
+		slf.P(slf.imports[importPath], " ", fmt.Sprintf("%q", importPath))
+	}
+	slf.P(")")
+	slf.P()
+	slf.buf.Write(body.Bytes())
+
+	src, err := format.Source(slf.buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%s: %+v", slf.file.GetName(), err)
+	}
+	return string(src), nil
+}
+
+func (slf *generator) generateService(s *descriptor.ServiceDescriptorProto) error {
+	service := s.GetName()
+	ctx := slf.use(contextPkg)
+	net := slf.use(netPkg)
+	client := slf.use(clientPkg)
+	server := slf.use(serverPkg)
+
+	//client
+	slf.P("//", service, "Client doc")
+	slf.P("//@Summary ", service, " typed client over the rpc client pool")
+	slf.P("type ", service, "Client struct {")
+	slf.P("_pool *", client, ".RPCClientPool")
+	slf.P("}")
+	slf.P()
+	slf.P("//New", service, "Client doc")
+	slf.P("//@Summary new a ", service, " typed client")
+	slf.P("//@Param  *client.RPCClientPool")
+	slf.P("//@Return *", service, "Client")
+	slf.P("func New", service, "Client(pool *", client, ".RPCClientPool) *", service, "Client {")
+	slf.P("return &", service, "Client{_pool: pool}")
+	slf.P("}")
+	slf.P()
+
+	for _, m := range s.Method {
+		if err := slf.generateClientMethod(service, m, ctx); err != nil {
+			return err
+		}
+	}
+
+	//server
+	slf.P("//", service, "Server doc")
+	slf.P("//@Summary ", service, " server interface")
+	slf.P("type ", service, "Server interface {")
+	for _, m := range s.Method {
+		sign, err := slf.serverSignature(service, m, ctx, net)
+		if err != nil {
+			return err
+		}
+		slf.P(m.GetName(), sign)
+	}
+	slf.P("}")
+	slf.P()
+	slf.P("//Register", service, "Server doc")
+	slf.P("//@Summary Register ", service, " server to the rpc server")
+	slf.P("//@Param  *server.RPCServer")
+	slf.P("//@Param  ", service, "Server")
+	slf.P("//@Return error")
+	slf.P("func Register", service, "Server(s *", server, ".RPCServer, srv ", service, "Server) error {")
+	slf.P("return s.RegRPCName(", fmt.Sprintf("%q", service), ", &", unexport(service), "Service{_srv: srv})")
+	slf.P("}")
+	slf.P()
+	slf.P("type ", unexport(service), "Service struct {")
+	slf.P("_srv ", service, "Server")
+	slf.P("}")
+	slf.P()
+
+	for _, m := range s.Method {
+		if err := slf.generateServerMethod(service, m, ctx, net); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (slf *generator) generateClientMethod(service string,
+	m *descriptor.MethodDescriptorProto,
+	ctx string) error {
+	in, err := slf.typeName(m.GetInputType())
+	if err != nil {
+		return err
+	}
+
+	out, err := slf.typeName(m.GetOutputType())
+	if err != nil {
+		return err
+	}
+
+	method := m.GetName()
+	fullName := fmt.Sprintf("%q", service+"."+method)
+	if !m.GetClientStreaming() && !m.GetServerStreaming() {
+		slf.P("//", method, " doc")
+		slf.P("//@Summary Call remote ", service, ".", method)
+		slf.P("func (slf *", service, "Client) ", method, "(ctx ", ctx, ".Context, in *", in, ") (*", out, ", error) {")
+		slf.P("out := &", out, "{}")
+		slf.P("if err := slf._pool.CallContext(ctx, ", fullName, ", in, out); err != nil {")
+		slf.P("return nil, err")
+		slf.P("}")
+		slf.P("return out, nil")
+		slf.P("}")
+		slf.P()
+		return nil
+	}
+
+	common := slf.use(commonPkg)
+	stream := service + method + "Client"
+	slf.P("//", method, " doc")
+	slf.P("//@Summary Open remote ", service, ".", method, " stream")
+	if m.GetClientStreaming() {
+		slf.P("func (slf *", service, "Client) ", method, "(ctx ", ctx, ".Context) (*", stream, ", error) {")
+	} else {
+		slf.P("func (slf *", service, "Client) ", method, "(ctx ", ctx, ".Context, in *", in, ") (*", stream, ", error) {")
+	}
+	slf.P("s, err := slf._pool.NewStream(ctx, ", fullName, ")")
+	slf.P("if err != nil {")
+	slf.P("return nil, err")
+	slf.P("}")
+	if !m.GetClientStreaming() {
+		slf.P("if err := s.Send(in); err != nil {")
+		slf.P("return nil, err")
+		slf.P("}")
+		slf.P("if err := s.CloseSend(); err != nil {")
+		slf.P("return nil, err")
+		slf.P("}")
+	}
+	slf.P("return &", stream, "{_stream: s}, nil")
+	slf.P("}")
+	slf.P()
+
+	slf.P("//", stream, " doc")
+	slf.P("//@Summary ", service, ".", method, " client stream")
+	slf.P("type ", stream, " struct {")
+	slf.P("_stream *", common, ".Stream")
+	slf.P("}")
+	slf.P()
+	slf.P("//Context doc")
+	slf.P("//@Summary Returns stream context")
+	slf.P("func (slf *", stream, ") Context() ", ctx, ".Context {")
+	slf.P("return slf._stream.Context()")
+	slf.P("}")
+	slf.P()
+	if m.GetClientStreaming() {
+		slf.P("//Send doc")
+		slf.P("//@Summary Send a message")
+		slf.P("func (slf *", stream, ") Send(m *", in, ") error {")
+		slf.P("return slf._stream.Send(m)")
+		slf.P("}")
+		slf.P()
+	}
+
+	if m.GetServerStreaming() {
+		if m.GetClientStreaming() {
+			slf.P("//CloseSend doc")
+			slf.P("//@Summary Close the sending direction")
+			slf.P("func (slf *", stream, ") CloseSend() error {")
+			slf.P("return slf._stream.CloseSend()")
+			slf.P("}")
+			slf.P()
+		}
+		slf.P("//Recv doc")
+		slf.P("//@Summary Receive a message, returns io.EOF at the end of stream")
+		slf.P("func (slf *", stream, ") Recv() (*", out, ", error) {")
+		slf.P("m, err := slf._stream.Recv()")
+		slf.P("if err != nil {")
+		slf.P("return nil, err")
+		slf.P("}")
+		slf.P("return m.(*", out, "), nil")
+		slf.P("}")
+		slf.P()
+		return nil
+	}
+
+	slf.P("//CloseAndRecv doc")
+	slf.P("//@Summary Close the sending direction and receive the return")
+	slf.P("func (slf *", stream, ") CloseAndRecv() (*", out, ", error) {")
+	slf.P("if err := slf._stream.CloseSend(); err != nil {")
+	slf.P("return nil, err")
+	slf.P("}")
+	slf.P("m, err := slf._stream.Recv()")
+	slf.P("if err != nil {")
+	slf.P("return nil, err")
+	slf.P("}")
+	slf.P("return m.(*", out, "), nil")
+	slf.P("}")
+	slf.P()
+	return nil
+}
+
+func (slf *generator) serverSignature(service string,
+	m *descriptor.MethodDescriptorProto,
+	ctx, net string) (string, error) {
+	in, err := slf.typeName(m.GetInputType())
+	if err != nil {
+		return "", err
+	}
+
+	out, err := slf.typeName(m.GetOutputType())
+	if err != nil {
+		return "", err
+	}
+
+	stream := service + m.GetName() + "Server"
+	switch {
+	case !m.GetClientStreaming() && !m.GetServerStreaming():
+		return "(ctx " + ctx + ".Context, c " + net + ".INetClient, in *" + in + ") (*" + out + ", error)", nil
+	case !m.GetClientStreaming():
+		return "(c " + net + ".INetClient, in *" + in + ", stream *" + stream + ") error", nil
+	default:
+		return "(c " + net + ".INetClient, stream *" + stream + ") error", nil
+	}
+}
+
+func (slf *generator) generateServerMethod(service string,
+	m *descriptor.MethodDescriptorProto,
+	ctx, net string) error {
+	in, err := slf.typeName(m.GetInputType())
+	if err != nil {
+		return err
+	}
+
+	out, err := slf.typeName(m.GetOutputType())
+	if err != nil {
+		return err
+	}
+
+	method := m.GetName()
+	adapter := unexport(service) + "Service"
+	if !m.GetClientStreaming() && !m.GetServerStreaming() {
+		slf.P("func (slf *", adapter, ") ", method, "(ctx ", ctx, ".Context, c ", net, ".INetClient, in *", in, ") (*", out, ", error) {")
+		slf.P("return slf._srv.", method, "(ctx, c, in)")
+		slf.P("}")
+		slf.P()
+		return nil
+	}
+
+	common := slf.use(commonPkg)
+	stream := service + method + "Server"
+	slf.P("func (slf *", adapter, ") ", method, "(c ", net, ".INetClient, s *", common, ".Stream) error {")
+	if !m.GetClientStreaming() {
+		slf.P("m, err := s.Recv()")
+		slf.P("if err != nil {")
+		slf.P("return err")
+		slf.P("}")
+		slf.P("in, ok := m.(*", in, ")")
+		slf.P("if !ok {")
+		code := slf.use(codePkg)
+		slf.P("return ", code, ".NewError(", code, ".CodeParamUndefined, \"param type mismatch\", nil)")
+		slf.P("}")
+		slf.P("return slf._srv.", method, "(c, in, &", stream, "{_stream: s})")
+	} else {
+		slf.P("return slf._srv.", method, "(c, &", stream, "{_stream: s})")
+	}
+	slf.P("}")
+	slf.P()
+
+	slf.P("//", stream, " doc")
+	slf.P("//@Summary ", service, ".", method, " server stream")
+	slf.P("type ", stream, " struct {")
+	slf.P("_stream *", common, ".Stream")
+	slf.P("}")
+	slf.P()
+	slf.P("//Context doc")
+	slf.P("//@Summary Returns stream context, done when the caller gone")
+	slf.P("func (slf *", stream, ") Context() ", ctx, ".Context {")
+	slf.P("return slf._stream.Context()")
+	slf.P("}")
+	slf.P()
+	if m.GetClientStreaming() {
+		slf.P("//Recv doc")
+		slf.P("//@Summary Receive a message, returns io.EOF at the end of stream")
+		slf.P("func (slf *", stream, ") Recv() (*", in, ", error) {")
+		slf.P("m, err := slf._stream.Recv()")
+		slf.P("if err != nil {")
+		slf.P("return nil, err")
+		slf.P("}")
+		slf.P("return m.(*", in, "), nil")
+		slf.P("}")
+		slf.P()
+	}
+
+	if m.GetServerStreaming() {
+		slf.P("//Send doc")
+		slf.P("//@Summary Send a message")
+		slf.P("func (slf *", stream, ") Send(m *", out, ") error {")
+		slf.P("return slf._stream.Send(m)")
+		slf.P("}")
+		slf.P()
+		return nil
+	}
+
+	slf.P("//SendAndClose doc")
+	slf.P("//@Summary Send the return, the stream closes when the method returns")
+	slf.P("func (slf *", stream, ") SendAndClose(m *", out, ") error {")
+	slf.P("return slf._stream.Send(m)")
+	slf.P("}")
+	slf.P()
+	return nil
+}
+
+func unexport(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
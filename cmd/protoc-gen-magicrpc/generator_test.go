@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	descriptor "github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	plugin "github.com/gogo/protobuf/protoc-gen-gogo/plugin"
+
+	_ "github.com/yamakiller/magicRpc/examples/helloworld"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the examples")
+
+const testExampleDir = "../../examples/helloworld"
+
+var (
+	testPackage = regexp.MustCompile(`package\s+([\w.]+)\s*;`)
+	testImport  = regexp.MustCompile(`import\s+"([^"]+)"\s*;`)
+	testService = regexp.MustCompile(`(?s)service\s+(\w+)\s*\{(.*?)\}`)
+	testRPC     = regexp.MustCompile(`rpc\s+(\w+)\s*\(\s*(stream\s+)?([\w.]+)\s*\)\s*returns\s*\(\s*(stream\s+)?([\w.]+)\s*\)`)
+)
+
+//testRegisteredFile returns the descriptor of a .proto file registered by the generated go code
+func testRegisteredFile(t *testing.T, name string) *descriptor.FileDescriptorProto {
+	gz := proto.FileDescriptor(name)
+	if gz == nil {
+		t.Fatalf("%s not registered", name)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(data, f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+//testServiceFile returns the descriptor of the services of a .proto file, the messages
+//are left to the imported files
+func testServiceFile(t *testing.T, name string) *descriptor.FileDescriptorProto {
+	src, err := ioutil.ReadFile(filepath.Join(testExampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &descriptor.FileDescriptorProto{Name: proto.String(name), Syntax: proto.String("proto3")}
+	if m := testPackage.FindSubmatch(src); m != nil {
+		f.Package = proto.String(string(m[1]))
+	}
+
+	for _, m := range testImport.FindAllSubmatch(src, -1) {
+		f.Dependency = append(f.Dependency, string(m[1]))
+	}
+
+	for _, s := range testService.FindAllSubmatch(src, -1) {
+		service := &descriptor.ServiceDescriptorProto{Name: proto.String(string(s[1]))}
+		for _, m := range testRPC.FindAllSubmatch(s[2], -1) {
+			service.Method = append(service.Method, &descriptor.MethodDescriptorProto{
+				Name:            proto.String(string(m[1])),
+				InputType:       proto.String(testTypeName(f, string(m[3]))),
+				OutputType:      proto.String(testTypeName(f, string(m[5]))),
+				ClientStreaming: proto.Bool(len(m[2]) > 0),
+				ServerStreaming: proto.Bool(len(m[4]) > 0),
+			})
+		}
+		f.Service = append(f.Service, service)
+	}
+	return f
+}
+
+func testTypeName(f *descriptor.FileDescriptorProto, name string) string {
+	if strings.Contains(name, ".") || f.GetPackage() == "" {
+		return "." + name
+	}
+	return "." + f.GetPackage() + "." + name
+}
+
+func TestGenerateGolden(t *testing.T) {
+	req := &plugin.CodeGeneratorRequest{FileToGenerate: []string{"greeter.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{testRegisteredFile(t, "helloworld.proto"),
+			testServiceFile(t, "greeter.proto")}}
+
+	resp := generate(req)
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+
+	if len(resp.File) != 1 || resp.File[0].GetName() != "greeter.magicrpc.go" {
+		t.Fatalf("generated files %+v", resp.File)
+	}
+
+	golden := filepath.Join(testExampleDir, resp.File[0].GetName())
+	if *update {
+		if err := ioutil.WriteFile(golden, []byte(resp.File[0].GetContent()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := resp.File[0].GetContent()
+	if got == string(want) {
+		return
+	}
+
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+
+		if g != w {
+			t.Fatalf("%s differs at line %d, run go test -update to regenerate\n got: %q\nwant: %q", golden, i+1, g, w)
+		}
+	}
+}
+
+func TestGenerateUndefinedType(t *testing.T) {
+	//a service of a message type no file defines fails the generation
+	f := &descriptor.FileDescriptorProto{Name: proto.String("undefined.proto"), Package: proto.String("test"),
+		Service: []*descriptor.ServiceDescriptorProto{{Name: proto.String("Test"),
+			Method: []*descriptor.MethodDescriptorProto{{Name: proto.String("Call"),
+				InputType: proto.String(".test.Undefined"), OutputType: proto.String(".test.Undefined")}}}}}
+
+	resp := generate(&plugin.CodeGeneratorRequest{FileToGenerate: []string{"undefined.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{f}})
+	if resp.Error == nil || !strings.Contains(resp.GetError(), ".test.Undefined") {
+		t.Fatalf("undefined message type generated:%v", resp.GetError())
+	}
+}
@@ -0,0 +1,40 @@
+//protoc-gen-magicrpc doc
+//@Summary protoc plugin generating magicRpc typed client stubs and server interfaces
+//@Summary from the service definitions of .proto files
+//@Usage   protoc -I=. --gogoslick_out=. --magicrpc_out=. helloworld.proto
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/gogo/protobuf/proto"
+	plugin "github.com/gogo/protobuf/protoc-gen-gogo/plugin"
+)
+
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+
+	req := &plugin.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		fail(err)
+	}
+
+	resp := generate(req)
+	data, err = proto.Marshal(resp)
+	if err != nil {
+		fail(err)
+	}
+
+	if _, err := os.Stdout.Write(data); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	os.Stderr.WriteString("protoc-gen-magicrpc: " + err.Error() + "\n")
+	os.Exit(1)
+}
@@ -9,6 +9,10 @@ var (
 	ErrMethodName = errors.New("Protocol exception method name exceeded")
 	//ErrDataOverflow error
 	ErrDataOverflow = errors.New("Data overflow")
+	//ErrDataTooLarge error
+	ErrDataTooLarge = errors.New("Data length exceeds the protocol version limit")
+	//ErrVersionUnsupported error
+	ErrVersionUnsupported = errors.New("Protocol version unsupported")
 	//ErrMethodUndefined error
 	ErrMethodUndefined = errors.New("RPC method undefined")
 	//ErrMethodDefinedResponse error
@@ -23,6 +27,30 @@ var (
 	ErrConnectNon = errors.New("Connection does not exist")
 	//ErrConnectFull error
 	ErrConnectFull = errors.New("Connection is full")
+	//ErrStreamClosed error
+	ErrStreamClosed = errors.New("RPC Stream closed")
+	//ErrStreamWindow error
+	ErrStreamWindow = errors.New("RPC Stream window overflow")
+	//ErrFeatureUnsupported error
+	ErrFeatureUnsupported = errors.New("Protocol feature unsupported by the peer")
+	//ErrUnauthenticated error
+	ErrUnauthenticated = errors.New("RPC Connection unauthenticated")
+	//ErrCompressorUndefined error
+	ErrCompressorUndefined = errors.New("Protocol compressor undefined")
+	//ErrCompressCorrupt error
+	ErrCompressCorrupt = errors.New("Compressed data corrupt")
+	//ErrCodecUndefined error
+	ErrCodecUndefined = errors.New("RPC codec undefined")
+	//ErrCodecCorrupt error
+	ErrCodecCorrupt = errors.New("Serialized data corrupt")
+	//ErrContextUndefined error
+	ErrContextUndefined = errors.New("RPC handler context undefined")
+	//ErrEndpointDown error
+	ErrEndpointDown = errors.New("RPC endpoint unavailable")
+	//ErrCircuitOpen error
+	ErrCircuitOpen = errors.New("RPC circuit breaker open")
 	//ErrTimeOut error
 	ErrTimeOut = errors.New("Time out")
+	//ErrHandshake error
+	ErrHandshake = errors.New("Protocol handshake out of order")
 )
@@ -0,0 +1,68 @@
+package code
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	//CodeUnknown unknown error code
+	CodeUnknown int32 = 1
+	//CodeInternal server internal error code
+	CodeInternal int32 = 2
+	//CodeMethodUndefined rpc method undefined error code
+	CodeMethodUndefined int32 = 3
+	//CodeParamUndefined rpc param undefined error code
+	CodeParamUndefined int32 = 4
+	//CodeNoReturn rpc method no return error code
+	CodeNoReturn int32 = 5
+	//CodeTimeOut rpc call deadline exceeded error code
+	CodeTimeOut int32 = 6
+	//CodeUnauthenticated rpc connection authentication failed error code
+	CodeUnauthenticated int32 = 7
+	//CodeVersionUnsupported protocol version incompatible error code
+	CodeVersionUnsupported int32 = 8
+	//CodeUnavailable rpc connection or endpoint unavailable error code
+	CodeUnavailable int32 = 9
+)
+
+//RPCError doc
+//@Summary RPC remote error, returned by the handler or the remote rpc service
+//@Member int32          error code
+//@Member string         error message
+//@Member proto.Message  error details, optional
+type RPCError struct {
+	Code    int32
+	Message string
+	Details proto.Message
+}
+
+//Error doc
+//@Summary Returns error informat
+//@Return string
+func (slf *RPCError) Error() string {
+	return fmt.Sprintf("RPC remote error code:%d message:%s", slf.Code, slf.Message)
+}
+
+//NewError doc
+//@Summary new a rpc remote error
+//@Param  int32          error code
+//@Param  string         error message
+//@Param  proto.Message  error details, can be nil
+//@Return *RPCError
+func NewError(c int32, message string, details proto.Message) *RPCError {
+	return &RPCError{Code: c, Message: message, Details: details}
+}
+
+//ToError doc
+//@Summary Convert error to rpc remote error
+//@Param  error
+//@Return *RPCError
+func ToError(err error) *RPCError {
+	if e, ok := err.(*RPCError); ok {
+		return e
+	}
+
+	return &RPCError{Code: CodeUnknown, Message: err.Error()}
+}
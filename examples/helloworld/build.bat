@@ -1 +1,2 @@
-protoc -I=. -I=%GOPATH%\src --gogoslick_out=. helloworld.proto
\ No newline at end of file
+protoc -I=. -I=%GOPATH%\src --gogoslick_out=. helloworld.proto
+protoc -I=. -I=%GOPATH%\src --magicrpc_out=. greeter.proto
\ No newline at end of file
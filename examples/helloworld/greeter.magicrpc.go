@@ -0,0 +1,280 @@
+// Code generated by protoc-gen-magicrpc. DO NOT EDIT.
+// source: greeter.proto
+
+package helloworld
+
+import (
+	context "context"
+	net "github.com/yamakiller/magicNet/handler/net"
+	client "github.com/yamakiller/magicRpc/assembly/client"
+	common "github.com/yamakiller/magicRpc/assembly/common"
+	server "github.com/yamakiller/magicRpc/assembly/server"
+	code "github.com/yamakiller/magicRpc/code"
+)
+
+// GreeterClient doc
+// @Summary Greeter typed client over the rpc client pool
+type GreeterClient struct {
+	_pool *client.RPCClientPool
+}
+
+// NewGreeterClient doc
+// @Summary new a Greeter typed client
+// @Param  *client.RPCClientPool
+// @Return *GreeterClient
+func NewGreeterClient(pool *client.RPCClientPool) *GreeterClient {
+	return &GreeterClient{_pool: pool}
+}
+
+// SayHello doc
+// @Summary Call remote Greeter.SayHello
+func (slf *GreeterClient) SayHello(ctx context.Context, in *HelloRequest) (*HelloReply, error) {
+	out := &HelloReply{}
+	if err := slf._pool.CallContext(ctx, "Greeter.SayHello", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SayHellos doc
+// @Summary Open remote Greeter.SayHellos stream
+func (slf *GreeterClient) SayHellos(ctx context.Context, in *HelloRequest) (*GreeterSayHellosClient, error) {
+	s, err := slf._pool.NewStream(ctx, "Greeter.SayHellos")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Send(in); err != nil {
+		return nil, err
+	}
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &GreeterSayHellosClient{_stream: s}, nil
+}
+
+// GreeterSayHellosClient doc
+// @Summary Greeter.SayHellos client stream
+type GreeterSayHellosClient struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context
+func (slf *GreeterSayHellosClient) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Recv doc
+// @Summary Receive a message, returns io.EOF at the end of stream
+func (slf *GreeterSayHellosClient) Recv() (*HelloReply, error) {
+	m, err := slf._stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return m.(*HelloReply), nil
+}
+
+// Upload doc
+// @Summary Open remote Greeter.Upload stream
+func (slf *GreeterClient) Upload(ctx context.Context) (*GreeterUploadClient, error) {
+	s, err := slf._pool.NewStream(ctx, "Greeter.Upload")
+	if err != nil {
+		return nil, err
+	}
+	return &GreeterUploadClient{_stream: s}, nil
+}
+
+// GreeterUploadClient doc
+// @Summary Greeter.Upload client stream
+type GreeterUploadClient struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context
+func (slf *GreeterUploadClient) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Send doc
+// @Summary Send a message
+func (slf *GreeterUploadClient) Send(m *HelloRequest) error {
+	return slf._stream.Send(m)
+}
+
+// CloseAndRecv doc
+// @Summary Close the sending direction and receive the return
+func (slf *GreeterUploadClient) CloseAndRecv() (*HelloReply, error) {
+	if err := slf._stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m, err := slf._stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return m.(*HelloReply), nil
+}
+
+// Chat doc
+// @Summary Open remote Greeter.Chat stream
+func (slf *GreeterClient) Chat(ctx context.Context) (*GreeterChatClient, error) {
+	s, err := slf._pool.NewStream(ctx, "Greeter.Chat")
+	if err != nil {
+		return nil, err
+	}
+	return &GreeterChatClient{_stream: s}, nil
+}
+
+// GreeterChatClient doc
+// @Summary Greeter.Chat client stream
+type GreeterChatClient struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context
+func (slf *GreeterChatClient) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Send doc
+// @Summary Send a message
+func (slf *GreeterChatClient) Send(m *HelloRequest) error {
+	return slf._stream.Send(m)
+}
+
+// CloseSend doc
+// @Summary Close the sending direction
+func (slf *GreeterChatClient) CloseSend() error {
+	return slf._stream.CloseSend()
+}
+
+// Recv doc
+// @Summary Receive a message, returns io.EOF at the end of stream
+func (slf *GreeterChatClient) Recv() (*HelloReply, error) {
+	m, err := slf._stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return m.(*HelloReply), nil
+}
+
+// GreeterServer doc
+// @Summary Greeter server interface
+type GreeterServer interface {
+	SayHello(ctx context.Context, c net.INetClient, in *HelloRequest) (*HelloReply, error)
+	SayHellos(c net.INetClient, in *HelloRequest, stream *GreeterSayHellosServer) error
+	Upload(c net.INetClient, stream *GreeterUploadServer) error
+	Chat(c net.INetClient, stream *GreeterChatServer) error
+}
+
+// RegisterGreeterServer doc
+// @Summary Register Greeter server to the rpc server
+// @Param  *server.RPCServer
+// @Param  GreeterServer
+// @Return error
+func RegisterGreeterServer(s *server.RPCServer, srv GreeterServer) error {
+	return s.RegRPCName("Greeter", &greeterService{_srv: srv})
+}
+
+type greeterService struct {
+	_srv GreeterServer
+}
+
+func (slf *greeterService) SayHello(ctx context.Context, c net.INetClient, in *HelloRequest) (*HelloReply, error) {
+	return slf._srv.SayHello(ctx, c, in)
+}
+
+func (slf *greeterService) SayHellos(c net.INetClient, s *common.Stream) error {
+	m, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	in, ok := m.(*HelloRequest)
+	if !ok {
+		return code.NewError(code.CodeParamUndefined, "param type mismatch", nil)
+	}
+	return slf._srv.SayHellos(c, in, &GreeterSayHellosServer{_stream: s})
+}
+
+// GreeterSayHellosServer doc
+// @Summary Greeter.SayHellos server stream
+type GreeterSayHellosServer struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context, done when the caller gone
+func (slf *GreeterSayHellosServer) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Send doc
+// @Summary Send a message
+func (slf *GreeterSayHellosServer) Send(m *HelloReply) error {
+	return slf._stream.Send(m)
+}
+
+func (slf *greeterService) Upload(c net.INetClient, s *common.Stream) error {
+	return slf._srv.Upload(c, &GreeterUploadServer{_stream: s})
+}
+
+// GreeterUploadServer doc
+// @Summary Greeter.Upload server stream
+type GreeterUploadServer struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context, done when the caller gone
+func (slf *GreeterUploadServer) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Recv doc
+// @Summary Receive a message, returns io.EOF at the end of stream
+func (slf *GreeterUploadServer) Recv() (*HelloRequest, error) {
+	m, err := slf._stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return m.(*HelloRequest), nil
+}
+
+// SendAndClose doc
+// @Summary Send the return, the stream closes when the method returns
+func (slf *GreeterUploadServer) SendAndClose(m *HelloReply) error {
+	return slf._stream.Send(m)
+}
+
+func (slf *greeterService) Chat(c net.INetClient, s *common.Stream) error {
+	return slf._srv.Chat(c, &GreeterChatServer{_stream: s})
+}
+
+// GreeterChatServer doc
+// @Summary Greeter.Chat server stream
+type GreeterChatServer struct {
+	_stream *common.Stream
+}
+
+// Context doc
+// @Summary Returns stream context, done when the caller gone
+func (slf *GreeterChatServer) Context() context.Context {
+	return slf._stream.Context()
+}
+
+// Recv doc
+// @Summary Receive a message, returns io.EOF at the end of stream
+func (slf *GreeterChatServer) Recv() (*HelloRequest, error) {
+	m, err := slf._stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return m.(*HelloRequest), nil
+}
+
+// Send doc
+// @Summary Send a message
+func (slf *GreeterChatServer) Send(m *HelloReply) error {
+	return slf._stream.Send(m)
+}
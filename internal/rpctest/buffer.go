@@ -0,0 +1,64 @@
+package rpctest
+
+//Buffer doc
+//@Summary Receive buffer of the frames of the tests
+//@Member []byte data received
+type Buffer struct {
+	Data []byte
+}
+
+//NewBuffer doc
+//@Summary Returns the receive buffer of the frames
+//@Param  ...[]byte frames
+//@Return *Buffer
+func NewBuffer(frames ...[]byte) *Buffer {
+	b := &Buffer{}
+	for _, frame := range frames {
+		b.Data = append(b.Data, frame...)
+	}
+	return b
+}
+
+//ClearBuffer doc
+//@Summary Drop the data received
+func (slf *Buffer) ClearBuffer() { slf.Data = nil }
+
+//GetBufferLen doc
+//@Summary Returns the length of the data received
+//@Return int
+func (slf *Buffer) GetBufferLen() int { return len(slf.Data) }
+
+//GetBufferCap doc
+//@Summary Returns the buffer capacity
+//@Return int
+func (slf *Buffer) GetBufferCap() int { return 8196 }
+
+//GetBufferBytes doc
+//@Summary Returns the data received
+//@Return []byte
+func (slf *Buffer) GetBufferBytes() []byte { return slf.Data }
+
+//TrunBuffer doc
+//@Summary Drop the first bytes of the data received
+//@Param int length
+func (slf *Buffer) TrunBuffer(n int) { slf.Data = slf.Data[n:] }
+
+//ReadBuffer doc
+//@Summary Read the first bytes of the data received
+//@Param  int length
+//@Return []byte
+func (slf *Buffer) ReadBuffer(n int) []byte {
+	r := slf.Data[:n]
+	slf.Data = slf.Data[n:]
+	return r
+}
+
+//WriteBuffer doc
+//@Summary Append data to the data received
+//@Param  []byte data
+//@Return int
+//@Return error
+func (slf *Buffer) WriteBuffer(b []byte) (int, error) {
+	slf.Data = append(slf.Data, b...)
+	return len(b), nil
+}
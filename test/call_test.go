@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/examples/helloworld"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testCallReturn waits the call of serial 7 and returns the frames sent
+func testCallReturn(ctx context.Context, wait chan *common.ResponseEvent, timeout int64,
+	stop chan bool) (*common.ResponseEvent, [][]byte) {
+	var sent [][]byte
+	r, err := common.WaitReturn(ctx, common.ConstVersion, "test.Call", 7, wait, timeout, stop, func(data []byte) error {
+		sent = append(sent, data)
+		return nil
+	})
+	return &common.ResponseEvent{Return: r, Err: err}, sent
+}
+
+//testCancelSent checks the frames sent are the cancel request of the call
+func testCancelSent(t *testing.T, sent [][]byte) {
+	if len(sent) != 1 {
+		t.Fatalf("%d frames sent", len(sent))
+	}
+
+	blk, err := common.Decode(rpctest.NewBuffer(sent[0]), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blk.Oper != common.RPCRequest || blk.DataName != common.ConstCancelName ||
+		blk.Method != "test.Call" || blk.Ser != 7 {
+		t.Fatalf("cancel sent %s %s serial %d", blk.Method, blk.DataName, blk.Ser)
+	}
+}
+
+func TestCallReturnTimeOut(t *testing.T) {
+	start := time.Now()
+	result, sent := testCallReturn(context.Background(), make(chan *common.ResponseEvent, 1), 20, nil)
+	if result.Err != code.ErrTimeOut {
+		t.Fatalf("call:%v", result.Err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Millisecond*20 || elapsed > time.Second {
+		t.Fatalf("call timed out after %v", elapsed)
+	}
+	testCancelSent(t, sent)
+}
+
+func TestCallReturnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*10, cancel)
+	result, sent := testCallReturn(ctx, make(chan *common.ResponseEvent, 1), 0, nil)
+	if result.Err != context.Canceled {
+		t.Fatalf("call:%v", result.Err)
+	}
+	testCancelSent(t, sent)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	result, sent = testCallReturn(ctx, make(chan *common.ResponseEvent, 1), 1000, nil)
+	if result.Err != context.DeadlineExceeded {
+		t.Fatalf("call:%v", result.Err)
+	}
+	testCancelSent(t, sent)
+}
+
+func TestCallReturnResponse(t *testing.T) {
+	wait := make(chan *common.ResponseEvent, 1)
+	wait <- &common.ResponseEvent{Return: &helloworld.HelloReply{Name: "test"}, Ser: 7,
+		Trailer: common.NewMetadata("served", "test")}
+
+	ctx, trailer := common.WithTrailer(context.Background())
+	result, sent := testCallReturn(ctx, wait, 1000, nil)
+	if reply, ok := result.Return.(*helloworld.HelloReply); result.Err != nil || !ok || reply.Name != "test" {
+		t.Fatalf("call returned %+v:%v", result.Return, result.Err)
+	}
+
+	if trailer.Get("served") != "test" {
+		t.Fatalf("trailer %+v", trailer)
+	}
+
+	if len(sent) != 0 {
+		t.Fatalf("%d frames sent after the response", len(sent))
+	}
+
+	//the closed connection sends nothing
+	stop := make(chan bool)
+	close(stop)
+	result, sent = testCallReturn(context.Background(), make(chan *common.ResponseEvent, 1), 1000, stop)
+	if result.Err != code.ErrConnectClosed || len(sent) != 0 {
+		t.Fatalf("call of the closed connection sent %d frames:%v", len(sent), result.Err)
+	}
+}
+
+func TestCallReturnLegacy(t *testing.T) {
+	//the peer without negotiation gets no cancel request
+	r, err := common.WaitReturn(context.Background(), common.ConstVersion, "test.Call", 7,
+		make(chan *common.ResponseEvent, 1), 20, nil, nil)
+	if r != nil || err != code.ErrTimeOut {
+		t.Fatalf("call:%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := common.WaitReturn(ctx, common.ConstVersion, "test.Call", 7,
+		make(chan *common.ResponseEvent, 1), 1000, nil, nil); err != context.Canceled {
+		t.Fatalf("call:%v", err)
+	}
+}
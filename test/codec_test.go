@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+func TestCodecLargeFrame(t *testing.T) {
+	data := make([]byte, 200*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	if _, err := common.Encode(common.ConstVersion, "test.Large", 1, common.RPCRequest, "test.Data", data); err != code.ErrDataTooLarge {
+		t.Fatalf("version 1 encoded %d bytes:%v", len(data), err)
+	}
+
+	frame, err := common.Encode(common.ConstVersion2, "test.Large", 1, common.RPCRequest, "test.Data", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := common.Decode(rpctest.NewBuffer(frame[:len(frame)/2]), 0); err != code.ErrIncompleteData {
+		t.Fatalf("half frame decoded:%v", err)
+	}
+
+	blk, err := common.Decode(rpctest.NewBuffer(frame), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blk.Ver != common.ConstVersion2 || blk.Method != "test.Large" ||
+		blk.DataName != "test.Data" || blk.Ser != 1 || !bytes.Equal(blk.Data, data) {
+		t.Fatal("large frame round trip mismatch")
+	}
+
+	if _, err := common.Decode(rpctest.NewBuffer(frame), 64*1024); err != code.ErrDataOverflow {
+		t.Fatalf("frame over the limit decoded:%v", err)
+	}
+}
+
+func TestCodecHandshake(t *testing.T) {
+	request, err := common.Encode(common.ConstVersion, "test.Undefined", 1, common.RPCRequest, "test.Undefined", []byte{0xFF, 0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := common.EncodeAuth(common.ConstVersion, &common.AuthEvent{Name: "test",
+		Oper:     common.RPCRequest,
+		Identity: "test",
+		Proof:    []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bf := rpctest.NewBuffer(append(request, auth...))
+	ev, err := common.RPCDecodeHandshake(bf, 0)
+	if err != nil || ev != nil {
+		t.Fatalf("request decoded before authentication:%+v %v", ev, err)
+	}
+
+	ev, err = common.RPCDecodeHandshake(bf, 0)
+	if event, ok := ev.(*common.AuthEvent); err != nil || !ok || event.Identity != "test" {
+		t.Fatalf("authentication decoded %+v:%v", ev, err)
+	}
+
+	if _, err := common.RPCDecodeHandshake(rpctest.NewBuffer(request), len(request)-1); err != code.ErrDataOverflow {
+		t.Fatalf("frame over the handshake limit decoded:%v", err)
+	}
+}
+
+//testCodecMessage message of the codec tests
+type testCodecMessage struct {
+	Name  string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Count int32             `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Total int64             `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Ratio float64           `protobuf:"fixed64,4,opt,name=ratio,proto3" json:"ratio,omitempty"`
+	Ok    bool              `protobuf:"varint,5,opt,name=ok,proto3" json:"ok,omitempty"`
+	Tags  []string          `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	Attrs map[string]string `protobuf:"bytes,7,rep,name=attrs,proto3" json:"attrs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Blob  []byte            `protobuf:"bytes,8,opt,name=blob,proto3" json:"blob,omitempty"`
+}
+
+func (m *testCodecMessage) Reset()         { *m = testCodecMessage{} }
+func (m *testCodecMessage) String() string { return proto.CompactTextString(m) }
+func (*testCodecMessage) ProtoMessage()    {}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	msg := &testCodecMessage{Name: "codec",
+		Count: -7,
+		Total: 1 << 40,
+		Ratio: 0.25,
+		Ok:    true,
+		Tags:  []string{"a", strings.Repeat("b", 300)},
+		Attrs: map[string]string{"region": "eu", "shard": "3"},
+		Blob:  []byte{0, 1, 2, 0xFF}}
+
+	for _, name := range []string{common.ConstCodecProto, common.ConstCodecJSON, common.ConstCodecMsgpack} {
+		c := common.GetCodec(name)
+		data, err := c.Marshal(msg)
+		if err != nil {
+			t.Fatalf("%s:%v", name, err)
+		}
+
+		result := &testCodecMessage{}
+		if err := c.Unmarshal(data, result); err != nil {
+			t.Fatalf("%s:%v", name, err)
+		}
+
+		if !reflect.DeepEqual(result, msg) {
+			t.Fatalf("%s round trip %+v", name, result)
+		}
+	}
+}
+
+func TestCodecsMsgpackMalformed(t *testing.T) {
+	c := common.GetCodec(common.ConstCodecMsgpack)
+	data, err := c.Marshal(&testCodecMessage{Name: "codec", Count: 1, Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(data); i++ {
+		if err := c.Unmarshal(data[:i], &testCodecMessage{}); err == nil {
+			t.Fatalf("truncated at %d of %d decoded", i, len(data))
+		}
+	}
+
+	if err := c.Unmarshal(append(data, 0xc0), &testCodecMessage{}); err != code.ErrCodecCorrupt {
+		t.Fatalf("trailing data decoded:%v", err)
+	}
+
+	//a field of the wrong type
+	if err := c.Unmarshal([]byte{0x81, 0xa5, 'c', 'o', 'u', 'n', 't', 0xa1, 'x'}, &testCodecMessage{}); err == nil {
+		t.Fatal("string decoded into int32")
+	}
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+func testRandom(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestCompressSnappy(t *testing.T) {
+	var text bytes.Buffer
+	for i := 0; text.Len() < 100000; i++ {
+		text.WriteString("magicRpc snappy block ")
+		text.WriteString(string(rune('a' + i%26)))
+	}
+
+	cases := map[string][]byte{
+		"empty":         {},
+		"byte":          {1},
+		"literal 59":    testRandom(1, 59),
+		"literal 60":    testRandom(2, 60),
+		"literal 61":    testRandom(3, 61),
+		"literal 256":   testRandom(4, 256),
+		"literal 257":   testRandom(5, 257),
+		"literal 65536": testRandom(6, 65536),
+		"literal 65537": testRandom(7, 65537),
+		"random":        testRandom(8, 200000),
+		"run":           bytes.Repeat([]byte{'a'}, 100000),
+		"run 67":        append(bytes.Repeat([]byte{'b'}, 4+67), 'c'),
+		"run 68":        append(bytes.Repeat([]byte{'b'}, 4+68), 'c'),
+		"pattern":       bytes.Repeat(testRandom(9, 1000), 70),
+		"text":          text.Bytes(),
+	}
+
+	c := &common.SnappyCompressor{}
+	for name, data := range cases {
+		compressed, err := c.Compress(data)
+		if err != nil {
+			t.Fatalf("%s:%v", name, err)
+		}
+
+		result, err := c.Decompress(compressed, len(data))
+		if err != nil {
+			t.Fatalf("%s:%v", name, err)
+		}
+
+		if !bytes.Equal(result, data) {
+			t.Fatalf("%s round trip mismatch", name)
+		}
+
+		if len(data) > 0 {
+			if _, err := c.Decompress(compressed, len(data)-1); err != code.ErrDataOverflow {
+				t.Fatalf("%s decompressed over the limit:%v", name, err)
+			}
+		}
+	}
+
+	for _, name := range []string{"run", "pattern", "text"} {
+		compressed, _ := c.Compress(cases[name])
+		if len(compressed) > len(cases[name])/4 {
+			t.Fatalf("%s compressed %d of %d", name, len(compressed), len(cases[name]))
+		}
+	}
+}
+
+func TestCompressSnappyCorrupt(t *testing.T) {
+	c := &common.SnappyCompressor{}
+	compressed, err := c.Compress(bytes.Repeat(testRandom(1, 300), 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(compressed); i++ {
+		if _, err := c.Decompress(compressed[:i], 1<<20); err == nil {
+			t.Fatalf("truncated at %d of %d decompressed", i, len(compressed))
+		}
+	}
+
+	cases := map[string][]byte{
+		//varint of the length never ends
+		"length": bytes.Repeat([]byte{0xFF}, binary.MaxVarintLen64+1),
+		//literal longer than the data
+		"literal data": {4, 3 << 2, 'a'},
+		//literal longer than the length
+		"literal length": {1, 1 << 2, 'a', 'b'},
+		//literal length bytes missing
+		"literal tag": {4, 61 << 2, 1},
+		//copy before any data
+		"copy offset": {8, 0, 'a', 0<<2 | 0x01, 2},
+		//copy of offset 0
+		"copy zero": {8, 0, 'a', 0<<2 | 0x01, 0},
+		//copy longer than the length
+		"copy length": {4, 0, 'a', 0<<2 | 0x01, 1},
+		//copy offset bytes missing
+		"copy tag": {8, 0, 'a', 3<<2 | 0x02, 1},
+		//data shorter than the length
+		"short": {8, 0, 'a'},
+	}
+
+	for name, data := range cases {
+		if _, err := c.Decompress(data, 1<<20); err == nil {
+			t.Fatalf("%s decompressed", name)
+		}
+	}
+
+	if _, err := c.Decompress([]byte{0x80, 0x80, 0x80, 0x80, 0x08}, 1<<20); err != code.ErrDataOverflow {
+		t.Fatalf("length over the limit:%v", err)
+	}
+}
+
+func TestCompressFrame(t *testing.T) {
+	data := bytes.Repeat(testRandom(1, 500), 300)
+	for _, name := range []string{"gzip", "snappy"} {
+		c := common.GetCompressor(name)
+		for _, ver := range []int{common.ConstVersion, common.ConstVersion2} {
+			payload := data
+			if ver == common.ConstVersion {
+				payload = data[:common.VersionLimit(ver)]
+			}
+
+			frame, err := common.Encode(ver, "test.Compress", 3, common.RPCRequest, "test.Data", payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compressed, err := common.CompressFrame(frame, c, common.ConstCompressThreshold)
+			if err != nil {
+				t.Fatalf("%s:%v", name, err)
+			}
+
+			if len(compressed) >= len(frame) {
+				t.Fatalf("%s version %d frame %d compressed %d", name, ver, len(frame), len(compressed))
+			}
+
+			again, err := common.CompressFrame(compressed, c, 0)
+			if err != nil || !bytes.Equal(again, compressed) {
+				t.Fatalf("%s compressed frame compressed again:%v", name, err)
+			}
+
+			blk, err := common.Decode(rpctest.NewBuffer(compressed), 0)
+			if err != nil {
+				t.Fatalf("%s:%v", name, err)
+			}
+
+			if blk.Ver != ver || blk.Method != "test.Compress" || blk.DataName != "test.Data" ||
+				blk.Ser != 3 || blk.Oper != common.RPCRequest || !bytes.Equal(blk.Data, payload) {
+				t.Fatalf("%s version %d round trip mismatch", name, ver)
+			}
+
+			if _, err := common.Decode(rpctest.NewBuffer(compressed), len(compressed)+1); err != code.ErrDataOverflow {
+				t.Fatalf("%s decompressed over the limit:%v", name, err)
+			}
+		}
+
+		small, _ := common.Encode(common.ConstVersion, "test.Compress", 3, common.RPCRequest, "test.Data", data[:100])
+		if result, err := common.CompressFrame(small, c, common.ConstCompressThreshold); err != nil || !bytes.Equal(result, small) {
+			t.Fatalf("%s frame below the threshold compressed:%v", name, err)
+		}
+
+		random, _ := common.Encode(common.ConstVersion2, "test.Compress", 3, common.RPCRequest, "test.Data", testRandom(2, 100000))
+		if result, err := common.CompressFrame(random, c, 0); err != nil || !bytes.Equal(result, random) {
+			t.Fatalf("%s frame not shrinking compressed:%v", name, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/yamakiller/magicNet/handler/net"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	rpcsrv "github.com/yamakiller/magicRpc/assembly/server"
+	"github.com/yamakiller/magicRpc/examples/helloworld"
+)
+
+type testDFunc struct {
+}
+
+func (slf *testDFunc) Say(c net.INetClient, request *helloworld.HelloRequest) *helloworld.HelloReply {
+	return &helloworld.HelloReply{Name: request.Name}
+}
+
+type testDInvalid struct {
+}
+
+func (slf *testDInvalid) Say(request *helloworld.HelloRequest) string {
+	return request.Name
+}
+
+func TestDispatchSignature(t *testing.T) {
+	if _, err := common.NewRPCService("testDFunc", &testDFunc{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := common.NewRPCService("testDInvalid", &testDInvalid{}); err == nil {
+		t.Fatal("invalid method signature registered")
+	} else {
+		t.Log(err)
+	}
+}
+
+//BenchmarkDispatchLookup per-call method name lookup, as before the dispatch table
+func BenchmarkDispatchLookup(b *testing.B) {
+	obj := &testDFunc{}
+	c := &rpcsrv.RPCSrvClient{}
+	request := &helloworld.HelloRequest{Name: "bench"}
+	sendto := func([]byte) error { return nil }
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		method := reflect.ValueOf(obj).MethodByName("Say")
+		rs := method.Call([]reflect.Value{reflect.ValueOf(c), reflect.ValueOf(request)})
+		for _, r := range rs {
+			if r.Type().Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+				continue
+			}
+
+			msg := r.Interface().(proto.Message)
+			data, _ := proto.Marshal(msg)
+			data, _ = common.Encode(common.ConstVersion, "testDFunc.Say", 1, common.RPCResponse, proto.MessageName(msg), data)
+			sendto(data)
+		}
+	}
+}
+
+//BenchmarkDispatchTable dispatch by the registered method table
+func BenchmarkDispatchTable(b *testing.B) {
+	svc, err := common.NewRPCService("testDFunc", &testDFunc{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	c := &rpcsrv.RPCSrvClient{}
+	request := &helloworld.HelloRequest{Name: "bench"}
+	sendto := func([]byte) error { return nil }
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event := &common.RequestEvent{MethodName: "testDFunc.Say",
+			Method: svc.Method("Say"),
+			Param:  request,
+			Ser:    1,
+			Ver:    common.ConstVersion}
+		if err := common.RPCRequestProcess(c, sendto, event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
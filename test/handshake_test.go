@@ -0,0 +1,93 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testBaselineDecode decode of a client without negotiation, the 1 byte handshake
+//code, then version 1 frames, a response frame without data name is dropped
+//unless its serial is the call waited, 0 when idle
+func testBaselineDecode(t *testing.T, data []byte, wait uint32) {
+	if data[0] != common.ConstHandShakeCode {
+		t.Fatalf("handshake code %x", data[0])
+	}
+
+	bf := rpctest.NewBuffer(data[1:])
+	for bf.GetBufferLen() > 0 {
+		blk, err := common.Decode(bf, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if blk.Ver != common.ConstVersion || blk.Compress != 0 {
+			t.Fatalf("frame version %d compress %d", blk.Ver, blk.Compress)
+		}
+
+		if blk.Oper != common.RPCResponse {
+			t.Fatalf("request frame %s closes the connection", blk.Method)
+		}
+
+		if blk.DataName != "" {
+			t.Fatalf("data name %s undefined closes the connection", blk.DataName)
+		}
+
+		if blk.Ser == wait {
+			t.Fatalf("serial %d taken for the waited call", blk.Ser)
+		}
+	}
+}
+
+func TestHandshakeBaselineClient(t *testing.T) {
+	offer, err := common.EncodeHello(&common.HelloEvent{MinVersion: common.ConstVersion,
+		MaxVersion:  common.ConstVersionMax,
+		Features:    common.ConstFeatures,
+		Compressors: []string{"gzip"},
+		Codecs:      common.Codecs()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := append([]byte{common.ConstHandShakeCode}, offer...)
+	testBaselineDecode(t, data, 0)
+	testBaselineDecode(t, data, 1)
+
+	ev, err := common.RPCDecodeClient(testNoRPC, rpctest.NewBuffer(offer), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello, ok := ev.(*common.HelloEvent)
+	if !ok || hello.MaxVersion != common.ConstVersionMax ||
+		hello.Features != common.ConstFeatures ||
+		len(hello.Compressors) != 1 || hello.Compressors[0] != "gzip" {
+		t.Fatalf("offer decoded %+v", ev)
+	}
+
+	reject, err := common.EncodeHello(&common.HelloEvent{MinVersion: common.ConstVersion2,
+		MaxVersion: common.ConstVersion2,
+		Code:       code.CodeVersionUnsupported,
+		Message:    code.ErrVersionUnsupported.Error()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err = common.RPCDecodeClient(testNoRPC, rpctest.NewBuffer(reject), 0)
+	if hello, ok := ev.(*common.HelloEvent); err != nil || !ok || hello.Code != code.CodeVersionUnsupported {
+		t.Fatalf("reject decoded %+v:%v", ev, err)
+	}
+
+	//a response of a method named as the negotiation is not the negotiation
+	response, err := common.Encode(common.ConstVersion, common.ConstHelloName, 1, common.RPCResponse, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err = common.RPCDecodeClient(testNoRPC, rpctest.NewBuffer(response), 0)
+	if _, ok := ev.(*common.ResponseEvent); err != nil || !ok {
+		t.Fatalf("response decoded %+v:%v", ev, err)
+	}
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/examples/helloworld"
+)
+
+type testInterceptFunc struct {
+	order *[]string
+}
+
+func (slf *testInterceptFunc) Say(c *testPeer, request *helloworld.HelloRequest) *helloworld.HelloReply {
+	*slf.order = append(*slf.order, "handler")
+	return &helloworld.HelloReply{Name: request.Name}
+}
+
+//testRecordServer returns a server interceptor recording its entry and exit
+func testRecordServer(name string, order *[]string) common.UnaryServerInterceptor {
+	return func(ctx context.Context, request proto.Message, info *common.UnaryInfo, handler common.UnaryHandler) (proto.Message, error) {
+		*order = append(*order, name+" in")
+		reply, err := handler(ctx, request)
+		*order = append(*order, name+" out")
+		return reply, err
+	}
+}
+
+func TestInterceptorChain(t *testing.T) {
+	var order []string
+	svc, err := common.NewRPCService("testIntercept", &testInterceptFunc{order: &order})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the first interceptor is the outermost, the handler runs inside the chain
+	var info *common.UnaryInfo
+	peer := newTestPeer(common.ConstFeatures)
+	peer.interceptor = common.ChainUnaryServer(testRecordServer("first", &order),
+		func(ctx context.Context, request proto.Message, i *common.UnaryInfo, handler common.UnaryHandler) (proto.Message, error) {
+			info = i
+			return testRecordServer("second", &order)(ctx, request, i, handler)
+		})
+
+	response := testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testIntercept.Say", 1, nil))
+	if response == nil || response.Err != nil || response.Return.(*helloworld.HelloReply).Name != "request" {
+		t.Fatalf("response %+v", response)
+	}
+
+	want := []string{"first in", "second in", "handler", "second out", "first out"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("chain order %v, want %v", order, want)
+	}
+
+	if info == nil || info.MethodName != "testIntercept.Say" || info.Ser != 1 || info.Peer != peer {
+		t.Fatalf("unary info %+v", info)
+	}
+
+	//an early error skips the later interceptors and the handler
+	order = nil
+	peer.interceptor = common.ChainUnaryServer(
+		func(ctx context.Context, request proto.Message, info *common.UnaryInfo, handler common.UnaryHandler) (proto.Message, error) {
+			order = append(order, "deny")
+			return nil, code.NewError(code.CodeUnauthenticated, "denied", nil)
+		}, testRecordServer("second", &order))
+
+	response = testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testIntercept.Say", 2, nil))
+	if response == nil || code.ToError(response.Err).Code != code.CodeUnauthenticated {
+		t.Fatalf("response %+v", response)
+	}
+
+	if !reflect.DeepEqual(order, []string{"deny"}) {
+		t.Fatalf("chain after the early error %v", order)
+	}
+}
+
+func TestInterceptorClientChain(t *testing.T) {
+	var order []string
+	record := func(name string) common.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, request proto.Message, invoker common.UnaryInvoker) (proto.Message, error) {
+			order = append(order, name+" in")
+			reply, err := invoker(ctx, method, request)
+			order = append(order, name+" out")
+			return reply, err
+		}
+	}
+
+	invoker := func(ctx context.Context, method string, request proto.Message) (proto.Message, error) {
+		order = append(order, "invoker")
+		return &helloworld.HelloReply{Name: method}, nil
+	}
+
+	//the first interceptor is the outermost, the invoker sends the call inside the chain
+	chain := common.ChainUnaryClient(record("first"), record("second"))
+	reply, err := chain(context.Background(), "test.Call", &helloworld.HelloRequest{}, invoker)
+	if err != nil || reply.(*helloworld.HelloReply).Name != "test.Call" {
+		t.Fatalf("reply %+v:%v", reply, err)
+	}
+
+	want := []string{"first in", "second in", "invoker", "second out", "first out"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("chain order %v, want %v", order, want)
+	}
+
+	//an early error skips the later interceptors and the call
+	order = nil
+	chain = common.ChainUnaryClient(func(ctx context.Context, method string, request proto.Message, invoker common.UnaryInvoker) (proto.Message, error) {
+		order = append(order, "deny")
+		return nil, code.ErrUnauthenticated
+	}, record("second"))
+	if _, err := chain(context.Background(), "test.Call", &helloworld.HelloRequest{}, invoker); err != code.ErrUnauthenticated {
+		t.Fatalf("early error:%v", err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"deny"}) {
+		t.Fatalf("chain after the early error %v", order)
+	}
+
+	if common.ChainUnaryServer() != nil || common.ChainUnaryClient() != nil {
+		t.Fatal("empty chain not nil")
+	}
+}
@@ -0,0 +1,168 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/examples/helloworld"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testPeer connection of the request process tests, the settled features, the
+//interceptor chain and the panic recovery, keeps the frames sent
+type testPeer struct {
+	features    uint32
+	interceptor common.UnaryServerInterceptor
+	recovery    bool
+	panics      []string
+	sent        [][]byte
+}
+
+func newTestPeer(features uint32) *testPeer {
+	return &testPeer{features: features, recovery: true}
+}
+
+func (slf *testPeer) Features() uint32 {
+	return slf.features
+}
+
+func (slf *testPeer) UnaryInterceptor() common.UnaryServerInterceptor {
+	return slf.interceptor
+}
+
+func (slf *testPeer) Recovery() bool {
+	return slf.recovery
+}
+
+func (slf *testPeer) OnPanic(methodName string, r interface{}, stack []byte) {
+	slf.panics = append(slf.panics, methodName)
+}
+
+func (slf *testPeer) SendTo(data []byte) error {
+	slf.sent = append(slf.sent, data)
+	return nil
+}
+
+//testProcess decodes the request frame, runs it on the peer and returns the response,
+//nil none sent
+func testProcess(t *testing.T, peer *testPeer, svc *common.RPCService, frame []byte) *common.ResponseEvent {
+	rpcGet := func(name string) *common.RPCService {
+		if name == svc.Name() {
+			return svc
+		}
+		return nil
+	}
+
+	request, err := common.RPCDecodeServer(rpcGet, rpctest.NewBuffer(frame), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := len(peer.sent)
+	if err := common.RPCRequestProcess(peer, peer.SendTo, request); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(peer.sent) == sent {
+		return nil
+	}
+
+	response, err := common.RPCDecodeClient(rpcGet, rpctest.NewBuffer(peer.sent[len(peer.sent)-1]), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.(*common.ResponseEvent)
+}
+
+//testRequest encodes the request of the method with the features settled
+func testRequest(t *testing.T, features uint32, method string, ser uint32, md common.Metadata) []byte {
+	frame, err := common.CallRequest(common.ConstVersion, features, method, ser,
+		&helloworld.HelloRequest{Name: "request"}, 0, md, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+type testMetaFunc struct {
+}
+
+//Echo replies the trace header and echoes it as a trailer
+func (slf *testMetaFunc) Echo(ctx context.Context, c *testPeer, request *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	trace := common.IncomingMetadata(ctx).Get("trace")
+	if err := common.SetTrailer(ctx, common.NewMetadata("trace", trace)); err != nil {
+		return nil, err
+	}
+	return &helloworld.HelloReply{Name: trace}, nil
+}
+
+//Fail sets a trailer and fails
+func (slf *testMetaFunc) Fail(ctx context.Context, c *testPeer, request *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	if err := common.SetTrailer(ctx, common.NewMetadata("retry", "false")); err != nil {
+		return nil, err
+	}
+	return nil, code.NewError(code.CodeUnknown, "fail", nil)
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	svc, err := common.NewRPCService("testMeta", &testMetaFunc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the request headers reach the handler, the trailers reach the call context
+	peer := newTestPeer(common.ConstFeatures)
+	response := testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testMeta.Echo", 1,
+		common.NewMetadata("trace", "42")))
+	if response == nil || response.Err != nil {
+		t.Fatalf("response %+v", response)
+	}
+
+	if reply := response.Return.(*helloworld.HelloReply); reply.Name != "42" {
+		t.Fatalf("handler header %q", reply.Name)
+	}
+
+	ctx, trailer := common.WithTrailer(context.Background())
+	common.CallTrailer(ctx, response)
+	if trailer.Get("trace") != "42" {
+		t.Fatalf("call trailer %+v", trailer)
+	}
+
+	//the trailers travel with the error response
+	response = testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testMeta.Fail", 2, nil))
+	if response == nil || response.Err == nil {
+		t.Fatalf("response %+v", response)
+	}
+
+	ctx, trailer = common.WithTrailer(context.Background())
+	common.CallTrailer(ctx, response)
+	if trailer.Get("retry") != "false" {
+		t.Fatalf("error trailer %+v", trailer)
+	}
+
+	//the peer without the metadata feature gets no headers and no trailers
+	peer = newTestPeer(common.ConstFeatures &^ common.FeatureMetadata)
+	response = testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testMeta.Echo", 3,
+		common.NewMetadata("trace", "42")))
+	if response == nil || response.Err != nil {
+		t.Fatalf("response %+v", response)
+	}
+
+	if reply := response.Return.(*helloworld.HelloReply); reply.Name != "" || len(response.Trailer) != 0 {
+		t.Fatalf("metadata of the peer without the feature %q %+v", reply.Name, response.Trailer)
+	}
+
+	//the request of the features without metadata drops the headers
+	frame := testRequest(t, common.ConstFeatures&^common.FeatureMetadata, "testMeta.Echo", 4,
+		common.NewMetadata("trace", "42"))
+	if blk, err := common.Decode(rpctest.NewBuffer(frame), 0); err != nil || blk.DataName != "helloworld.HelloRequest" {
+		t.Fatalf("request without metadata data name %s:%v", blk.DataName, err)
+	}
+
+	//the trailer of a context not handling a request is refused
+	if err := common.SetTrailer(context.Background(), common.NewMetadata("trace", "1")); err != code.ErrContextUndefined {
+		t.Fatalf("trailer outside a handler:%v", err)
+	}
+}
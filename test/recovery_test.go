@@ -0,0 +1,90 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/examples/helloworld"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+type testRecoverFunc struct {
+}
+
+func (slf *testRecoverFunc) Panic(c *testPeer, request *helloworld.HelloRequest) *helloworld.HelloReply {
+	panic("test panic")
+}
+
+func (slf *testRecoverFunc) Say(c *testPeer, request *helloworld.HelloRequest) *helloworld.HelloReply {
+	return &helloworld.HelloReply{Name: request.Name}
+}
+
+func TestRecoveryPanic(t *testing.T) {
+	svc, err := common.NewRPCService("testRecover", &testRecoverFunc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the panic replies an internal error and is reported to the connection
+	peer := newTestPeer(common.ConstFeatures)
+	panics := common.Panics()
+	response := testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testRecover.Panic", 1, nil))
+	if response == nil || code.ToError(response.Err).Code != code.CodeInternal || response.Ser != 1 {
+		t.Fatalf("response of the panic %+v", response)
+	}
+
+	if len(peer.panics) != 1 || peer.panics[0] != "testRecover.Panic" || common.Panics() != panics+1 {
+		t.Fatalf("panics reported %v, counted %d", peer.panics, common.Panics()-panics)
+	}
+
+	//the connection keeps serving the requests after the panic
+	response = testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testRecover.Say", 2, nil))
+	if response == nil || response.Err != nil || response.Return.(*helloworld.HelloReply).Name != "request" {
+		t.Fatalf("response after the panic %+v", response)
+	}
+
+	//a panic of the interceptor chain is recovered as well
+	peer.interceptor = func(ctx context.Context, request proto.Message, info *common.UnaryInfo, handler common.UnaryHandler) (proto.Message, error) {
+		panic("test interceptor panic")
+	}
+
+	response = testProcess(t, peer, svc, testRequest(t, common.ConstFeatures, "testRecover.Say", 3, nil))
+	if response == nil || code.ToError(response.Err).Code != code.CodeInternal {
+		t.Fatalf("response of the interceptor panic %+v", response)
+	}
+}
+
+func TestRecoveryDisabled(t *testing.T) {
+	svc, err := common.NewRPCService("testRecover", &testRecoverFunc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the connection disabling the recovery lets the panic through, no response is sent
+	peer := newTestPeer(common.ConstFeatures)
+	peer.recovery = false
+	request, err := common.RPCDecodeServer(func(string) *common.RPCService { return svc },
+		rpctest.NewBuffer(testRequest(t, common.ConstFeatures, "testRecover.Panic", 1, nil)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panics := common.Panics()
+	func() {
+		defer func() {
+			if r := recover(); r != "test panic" {
+				t.Fatalf("panic %+v", r)
+			}
+		}()
+		common.RPCRequestProcess(peer, peer.SendTo, request)
+		t.Fatal("panic recovered with the recovery disabled")
+	}()
+
+	if len(peer.sent) != 0 || len(peer.panics) != 0 || common.Panics() != panics {
+		t.Fatalf("%d responses sent, panics reported %v", len(peer.sent), peer.panics)
+	}
+}
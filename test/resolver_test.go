@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yamakiller/magicRpc/assembly/client"
+)
+
+func TestResolverFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "endpoints")
+	if err := ioutil.WriteFile(path, []byte("127.0.0.1:8001\n# drained\n127.0.0.1:8002 # zone a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan []string, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.NewFileResolver(path).WithInterval(10).Resolve(ctx, func(addrs []string) {
+		updates <- addrs
+	})
+
+	if addrs := <-updates; !reflect.DeepEqual(addrs, []string{"127.0.0.1:8001", "127.0.0.1:8002"}) {
+		t.Fatal(addrs)
+	}
+
+	//the empty file and the half written file keep the addresses
+	for _, data := range []string{"", "# drained\n", "127.0.0.1:8003\n127.0"} {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case addrs := <-updates:
+			t.Fatalf("file %q resolved %v", data, addrs)
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", []byte("127.0.0.1:8003\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case addrs := <-updates:
+		if !reflect.DeepEqual(addrs, []string{"127.0.0.1:8003"}) {
+			t.Fatal(addrs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("file change not resolved")
+	}
+}
@@ -1,11 +1,14 @@
 package test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/yamakiller/magicNet/core"
 	"github.com/yamakiller/magicNet/handler/net"
 
@@ -14,7 +17,9 @@ import (
 	"github.com/yamakiller/magicNet/core/boot"
 	"github.com/yamakiller/magicNet/core/frame"
 	"github.com/yamakiller/magicRpc/assembly/client"
+	"github.com/yamakiller/magicRpc/assembly/common"
 	rpcsrv "github.com/yamakiller/magicRpc/assembly/server"
+	"github.com/yamakiller/magicRpc/code"
 	"github.com/yamakiller/magicRpc/examples/helloworld"
 )
 
@@ -22,10 +27,52 @@ type testFunc struct {
 }
 
 func (slf *testFunc) A(c net.INetClient, request *helloworld.HelloRequest) *helloworld.HelloReply {
-	c.(*rpcsrv.RPCSrvClient).LogInfo("Remote Call A Request:%s", request.Name)
+	c.(*rpcsrv.RPCSrvClient).LogInfo("Remote Call A Request:%s Identity:%s", request.Name, c.(*rpcsrv.RPCSrvClient).Identity())
 	return &helloworld.HelloReply{Name: "test"}
 }
 
+func (slf *testFunc) C(ctx context.Context, c net.INetClient, request *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	if info, ok := common.RequestFromContext(ctx); ok {
+		c.(*rpcsrv.RPCSrvClient).LogInfo("Remote Call C Request:%s deadline:%+v trace:%s", info.MethodName, info.Deadline,
+			common.IncomingMetadata(ctx).Get("trace"))
+	}
+	common.SetTrailer(ctx, common.NewMetadata("served", "C"))
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Millisecond * 100):
+	}
+	return &helloworld.HelloReply{Name: request.Name}, nil
+}
+
+func (slf *testFunc) D(c net.INetClient, s *common.Stream) error {
+	for {
+		msg, err := s.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		request := msg.(*helloworld.HelloRequest)
+		for i := 0; i < 3; i++ {
+			if err := s.Send(&helloworld.HelloReply{Name: fmt.Sprintf("%s - %d", request.Name, i)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (slf *testFunc) B(c net.INetClient, request *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	return nil, code.NewError(100, "test error", request)
+}
+
+//testEngine doc
+//@Member []rpcsrv.Option options of the case appended to the server options
+//@Member []client.Option options of the case appended to the client options
 type testEngine struct {
 	core.DefaultBoot
 	core.DefaultService
@@ -33,11 +80,20 @@ type testEngine struct {
 
 	_rpcServer *rpcsrv.RPCServer
 	_rpcClient *client.RPCClientPool
+	_srvOpts   []rpcsrv.Option
+	_cliOpts   []client.Option
 }
 
 func (slf *testEngine) InitService() error {
 	addr := "0.0.0.0:8888"
-	rpcSrv, err := rpcsrv.New(rpcsrv.WithName("testRpc"))
+	rpcSrv, err := rpcsrv.New(append([]rpcsrv.Option{rpcsrv.WithName("testRpc"),
+		rpcsrv.WithUnaryInterceptor(func(ctx context.Context, request proto.Message, info *common.UnaryInfo,
+			handler common.UnaryHandler) (proto.Message, error) {
+			start := time.Now()
+			reply, err := handler(ctx, request)
+			logger.Info(0, "RPC Server %s %+v %v", info.MethodName, err, time.Since(start))
+			return reply, err
+		})}, slf._srvOpts...)...)
 	if err != nil {
 		return errors.New("创建RPC服务失败")
 	}
@@ -54,8 +110,14 @@ func (slf *testEngine) InitService() error {
 
 	logger.Info(0, "RPC开始创建Client")
 	//启动客户端
-	rpcCli, err := client.New(client.WithAddr("127.0.0.1:8888"),
-		client.WithTimeout(1))
+	rpcCli, err := client.New(append([]client.Option{client.WithAddr("127.0.0.1:8888"),
+		client.WithTimeout(1),
+		client.WithUnaryInterceptor(func(ctx context.Context, method string, request proto.Message,
+			invoker common.UnaryInvoker) (proto.Message, error) {
+			reply, err := invoker(common.WithMetadata(ctx, common.NewMetadata("caller", "test")), method, request)
+			logger.Info(0, "RPC Client %s %+v", method, err)
+			return reply, err
+		})}, slf._cliOpts...)...)
 
 	if err != nil && rpcCli != nil {
 		return fmt.Errorf("创建RPC Client Fail%+v", err)
@@ -71,6 +133,42 @@ func (slf *testEngine) InitService() error {
 
 	logger.Info(0, "1.RPC调用成功%+v,%p", r, r)
 
+	err = rpcCli.Call("testFunc.B", &helloworld.HelloRequest{Name: "request - 2"}, r)
+	if rerr, ok := err.(*code.RPCError); ok {
+		logger.Info(0, "2.RPC调用返回错误%d:%s,%+v", rerr.Code, rerr.Message, rerr.Details)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = rpcCli.CallContext(ctx, "testFunc.A", &helloworld.HelloRequest{Name: "request - 3"}, r)
+	if err != nil {
+		logger.Error(0, "3.RPC调用失败:%+v", err)
+		return nil
+	}
+	logger.Info(0, "3.RPC调用成功%+v,%p", r, r)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	ctx, trailer := common.WithTrailer(common.WithMetadata(ctx, common.NewMetadata("trace", "request - 4")))
+	err = rpcCli.CallContext(ctx, "testFunc.C", &helloworld.HelloRequest{Name: "request - 4"}, r)
+	logger.Info(0, "4.RPC调用取消%+v trailer:%+v", err, trailer)
+
+	stream, err := rpcCli.NewStream(context.Background(), "testFunc.D")
+	if err != nil {
+		logger.Error(0, "5.RPC Stream失败:%+v", err)
+		return nil
+	}
+	stream.Send(&helloworld.HelloRequest{Name: "request - 5"})
+	stream.CloseSend()
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			logger.Info(0, "5.RPC Stream结束%+v", err)
+			break
+		}
+		logger.Info(0, "5.RPC Stream返回%+v", msg)
+	}
+
 	//err = rpcCli.Call("testFunc.A", &helloworld.HelloRequest{Name: "request - 1"}, r)
 
 	//logger.Info(0, "2.RPC调用成功%+v,%p", r, r)
@@ -118,3 +216,31 @@ func TestRPCServer(t *testing.T) {
 		return &testEngine{}
 	})
 }
+
+func TestRPCServerAuth(t *testing.T) {
+	authKey := []byte("test auth key")
+	boot.Launch(func() frame.Framework {
+		return &testEngine{_srvOpts: []rpcsrv.Option{rpcsrv.WithAuthenticator(&common.HMACAuth{Key: authKey})},
+			_cliOpts: []client.Option{client.WithAuthenticator(&common.HMACAuth{Identity: "test", Key: authKey})}}
+	})
+}
+
+func TestRPCServerCompress(t *testing.T) {
+	boot.Launch(func() frame.Framework {
+		return &testEngine{_srvOpts: []rpcsrv.Option{rpcsrv.WithCompressor("snappy", "gzip"),
+			rpcsrv.WithCompressThreshold(0)},
+			_cliOpts: []client.Option{client.WithCompressor("gzip"), client.WithCompressThreshold(0)}}
+	})
+}
+
+func TestRPCServerCodec(t *testing.T) {
+	boot.Launch(func() frame.Framework {
+		return &testEngine{_cliOpts: []client.Option{client.WithCodec(common.ConstCodecJSON)}}
+	})
+}
+
+func TestRPCServerMsgpack(t *testing.T) {
+	boot.Launch(func() frame.Framework {
+		return &testEngine{_cliOpts: []client.Option{client.WithCodec(common.ConstCodecMsgpack)}}
+	})
+}
@@ -64,6 +64,7 @@ func (slf *testBEngine) InitService() error {
 		rpcclient.WithIdle(32),
 		rpcclient.WithTimeout(30*1000),
 		rpcclient.WithIdleTimeout(60*1000),
+		rpcclient.WithMaxCalls(16),
 	)
 
 	if err != nil {
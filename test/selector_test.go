@@ -0,0 +1,41 @@
+package test
+
+import (
+	"testing"
+
+	rpcsrv "github.com/yamakiller/magicRpc/assembly/server"
+)
+
+func TestTagSelector(t *testing.T) {
+	tags := map[string]string{"service": "gateway", "shard": "3", "region": "eu"}
+	cases := map[string]bool{
+		"":                          true,
+		"service=gateway,shard=3":   true,
+		"service=gateway, shard=4":  false,
+		"region!=us":                true,
+		"region!=eu":                false,
+		"shard":                     true,
+		"!canary":                   true,
+		"service=gateway,!region":   false,
+		"service = gateway , shard": true,
+	}
+
+	for s, want := range cases {
+		selector, err := rpcsrv.ParseSelector(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+
+		if selector.Matches(tags) != want {
+			t.Fatalf("selector %q matches %v", s, !want)
+		}
+	}
+
+	if _, err := rpcsrv.ParseSelector("=3"); err == nil {
+		t.Fatal("selector without key parsed")
+	}
+
+	if !rpcsrv.SelectorOf(map[string]string{"shard": "3"}).Matches(tags) {
+		t.Fatal("selector of tags")
+	}
+}
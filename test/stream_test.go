@@ -0,0 +1,109 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/yamakiller/magicRpc/assembly/common"
+	"github.com/yamakiller/magicRpc/code"
+	"github.com/yamakiller/magicRpc/internal/rpctest"
+)
+
+//testStreamFrame wire copy of the stream frame message
+type testStreamFrame struct {
+	Flag     int32  `protobuf:"varint,1,opt,name=flag,proto3" json:"flag,omitempty"`
+	DataName string `protobuf:"bytes,4,opt,name=data_name,json=dataName,proto3" json:"data_name,omitempty"`
+	Data     []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *testStreamFrame) Reset()         { *m = testStreamFrame{} }
+func (m *testStreamFrame) String() string { return proto.CompactTextString(m) }
+func (*testStreamFrame) ProtoMessage()    {}
+
+const (
+	testStreamOpen int32 = 0x1
+	testStreamData int32 = 0x2
+)
+
+func testNoRPC(name string) *common.RPCService {
+	return nil
+}
+
+func TestStreamDecodeError(t *testing.T) {
+	data, err := proto.Marshal(&testStreamFrame{Flag: testStreamData, DataName: "test.Undefined", Data: []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := common.Encode(common.ConstVersion, "test.Chat", 7, common.RPCResponse, common.ConstStreamName, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := common.RPCDecodeClient(testNoRPC, rpctest.NewBuffer(frame), 0)
+	if err != nil {
+		t.Fatalf("undefined stream message closed the connection:%v", err)
+	}
+
+	event, ok := ev.(*common.StreamEvent)
+	if !ok || event.Err != code.ErrParamUndefined {
+		t.Fatalf("undefined stream message decoded:%+v", ev)
+	}
+
+	var sent [][]byte
+	sendto := func(b []byte) error {
+		sent = append(sent, b)
+		return nil
+	}
+
+	var streams common.RPCStreams
+	streams.InitialStreams()
+	defer streams.ShutdownStreams()
+
+	failed, err := streams.OpenStream(context.Background(), common.ConstVersion, "test.Chat", 7, nil, nil, sendto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := streams.OpenStream(context.Background(), common.ConstVersion, "test.Chat", 8, nil, nil, sendto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent = nil
+	streams.StreamProcess(nil, sendto, event)
+	if _, err := failed.Recv(); err != code.ErrParamUndefined {
+		t.Fatalf("stream failed with %v", err)
+	}
+
+	if other.Context().Err() != nil {
+		t.Fatal("the other stream of the connection closed")
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d frames, want the cancel", len(sent))
+	}
+
+	ev, err = common.RPCDecodeServer(testNoRPC, rpctest.NewBuffer(sent[0]), 0)
+	if cancel, ok := ev.(*common.CancelEvent); err != nil || !ok || cancel.Ser != 7 {
+		t.Fatalf("sent %+v:%v, want the cancel", ev, err)
+	}
+
+	sent = nil
+	streams.StreamProcess(nil, sendto, &common.StreamEvent{MethodName: "test.Chat",
+		Oper: common.RPCRequest,
+		Ser:  9,
+		Ver:  common.ConstVersion,
+		Flag: testStreamOpen,
+		Err:  code.ErrCodecUndefined})
+	if len(sent) != 1 {
+		t.Fatalf("sent %d frames, want the error reply", len(sent))
+	}
+
+	ev, err = common.RPCDecodeClient(testNoRPC, rpctest.NewBuffer(sent[0]), 0)
+	if reply, ok := ev.(*common.ResponseEvent); err != nil || !ok || reply.Ser != 9 || reply.Err == nil {
+		t.Fatalf("sent %+v:%v, want the error reply", ev, err)
+	}
+}